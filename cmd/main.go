@@ -1,6 +1,9 @@
 package main
 
 import (
+	"strconv"
+	"time"
+
 	"memcached-management/config"
 	"memcached-management/handlers"
 	"memcached-management/services"
@@ -10,19 +13,76 @@ import (
 
 func main() {
 	logger := config.SetupLogger()
-	memcachedService := services.NewMemcachedService()
-	handler := handlers.NewHandler(memcachedService, logger)
+	idleTimeout, err := time.ParseDuration(config.GetEnv("SESSION_IDLE_TIMEOUT", "30m"))
+	if err != nil {
+		logger.WithError(err).Fatal("Invalid SESSION_IDLE_TIMEOUT")
+	}
+	sessions := services.NewSessionManager(idleTimeout)
+	stopJanitor := sessions.StartJanitor(time.Minute)
+	defer stopJanitor()
+
+	profiles, err := services.NewProfileStore(config.GetEnv("PROFILES_FILE", "profiles.json"))
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to load connection profiles")
+	}
+	handler := handlers.NewHandler(sessions, profiles, logger)
+
+	maxListKeys, err := strconv.Atoi(config.GetEnv("LIST_KEYS_MAX", "10000"))
+	if err != nil || maxListKeys < 1 {
+		logger.WithField("value", config.GetEnv("LIST_KEYS_MAX", "")).Fatal("Invalid LIST_KEYS_MAX")
+	}
+	handler.SetMaxListKeys(maxListKeys)
 
 	r := gin.Default()
 
+	r.Use(handler.MetricsMiddleware())
+
 	r.GET("/", handler.ServeIndex)
 	r.POST("/connect", handler.HandleConnect)
+	r.POST("/disconnect", handler.HandleDisconnect)
 	r.POST("/set", handler.HandleSet)
 	r.POST("/get", handler.HandleGet)
 	r.POST("/getMultiple", handler.HandleGetMultiple)
 	r.POST("/delete", handler.HandleDelete)
 	r.POST("/flush", handler.HandleFlush)
+	r.POST("/incr", handler.HandleIncrement)
+	r.POST("/decr", handler.HandleDecrement)
+	r.POST("/add", handler.HandleAdd)
+	r.POST("/replace", handler.HandleReplace)
+	r.POST("/append", handler.HandleAppend)
+	r.POST("/prepend", handler.HandlePrepend)
+	r.POST("/touch", handler.HandleTouch)
 	r.POST("/listKeys", handler.HandleListKeys)
+	r.POST("/deleteByPattern", handler.HandleDeleteByPattern)
+	r.POST("/keyTree", handler.HandleKeyTree)
+	r.GET("/export", handler.HandleExport)
+	r.POST("/import", handler.HandleImport)
+	r.POST("/migrations", handler.HandleStartMigration)
+	r.GET("/migrations", handler.HandleListMigrations)
+	r.GET("/migrations/:id", handler.HandleGetMigration)
+	r.POST("/migrations/:id/cancel", handler.HandleCancelMigration)
+	r.POST("/diff", handler.HandleDiff)
+	r.POST("/diff/files", handler.HandleDiffFiles)
+	r.GET("/decoders", handler.HandleListDecoders)
+	r.PUT("/decoders/rules", handler.HandleSetDecoderRules)
+	r.POST("/decoders/protobuf", handler.HandleUploadProtoDescriptors)
+	r.GET("/stats", handler.HandleStats)
+	r.GET("/watch", handler.HandleWatch)
+	r.POST("/hotkeys", handler.HandleStartHotKeys)
+	r.GET("/hotkeys", handler.HandleGetHotKeys)
+	r.POST("/hotkeys/stop", handler.HandleStopHotKeys)
+	r.GET("/keywatches", handler.HandleListKeyWatches)
+	r.POST("/keywatches", handler.HandleAddKeyWatch)
+	r.GET("/keywatches/events", handler.HandleKeyWatchEvents)
+	r.GET("/keywatches/:id", handler.HandleGetKeyWatch)
+	r.DELETE("/keywatches/:id", handler.HandleRemoveKeyWatch)
+	r.GET("/metrics", handler.HandleMetrics)
+
+	r.GET("/profiles", handler.HandleListProfiles)
+	r.POST("/profiles", handler.HandleCreateProfile)
+	r.GET("/profiles/:name", handler.HandleGetProfile)
+	r.PUT("/profiles/:name", handler.HandleUpdateProfile)
+	r.DELETE("/profiles/:name", handler.HandleDeleteProfile)
 
 	logger.Info("Server starting on http://localhost:5000")
 	r.Run(":5000")
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"memcached-management/models"
+	"memcached-management/services"
+)
+
+// maxDescriptorSetSize bounds descriptor uploads; real sets with all their
+// imports are well under a megabyte.
+const maxDescriptorSetSize = 8 << 20
+
+// HandleListDecoders returns the decoder names and the prefix rules.
+func (h *Handler) HandleListDecoders(c *gin.Context) {
+	c.JSON(http.StatusOK, h.decodersResponse(""))
+}
+
+// HandleSetDecoderRules replaces the prefix rules that pick a decoder for
+// keys, e.g. pickle for "py:". The rules apply to every session.
+func (h *Handler) HandleSetDecoderRules(c *gin.Context) {
+	var req models.DecoderRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Invalid request data")
+		c.JSON(http.StatusBadRequest, models.DecodersResponse{Success: false, Error: "Invalid data"})
+		return
+	}
+
+	if err := h.decoders.SetRules(req.Rules); err != nil {
+		c.JSON(http.StatusBadRequest, models.DecodersResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	h.logger.WithField("rules", len(req.Rules)).Info("Decoder rules updated")
+	c.JSON(http.StatusOK, h.decodersResponse("Decoder rules saved"))
+}
+
+// HandleUploadProtoDescriptors replaces the protobuf schemas with an
+// uploaded FileDescriptorSet, sent as the multipart field "descriptors".
+// Rules then map key prefixes to its message types.
+func (h *Handler) HandleUploadProtoDescriptors(c *gin.Context) {
+	header, err := c.FormFile("descriptors")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.DecodersResponse{Success: false, Error: "A descriptor set upload is required"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to open upload")
+		c.JSON(http.StatusInternalServerError, models.DecodersResponse{Success: false, Error: "Error loading descriptors: " + err.Error()})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxDescriptorSetSize+1))
+	if err == nil && len(data) > maxDescriptorSetSize {
+		err = fmt.Errorf("%w: larger than %d bytes", services.ErrInvalidDescriptors, maxDescriptorSetSize)
+	}
+	var messages []string
+	if err == nil {
+		messages, err = h.decoders.SetProtoDescriptors(data)
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidDescriptors) {
+			status = http.StatusBadRequest
+		}
+		h.logger.WithError(err).Error("Failed to load descriptors")
+		c.JSON(status, models.DecodersResponse{Success: false, Error: "Error loading descriptors: " + err.Error()})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"file":     header.Filename,
+		"messages": len(messages),
+	}).Info("Protobuf descriptors loaded")
+	c.JSON(http.StatusOK, h.decodersResponse(fmt.Sprintf("Loaded %d message types", len(messages))))
+}
+
+func (h *Handler) decodersResponse(message string) models.DecodersResponse {
+	return models.DecodersResponse{
+		Success:       true,
+		Message:       message,
+		Decoders:      h.decoders.Names(),
+		Rules:         h.decoders.Rules(),
+		ProtoMessages: h.decoders.ProtoMessages(),
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"memcached-management/models"
+	"memcached-management/services"
+)
+
+// HandleDiff compares the keys and values of two clusters.
+func (h *Handler) HandleDiff(c *gin.Context) {
+	var req models.DiffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Invalid request data")
+		c.JSON(http.StatusBadRequest, models.DiffResponse{Success: false, Error: "Invalid data"})
+		return
+	}
+
+	a, err := h.endpointProfile("a", req.A)
+	if err != nil {
+		c.JSON(diffErrorStatus(err), models.DiffResponse{Success: false, Error: err.Error()})
+		return
+	}
+	b, err := h.endpointProfile("b", req.B)
+	if err != nil {
+		c.JSON(diffErrorStatus(err), models.DiffResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	report, err := services.DiffServers(c.Request.Context(), a, b, req.Prefix)
+	h.respondDiff(c, report, err)
+}
+
+// HandleDiffFiles compares two export files, uploaded as the multipart
+// fields "a" and "b".
+func (h *Handler) HandleDiffFiles(c *gin.Context) {
+	headerA, errA := c.FormFile("a")
+	headerB, errB := c.FormFile("b")
+	if errA != nil || errB != nil {
+		c.JSON(http.StatusBadRequest, models.DiffResponse{Success: false, Error: "Files a and b are required"})
+		return
+	}
+
+	fileA, err := headerA.Open()
+	if err != nil {
+		h.respondDiff(c, models.DiffReport{}, err)
+		return
+	}
+	defer fileA.Close()
+	fileB, err := headerB.Open()
+	if err != nil {
+		h.respondDiff(c, models.DiffReport{}, err)
+		return
+	}
+	defer fileB.Close()
+
+	report, err := services.DiffDumps(fileA, fileB, c.PostForm("prefix"))
+	report.A, report.B = headerA.Filename, headerB.Filename
+	h.respondDiff(c, report, err)
+}
+
+func (h *Handler) respondDiff(c *gin.Context, report models.DiffReport, err error) {
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to compare")
+		c.JSON(diffErrorStatus(err), models.DiffResponse{Success: false, Error: "Error comparing: " + err.Error()})
+		return
+	}
+
+	summary := report.Summary
+	h.logger.WithFields(logrus.Fields{
+		"a":          report.A,
+		"b":          report.B,
+		"only_in_a":  summary.OnlyInA,
+		"only_in_b":  summary.OnlyInB,
+		"mismatched": summary.Mismatched,
+	}).Info("Diff finished")
+	c.JSON(http.StatusOK, models.DiffResponse{
+		Success: true,
+		Report:  &report,
+		Message: fmt.Sprintf("Compared %d and %d keys: %d only in A, %d only in B, %d mismatched", summary.KeysA, summary.KeysB, summary.OnlyInA, summary.OnlyInB, summary.Mismatched),
+	})
+}
+
+func diffErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrProfileNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidProfile), errors.Is(err, services.ErrInvalidDiff):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"memcached-management/models"
+	"memcached-management/services"
+)
+
+// exportError is the last line of an export that failed part way, so a
+// truncated file is never mistaken for a complete one.
+type exportError struct {
+	Error string `json:"error"`
+}
+
+// HandleExport streams the cache contents as a JSON Lines download, gzip
+// compressed with format=gzip. The prefix query parameter limits the
+// export to one namespace.
+func (h *Handler) HandleExport(c *gin.Context) {
+	format := c.DefaultQuery("format", "jsonl")
+	if format != "jsonl" && format != "gzip" {
+		c.JSON(http.StatusBadRequest, models.ItemResponse{Success: false, Error: "Invalid format: " + format})
+		return
+	}
+
+	var match *services.KeyMatcher
+	if prefix := c.Query("prefix"); prefix != "" {
+		match, _ = services.NewKeyMatcher(models.KeyFilter{Prefix: prefix})
+	}
+
+	var (
+		started bool
+		buf     *bufio.Writer
+		gz      *gzip.Writer
+		enc     *json.Encoder
+	)
+	start := func() {
+		started = true
+		filename := "memcached-export-" + time.Now().Format("20060102-150405") + ".jsonl"
+		if format == "gzip" {
+			filename += ".gz"
+			c.Header("Content-Type", "application/gzip")
+		} else {
+			c.Header("Content-Type", "application/x-ndjson")
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.Status(http.StatusOK)
+
+		var out io.Writer = c.Writer
+		if format == "gzip" {
+			gz = gzip.NewWriter(c.Writer)
+			out = gz
+		}
+		buf = bufio.NewWriter(out)
+		enc = json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+	}
+
+	exported, skipped, err := h.service(c).Export(c.Request.Context(), match, func(record models.DumpRecord) error {
+		if !started {
+			start()
+		}
+		return enc.Encode(record)
+	})
+
+	logger := h.logger.WithFields(logrus.Fields{"exported": exported, "skipped": skipped, "format": format})
+	if err != nil && !started {
+		logger.WithError(err).Error("Failed to export")
+		c.JSON(http.StatusInternalServerError, models.ItemResponse{Success: false, Error: "Error exporting: " + err.Error()})
+		return
+	}
+	if !started {
+		start()
+	}
+	if err != nil {
+		logger.WithError(err).Error("Export failed part way")
+		enc.Encode(exportError{Error: "Error exporting: " + err.Error()})
+	}
+
+	buf.Flush()
+	if gz != nil {
+		gz.Close()
+	}
+	if err == nil {
+		logger.Info("Export finished")
+	}
+}
@@ -1,27 +1,65 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"memcached-management/models"
 	"memcached-management/services"
 )
 
+const (
+	sessionCookie = "mcv_session"
+	sessionHeader = "X-Session-Token"
+	scrapeTimeout = 5 * time.Second
+)
+
 type Handler struct {
-	memcachedService *services.MemcachedService
-	logger           *logrus.Logger
+	sessions    *services.SessionManager
+	profiles    *services.ProfileStore
+	metrics     *services.RequestMetrics
+	migrations  *services.MigrationManager
+	decoders    *services.DecoderRegistry
+	logger      *logrus.Logger
+	maxListKeys int
 }
 
-func NewHandler(memcachedService *services.MemcachedService, logger *logrus.Logger) *Handler {
+func NewHandler(sessions *services.SessionManager, profiles *services.ProfileStore, logger *logrus.Logger) *Handler {
 	return &Handler{
-		memcachedService: memcachedService,
-		logger:           logger,
+		sessions:    sessions,
+		profiles:    profiles,
+		metrics:     services.NewRequestMetrics(),
+		migrations:  services.NewMigrationManager(),
+		decoders:    services.NewDecoderRegistry(),
+		logger:      logger,
+		maxListKeys: defaultMaxListKeys,
 	}
 }
 
+// service returns the MemcachedService of the caller's session. The session
+// is identified by the X-Session-Token header or the session cookie; a new
+// session is started (and the cookie set) when neither matches.
+func (h *Handler) service(c *gin.Context) *services.MemcachedService {
+	token := c.GetHeader(sessionHeader)
+	if token == "" {
+		token, _ = c.Cookie(sessionCookie)
+	}
+
+	id, service := h.sessions.Get(token)
+	if id != token {
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(sessionCookie, id, 0, "/", "", false, true)
+	}
+	c.Header(sessionHeader, id)
+
+	return service
+}
+
 func (h *Handler) ServeIndex(c *gin.Context) {
 	c.File("./web/index.html")
 }
@@ -34,19 +72,43 @@ func (h *Handler) HandleConnect(c *gin.Context) {
 		return
 	}
 
-	if req.URL == "" {
+	profile := models.Profile{Servers: append([]string{req.URL}, req.Servers...)}
+	if req.Profile != "" {
+		stored, err := h.profiles.Get(req.Profile)
+		if err != nil {
+			h.logger.WithError(err).WithField("profile", req.Profile).Warn("Profile not found")
+			c.JSON(http.StatusNotFound, models.ConnectResponse{Success: false, Error: "Profile not found: " + req.Profile})
+			return
+		}
+		profile = stored
+	} else if req.URL == "" && len(req.Servers) == 0 {
 		c.JSON(http.StatusBadRequest, models.ConnectResponse{Success: false, Error: "URL is required"})
 		return
 	}
 
-	if err := h.memcachedService.Connect(req.URL); err != nil {
-		h.logger.WithError(err).WithField("url", req.URL).Error("Failed to connect to Memcached")
+	service := h.service(c)
+	if err := service.ConnectProfile(profile); err != nil {
+		h.logger.WithError(err).WithField("servers", profile.Servers).Error("Failed to connect to Memcached")
 		c.JSON(http.StatusInternalServerError, models.ConnectResponse{Success: false, Error: "Unable to connect: " + err.Error()})
 		return
 	}
 
-	h.logger.WithField("url", req.URL).Info("Successfully connected to Memcached")
-	c.JSON(http.StatusOK, models.ConnectResponse{Success: true, Message: "Connection successful!"})
+	servers := service.Servers()
+	h.logger.WithFields(logrus.Fields{"servers": servers, "profile": profile.Name}).Info("Successfully connected to Memcached")
+	c.JSON(http.StatusOK, models.ConnectResponse{
+		Success:  true,
+		Message:  "Connection successful!",
+		Servers:  servers,
+		Profile:  profile.Name,
+		ReadOnly: profile.ReadOnly,
+	})
+}
+
+func (h *Handler) HandleDisconnect(c *gin.Context) {
+	h.service(c).Disconnect()
+
+	h.logger.Info("Disconnected from Memcached")
+	c.JSON(http.StatusOK, models.ConnectResponse{Success: true, Message: "Disconnected"})
 }
 
 func (h *Handler) HandleSet(c *gin.Context) {
@@ -57,7 +119,30 @@ func (h *Handler) HandleSet(c *gin.Context) {
 		return
 	}
 
-	if err := h.memcachedService.Set(req.Key, req.Value); err != nil {
+	if err := decodeRequestValue(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ItemResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	service := h.service(c)
+
+	var err error
+	if req.CAS != 0 {
+		err = service.CompareAndSwap(req.Key, req.Value, req.Flags, req.TTL, req.CAS)
+	} else {
+		err = service.Set(req.Key, req.Value, req.Flags, req.TTL)
+	}
+
+	if errors.Is(err, services.ErrCASConflict) {
+		h.logger.WithError(err).WithField("key", req.Key).Warn("Item changed since it was read")
+		response := models.ItemResponse{Success: false, Error: "Conflict: the item was changed by someone else since it was loaded"}
+		if item, meta, err := service.GetWithMeta(req.Key); err == nil {
+			response.Items = []models.Item{h.responseItem(item, meta)}
+		}
+		c.JSON(http.StatusConflict, response)
+		return
+	}
+	if err != nil {
 		h.logger.WithError(err).WithField("key", req.Key).Error("Failed to set item")
 		c.JSON(http.StatusInternalServerError, models.ItemResponse{Success: false, Error: "Error saving: " + err.Error()})
 		return
@@ -75,17 +160,40 @@ func (h *Handler) HandleGet(c *gin.Context) {
 		return
 	}
 
-	item, err := h.memcachedService.Get(req.Key)
+	item, meta, err := h.service(c).GetWithMeta(req.Key)
 	if err != nil {
 		h.logger.WithError(err).WithField("key", req.Key).Warn("Item not found")
 		c.JSON(http.StatusNotFound, models.ItemResponse{Success: false, Error: "Item not found: " + err.Error()})
 		return
 	}
 
-	items := []models.Item{{Key: item.Key, Value: string(item.Value)}}
+	items := []models.Item{h.responseItem(item, meta)}
 	c.JSON(http.StatusOK, models.ItemResponse{Success: true, Items: items})
 }
 
+// responseItem returns item with its raw value, encoded for JSON, and its
+// decoded view when the value is in a known format.
+func (h *Handler) responseItem(item *memcache.Item, meta *models.ItemMeta) models.Item {
+	responseItem := models.Item{Key: item.Key, Flags: item.Flags, CAS: item.CasID, Meta: meta}
+	responseItem.Value, responseItem.Encoding = services.EncodeValue(item.Value)
+	responseItem.Decoded = h.decoders.Decode(item.Key, item.Value, item.Flags)
+	if meta != nil {
+		responseItem.Server = meta.Server
+	}
+	return responseItem
+}
+
+// decodeRequestValue replaces a base64 encoded request value with its raw
+// bytes.
+func decodeRequestValue(req *models.ItemRequest) error {
+	value, err := services.DecodeValue(req.Value, req.Encoding)
+	if err != nil {
+		return fmt.Errorf("Invalid value: %v", err)
+	}
+	req.Value, req.Encoding = string(value), ""
+	return nil
+}
+
 func (h *Handler) HandleGetMultiple(c *gin.Context) {
 	var req models.ItemRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -94,7 +202,7 @@ func (h *Handler) HandleGetMultiple(c *gin.Context) {
 		return
 	}
 
-	items, err := h.memcachedService.GetMultiple(req.Keys)
+	items, err := h.service(c).GetMultiple(req.Keys)
 	if err != nil {
 		h.logger.WithError(err).WithField("keys", req.Keys).Warn("Failed to get multiple items")
 		c.JSON(http.StatusNotFound, models.ItemResponse{Success: false, Error: err.Error()})
@@ -103,7 +211,7 @@ func (h *Handler) HandleGetMultiple(c *gin.Context) {
 
 	var responseItems []models.Item
 	for _, item := range items {
-		responseItems = append(responseItems, models.Item{Key: item.Key, Value: string(item.Value)})
+		responseItems = append(responseItems, h.responseItem(&item, nil))
 	}
 
 	c.JSON(http.StatusOK, models.ItemResponse{Success: true, Items: responseItems})
@@ -117,7 +225,7 @@ func (h *Handler) HandleDelete(c *gin.Context) {
 		return
 	}
 
-	if err := h.memcachedService.Delete(req.Key); err != nil {
+	if err := h.service(c).Delete(req.Key); err != nil {
 		h.logger.WithError(err).WithField("key", req.Key).Error("Failed to delete item")
 		c.JSON(http.StatusInternalServerError, models.ItemResponse{Success: false, Error: "Error deleting: " + err.Error()})
 		return
@@ -128,7 +236,7 @@ func (h *Handler) HandleDelete(c *gin.Context) {
 }
 
 func (h *Handler) HandleFlush(c *gin.Context) {
-	if err := h.memcachedService.FlushAll(); err != nil {
+	if err := h.service(c).FlushAll(); err != nil {
 		h.logger.WithError(err).Error("Failed to flush cache")
 		c.JSON(http.StatusInternalServerError, models.ItemResponse{Success: false, Error: "Error flushing cache: " + err.Error()})
 		return
@@ -137,20 +245,3 @@ func (h *Handler) HandleFlush(c *gin.Context) {
 	h.logger.Info("Cache flushed successfully")
 	c.JSON(http.StatusOK, models.ItemResponse{Success: true, Message: "All items cleared successfully!"})
 }
-
-func (h *Handler) HandleListKeys(c *gin.Context) {
-	keys, err := h.memcachedService.GetAllKeys()
-	if err != nil {
-		h.logger.WithError(err).Error("Failed to list keys")
-		c.JSON(http.StatusInternalServerError, models.ItemResponse{Success: false, Error: "Error listing keys: " + err.Error()})
-		return
-	}
-
-	var items []models.Item
-	for _, key := range keys {
-		items = append(items, models.Item{Key: key})
-	}
-
-	h.logger.WithField("count", len(keys)).Info("Keys listed successfully")
-	c.JSON(http.StatusOK, models.ItemResponse{Success: true, Items: items, Message: fmt.Sprintf("Found %d keys", len(keys))})
-}
\ No newline at end of file
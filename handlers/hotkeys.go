@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"memcached-management/models"
+	"memcached-management/services"
+)
+
+// HandleStartHotKeys starts counting the most requested keys and prefixes
+// of the connected servers from their watch stream. The report is polled
+// with GET /hotkeys.
+func (h *Handler) HandleStartHotKeys(c *gin.Context) {
+	var req models.HotKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Invalid request data")
+		c.JSON(http.StatusBadRequest, models.HotKeyResponse{Success: false, Error: "Invalid data"})
+		return
+	}
+
+	report, err := h.service(c).StartHotKeys(req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to start hot key analysis")
+		c.JSON(hotKeysErrorStatus(err), models.HotKeyResponse{Success: false, Error: "Error starting hot key analysis: " + err.Error()})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"window": report.WindowSeconds,
+		"top_k":  report.TopK,
+		"prefix": report.Prefix,
+	}).Info("Hot key analysis started")
+	c.JSON(http.StatusOK, models.HotKeyResponse{Success: true, Message: "Hot key analysis started", Report: &report})
+}
+
+func (h *Handler) HandleGetHotKeys(c *gin.Context) {
+	report, err := h.service(c).HotKeys()
+	if err != nil {
+		c.JSON(hotKeysErrorStatus(err), models.HotKeyResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.HotKeyResponse{Success: true, Report: &report})
+}
+
+func (h *Handler) HandleStopHotKeys(c *gin.Context) {
+	report, err := h.service(c).StopHotKeys()
+	if err != nil {
+		c.JSON(hotKeysErrorStatus(err), models.HotKeyResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	h.logger.WithField("events", report.Events).Info("Hot key analysis stopped")
+	c.JSON(http.StatusOK, models.HotKeyResponse{Success: true, Message: "Hot key analysis stopped", Report: &report})
+}
+
+func hotKeysErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrHotKeysNotStarted):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidHotKeys):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"memcached-management/models"
+	"memcached-management/services"
+)
+
+// HandleImport writes the items of an uploaded JSON Lines or CSV file. The
+// file is sent as the multipart field "file" with the import options as
+// form fields, and the response reports the outcome line by line.
+func (h *Handler) HandleImport(c *gin.Context) {
+	var opts models.ImportOptions
+	if err := c.ShouldBind(&opts); err != nil {
+		h.logger.WithError(err).Error("Invalid request data")
+		c.JSON(http.StatusBadRequest, models.ImportResponse{Success: false, Error: "Invalid data"})
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ImportResponse{Success: false, Error: "A file upload is required"})
+		return
+	}
+	if opts.Format == "" && strings.HasSuffix(strings.TrimSuffix(strings.ToLower(header.Filename), ".gz"), ".csv") {
+		opts.Format = "csv"
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to open upload")
+		c.JSON(http.StatusInternalServerError, models.ImportResponse{Success: false, Error: "Error importing: " + err.Error()})
+		return
+	}
+	defer file.Close()
+
+	summary, err := h.service(c).Import(c.Request.Context(), file, opts)
+	logger := h.logger.WithFields(logrus.Fields{
+		"file":    header.Filename,
+		"written": summary.Written,
+		"skipped": summary.Skipped,
+		"failed":  summary.Failed,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidImport) {
+			status = http.StatusBadRequest
+		}
+		logger.WithError(err).Error("Failed to import")
+		response := models.ImportResponse{Success: false, Error: "Error importing: " + err.Error()}
+		if summary.Lines > 0 {
+			response.Summary = &summary
+		}
+		c.JSON(status, response)
+		return
+	}
+
+	logger.Info("Import finished")
+	c.JSON(http.StatusOK, models.ImportResponse{
+		Success: true,
+		Summary: &summary,
+		Message: fmt.Sprintf("Imported %d of %d lines (%d skipped, %d failed)", summary.Written, summary.Lines, summary.Skipped, summary.Failed),
+	})
+}
@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"memcached-management/models"
+	"memcached-management/services"
+)
+
+const (
+	defaultMaxListKeys = 10000
+	// streamFlushEvery is how many streamed keys are buffered between flushes
+	streamFlushEvery = 100
+)
+
+// errListLimit stops a key scan once a page is full.
+var errListLimit = errors.New("list limit reached")
+
+// SetMaxListKeys sets the most keys /listKeys returns per page or stream.
+func (h *Handler) SetMaxListKeys(max int) {
+	h.maxListKeys = max
+}
+
+// HandleListKeys returns one page of the keys matching the request's
+// filter, or streams them as NDJSON or server-sent events when the request
+// asks for it. Both modes stop after the limit and return a cursor to
+// continue from.
+func (h *Handler) HandleListKeys(c *gin.Context) {
+	var req models.ListKeysRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.logger.WithError(err).Error("Invalid request data")
+		c.JSON(http.StatusBadRequest, models.ListKeysResponse{Success: false, Error: "Invalid data"})
+		return
+	}
+
+	cursor, err := services.ParseKeyCursor(req.Cursor)
+	if err != nil {
+		h.logger.WithError(err).WithField("cursor", req.Cursor).Warn("Invalid key cursor")
+		c.JSON(http.StatusBadRequest, models.ListKeysResponse{Success: false, Error: "Invalid cursor"})
+		return
+	}
+
+	match, err := services.NewKeyMatcher(req.KeyFilter)
+	if err != nil {
+		h.logger.WithError(err).Warn("Invalid key filter")
+		c.JSON(http.StatusBadRequest, models.ListKeysResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	limit := req.Limit
+	if limit <= 0 || limit > h.maxListKeys {
+		limit = h.maxListKeys
+	}
+
+	switch req.Stream {
+	case "":
+		h.listKeysPage(c, cursor, match, limit)
+	case "ndjson", "sse":
+		h.streamKeys(c, req.Stream, cursor, match, limit)
+	default:
+		c.JSON(http.StatusBadRequest, models.ListKeysResponse{Success: false, Error: "Invalid stream mode: " + req.Stream})
+	}
+}
+
+func (h *Handler) listKeysPage(c *gin.Context, cursor services.KeyCursor, match *services.KeyMatcher, limit int) {
+	now := time.Now().Unix()
+	var items []models.Item
+	var next string
+
+	err := h.service(c).ScanKeys(c.Request.Context(), cursor, match, func(key models.KeyInfo, at services.KeyCursor) error {
+		if len(items) == limit {
+			next = at.String()
+			return errListLimit
+		}
+		items = append(items, newKeyItem(key, now))
+		return nil
+	})
+	if err != nil && !errors.Is(err, errListLimit) {
+		h.logger.WithError(err).Error("Failed to list keys")
+		c.JSON(listKeysErrorStatus(err), models.ListKeysResponse{Success: false, Error: "Error listing keys: " + err.Error()})
+		return
+	}
+
+	h.logger.WithField("count", len(items)).Info("Keys listed successfully")
+	c.JSON(http.StatusOK, models.ListKeysResponse{
+		Success:    true,
+		Items:      items,
+		NextCursor: next,
+		Message:    fmt.Sprintf("Found %d keys", len(items)),
+	})
+}
+
+// streamKeys writes keys as they come off the scanner. Headers are only
+// sent with the first record so errors before any key still get a JSON
+// response; later errors end the stream with a summary carrying the error.
+func (h *Handler) streamKeys(c *gin.Context, mode string, cursor services.KeyCursor, match *services.KeyMatcher, limit int) {
+	ctx := c.Request.Context()
+	now := time.Now().Unix()
+	started := false
+	count := 0
+	var next string
+
+	write := func(event string, line models.KeyStreamLine) {
+		if !started {
+			started = true
+			if mode == "sse" {
+				c.Header("Content-Type", "text/event-stream")
+				c.Header("Cache-Control", "no-cache")
+				c.Header("X-Accel-Buffering", "no")
+			} else {
+				c.Header("Content-Type", "application/x-ndjson")
+			}
+			c.Status(http.StatusOK)
+		}
+
+		data, _ := json.Marshal(line)
+		if mode == "sse" {
+			fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event, data)
+		} else {
+			c.Writer.Write(append(data, '\n'))
+		}
+	}
+
+	err := h.service(c).ScanKeys(ctx, cursor, match, func(key models.KeyInfo, at services.KeyCursor) error {
+		if count == limit {
+			next = at.String()
+			return errListLimit
+		}
+		item := newKeyItem(key, now)
+		write("key", models.KeyStreamLine{Item: &item})
+		count++
+		if count%streamFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+
+	if ctx.Err() != nil {
+		h.logger.WithField("count", count).Info("Key stream cancelled by client")
+		return
+	}
+
+	summary := models.KeyStreamLine{Done: true, Count: count, NextCursor: next}
+	if err != nil && !errors.Is(err, errListLimit) {
+		h.logger.WithError(err).Error("Failed to stream keys")
+		if !started {
+			c.JSON(listKeysErrorStatus(err), models.ListKeysResponse{Success: false, Error: "Error listing keys: " + err.Error()})
+			return
+		}
+		summary.Error = "Error listing keys: " + err.Error()
+	}
+
+	write("done", summary)
+	c.Writer.Flush()
+	h.logger.WithField("count", count).Info("Keys streamed successfully")
+}
+
+// HandleDeleteByPattern previews the keys matching a filter or, with
+// execute set, deletes them. Deletion streams NDJSON lines with the running
+// totals after each batch and ends with the summary.
+func (h *Handler) HandleDeleteByPattern(c *gin.Context) {
+	var req models.DeleteByPatternRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Invalid request data")
+		c.JSON(http.StatusBadRequest, models.DeleteByPatternResponse{Success: false, Error: "Invalid data"})
+		return
+	}
+
+	if req.Prefix == "" && req.Glob == "" && req.Regex == "" {
+		c.JSON(http.StatusBadRequest, models.DeleteByPatternResponse{Success: false, Error: "A prefix, glob or regex is required"})
+		return
+	}
+	match, err := services.NewKeyMatcher(req.KeyFilter)
+	if err != nil {
+		h.logger.WithError(err).Warn("Invalid key filter")
+		c.JSON(http.StatusBadRequest, models.DeleteByPatternResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	service := h.service(c)
+
+	if !req.Execute {
+		preview, err := service.PreviewDelete(ctx, match)
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to preview delete by pattern")
+			c.JSON(http.StatusInternalServerError, models.DeleteByPatternResponse{Success: false, Error: "Error previewing delete: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, models.DeleteByPatternResponse{
+			Success: true,
+			Preview: &preview,
+			Message: fmt.Sprintf("%d keys match", preview.Matched),
+		})
+		return
+	}
+
+	started := false
+	write := func(line models.DeleteProgressLine) {
+		if !started {
+			started = true
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(http.StatusOK)
+		}
+		data, _ := json.Marshal(line)
+		c.Writer.Write(append(data, '\n'))
+		c.Writer.Flush()
+	}
+
+	summary, err := service.DeleteMatching(ctx, match, req.BatchSize, func(progress models.DeleteSummary) {
+		write(models.DeleteProgressLine{DeleteSummary: progress})
+	})
+	if ctx.Err() != nil {
+		h.logger.WithField("deleted", summary.Deleted).Info("Delete by pattern cancelled by client")
+		return
+	}
+
+	final := models.DeleteProgressLine{DeleteSummary: summary, Done: true}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to delete by pattern")
+		if !started {
+			c.JSON(http.StatusInternalServerError, models.DeleteByPatternResponse{Success: false, Error: "Error deleting by pattern: " + err.Error()})
+			return
+		}
+		final.Error = "Error deleting by pattern: " + err.Error()
+	}
+
+	write(final)
+	h.logger.WithFields(logrus.Fields{
+		"deleted": summary.Deleted,
+		"missing": summary.Missing,
+		"failed":  summary.Failed,
+	}).Info("Delete by pattern finished")
+}
+
+// HandleKeyTree returns the children of one keyspace tree node with their
+// key counts and bytes. The UI calls it again for each branch it expands.
+func (h *Handler) HandleKeyTree(c *gin.Context) {
+	var req models.KeyTreeRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.logger.WithError(err).Error("Invalid request data")
+		c.JSON(http.StatusBadRequest, models.KeyTreeResponse{Success: false, Error: "Invalid data"})
+		return
+	}
+
+	node, children, truncated, err := h.service(c).KeyTree(c.Request.Context(), req.Prefix, req.Delimiters)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidFilter) {
+			status = http.StatusBadRequest
+		}
+		h.logger.WithError(err).WithField("prefix", req.Prefix).Error("Failed to build key tree")
+		c.JSON(status, models.KeyTreeResponse{Success: false, Error: "Error building key tree: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.KeyTreeResponse{
+		Success:   true,
+		Node:      &node,
+		Children:  children,
+		Truncated: truncated,
+		Message:   fmt.Sprintf("Found %d keys under %q", node.Count, req.Prefix),
+	})
+}
+
+func listKeysErrorStatus(err error) int {
+	if errors.Is(err, services.ErrInvalidCursor) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// newKeyItem reports a listed key with its metadata in the same relative
+// form /get uses: remaining TTL and seconds since the last access.
+func newKeyItem(key models.KeyInfo, now int64) models.Item {
+	meta := &models.ItemMeta{
+		TTL:        -1,
+		Size:       key.Size,
+		LastAccess: -1,
+		Expiration: key.Expiration,
+		SlabClass:  key.SlabClass,
+		Server:     key.Server,
+	}
+	if key.Expiration > 0 {
+		meta.TTL = max(key.Expiration-now, 0)
+	} else {
+		meta.Expiration = 0
+	}
+	if key.LastAccess > 0 {
+		meta.LastAccess = max(now-key.LastAccess, 0)
+	}
+
+	return models.Item{Key: key.Key, Server: key.Server, Meta: meta}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"memcached-management/models"
+	"memcached-management/services"
+)
+
+// HandleAddKeyWatch registers a key or prefix that is polled for changes.
+// Changes are streamed by GET /keywatches/events and kept as a history.
+func (h *Handler) HandleAddKeyWatch(c *gin.Context) {
+	var req models.KeyWatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Invalid request data")
+		c.JSON(http.StatusBadRequest, models.KeyWatchResponse{Success: false, Error: "Invalid data"})
+		return
+	}
+
+	watch, err := h.service(c).AddKeyWatch(req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to add key watch")
+		c.JSON(keyWatchErrorStatus(err), models.KeyWatchResponse{Success: false, Error: "Error adding watch: " + err.Error()})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"watch":  watch.ID,
+		"key":    watch.Key,
+		"prefix": watch.Prefix,
+	}).Info("Key watch added")
+	c.JSON(http.StatusOK, models.KeyWatchResponse{Success: true, Message: "Watch added", Watches: []models.KeyWatch{watch}})
+}
+
+func (h *Handler) HandleListKeyWatches(c *gin.Context) {
+	c.JSON(http.StatusOK, models.KeyWatchResponse{Success: true, Watches: h.service(c).KeyWatches()})
+}
+
+// HandleGetKeyWatch returns a watch with the recorded versions of its keys.
+func (h *Handler) HandleGetKeyWatch(c *gin.Context) {
+	watch, history, err := h.service(c).KeyWatchHistory(c.Param("id"))
+	if err != nil {
+		c.JSON(keyWatchErrorStatus(err), models.KeyWatchResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.KeyWatchResponse{Success: true, Watches: []models.KeyWatch{watch}, History: history})
+}
+
+func (h *Handler) HandleRemoveKeyWatch(c *gin.Context) {
+	if err := h.service(c).RemoveKeyWatch(c.Param("id")); err != nil {
+		c.JSON(keyWatchErrorStatus(err), models.KeyWatchResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	h.logger.WithField("watch", c.Param("id")).Info("Key watch removed")
+	c.JSON(http.StatusOK, models.KeyWatchResponse{Success: true, Message: "Watch removed"})
+}
+
+// HandleKeyWatchEvents streams the changes of the session's key watches as
+// server-sent "change" events until the client goes away or the connection
+// changes.
+func (h *Handler) HandleKeyWatchEvents(c *gin.Context) {
+	changes, unsubscribe, err := h.service(c).SubscribeKeyChanges()
+	if err != nil {
+		c.JSON(keyWatchErrorStatus(err), models.KeyWatchResponse{Success: false, Error: "Error subscribing to changes: " + err.Error()})
+		return
+	}
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(watchKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(change)
+			fmt.Fprintf(c.Writer, "event: change\ndata: %s\n\n", data)
+			c.Writer.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+func keyWatchErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrKeyWatchNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidKeyWatch):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
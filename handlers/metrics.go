@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"memcached-management/services"
+)
+
+// MetricsMiddleware records the latency and status of every routed request
+// for the /metrics endpoint.
+func (h *Handler) MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		h.metrics.Observe(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// HandleMetrics scrapes every server any session is connected to and
+// exposes the results together with the visualizer's own request metrics.
+func (h *Handler) HandleMetrics(c *gin.Context) {
+	stats := services.CollectStats(h.sessions.ConnectedServers(), scrapeTimeout)
+
+	var buf bytes.Buffer
+	if err := services.WritePrometheus(&buf, stats, h.metrics, h.sessions.Count()); err != nil {
+		h.logger.WithError(err).Error("Failed to write metrics")
+		c.String(http.StatusInternalServerError, "error writing metrics: %v", err)
+		return
+	}
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"memcached-management/models"
+	"memcached-management/services"
+)
+
+// HandleStartMigration starts copying keys between two clusters. The job
+// runs in the background; its status is polled with GET /migrations/:id.
+func (h *Handler) HandleStartMigration(c *gin.Context) {
+	var req models.MigrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Invalid request data")
+		c.JSON(http.StatusBadRequest, models.MigrationResponse{Success: false, Error: "Invalid data"})
+		return
+	}
+
+	source, err := h.endpointProfile("source", req.Source)
+	if err != nil {
+		c.JSON(migrationErrorStatus(err), models.MigrationResponse{Success: false, Error: err.Error()})
+		return
+	}
+	destination, err := h.endpointProfile("destination", req.Destination)
+	if err != nil {
+		c.JSON(migrationErrorStatus(err), models.MigrationResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	status, err := h.migrations.Start(source, destination, req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to start migration")
+		c.JSON(migrationErrorStatus(err), models.MigrationResponse{Success: false, Error: "Error starting migration: " + err.Error()})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"migration":   status.ID,
+		"source":      status.Source,
+		"destination": status.Destination,
+	}).Info("Migration started")
+	c.JSON(http.StatusAccepted, models.MigrationResponse{Success: true, Message: "Migration started", Jobs: []models.MigrationStatus{status}})
+}
+
+func (h *Handler) HandleListMigrations(c *gin.Context) {
+	c.JSON(http.StatusOK, models.MigrationResponse{Success: true, Jobs: h.migrations.List()})
+}
+
+func (h *Handler) HandleGetMigration(c *gin.Context) {
+	status, err := h.migrations.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(migrationErrorStatus(err), models.MigrationResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MigrationResponse{Success: true, Jobs: []models.MigrationStatus{status}})
+}
+
+func (h *Handler) HandleCancelMigration(c *gin.Context) {
+	status, err := h.migrations.Cancel(c.Param("id"))
+	if err != nil {
+		c.JSON(migrationErrorStatus(err), models.MigrationResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	h.logger.WithField("migration", status.ID).Info("Migration cancelled")
+	c.JSON(http.StatusOK, models.MigrationResponse{Success: true, Message: "Migration cancelled", Jobs: []models.MigrationStatus{status}})
+}
+
+// endpointProfile resolves one side of a migration or diff to the profile
+// to connect with: the saved profile it names, or its server list.
+func (h *Handler) endpointProfile(side string, endpoint models.MigrationEndpoint) (models.Profile, error) {
+	switch {
+	case endpoint.Profile != "" && len(endpoint.Servers) > 0:
+		return models.Profile{}, fmt.Errorf("%w: %s takes either servers or a profile", services.ErrInvalidProfile, side)
+	case endpoint.Profile != "":
+		profile, err := h.profiles.Get(endpoint.Profile)
+		if err != nil {
+			return models.Profile{}, fmt.Errorf("%s: %w", side, err)
+		}
+		return profile, nil
+	case len(endpoint.Servers) > 0:
+		return models.Profile{Servers: endpoint.Servers}, nil
+	}
+	return models.Profile{}, fmt.Errorf("%w: %s servers or profile are required", services.ErrInvalidProfile, side)
+}
+
+func migrationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrMigrationNotFound), errors.Is(err, services.ErrProfileNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidMigration), errors.Is(err, services.ErrInvalidProfile):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"memcached-management/models"
+	"memcached-management/services"
+)
+
+func (h *Handler) HandleIncrement(c *gin.Context) {
+	h.handleCounter(c, "increment", func(service *services.MemcachedService, key string, delta uint64) (uint64, error) {
+		return service.Increment(key, delta)
+	})
+}
+
+func (h *Handler) HandleDecrement(c *gin.Context) {
+	h.handleCounter(c, "decrement", func(service *services.MemcachedService, key string, delta uint64) (uint64, error) {
+		return service.Decrement(key, delta)
+	})
+}
+
+func (h *Handler) HandleAdd(c *gin.Context) {
+	h.handleWrite(c, "add", http.StatusConflict, "key already exists", func(service *services.MemcachedService, req models.ItemRequest) error {
+		return service.Add(req.Key, req.Value, req.Flags, req.TTL)
+	})
+}
+
+func (h *Handler) HandleReplace(c *gin.Context) {
+	h.handleWrite(c, "replace", http.StatusNotFound, "key does not exist", func(service *services.MemcachedService, req models.ItemRequest) error {
+		return service.Replace(req.Key, req.Value, req.Flags, req.TTL)
+	})
+}
+
+func (h *Handler) HandleAppend(c *gin.Context) {
+	h.handleWrite(c, "append", http.StatusNotFound, "key does not exist", func(service *services.MemcachedService, req models.ItemRequest) error {
+		return service.Append(req.Key, req.Value)
+	})
+}
+
+func (h *Handler) HandlePrepend(c *gin.Context) {
+	h.handleWrite(c, "prepend", http.StatusNotFound, "key does not exist", func(service *services.MemcachedService, req models.ItemRequest) error {
+		return service.Prepend(req.Key, req.Value)
+	})
+}
+
+func (h *Handler) HandleTouch(c *gin.Context) {
+	h.handleWrite(c, "touch", http.StatusNotFound, "key does not exist", func(service *services.MemcachedService, req models.ItemRequest) error {
+		return service.Touch(req.Key, req.TTL)
+	})
+}
+
+func (h *Handler) handleCounter(c *gin.Context, op string, fn func(*services.MemcachedService, string, uint64) (uint64, error)) {
+	var req models.ItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Invalid request data")
+		c.JSON(http.StatusBadRequest, models.ItemResponse{Success: false, Error: "Invalid data"})
+		return
+	}
+
+	delta := req.Delta
+	if delta == 0 {
+		delta = 1
+	}
+
+	value, err := fn(h.service(c), req.Key, delta)
+	if err != nil {
+		h.logger.WithError(err).WithField("key", req.Key).Errorf("Failed to %s item", op)
+		c.JSON(operationErrorStatus(err, http.StatusNotFound), models.ItemResponse{Success: false, Error: "Error on " + op + ": " + err.Error()})
+		return
+	}
+
+	newValue := strconv.FormatUint(value, 10)
+	h.logger.WithField("key", req.Key).Infof("Item %s successful", op)
+	c.JSON(http.StatusOK, models.ItemResponse{
+		Success: true,
+		Message: "New value: " + newValue,
+		Items:   []models.Item{{Key: req.Key, Value: newValue}},
+	})
+}
+
+// handleWrite runs a conditional write. notStoredStatus and notStoredReason
+// describe what ErrNotStored means for this operation.
+func (h *Handler) handleWrite(c *gin.Context, op string, notStoredStatus int, notStoredReason string, fn func(*services.MemcachedService, models.ItemRequest) error) {
+	var req models.ItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Invalid request data")
+		c.JSON(http.StatusBadRequest, models.ItemResponse{Success: false, Error: "Invalid data"})
+		return
+	}
+	if err := decodeRequestValue(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ItemResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	if err := fn(h.service(c), req); err != nil {
+		h.logger.WithError(err).WithField("key", req.Key).Errorf("Failed to %s item", op)
+		message := err.Error()
+		if errors.Is(err, services.ErrNotStored) || errors.Is(err, services.ErrCacheMiss) {
+			message = notStoredReason
+		}
+		c.JSON(operationErrorStatus(err, notStoredStatus), models.ItemResponse{Success: false, Error: "Error on " + op + ": " + message})
+		return
+	}
+
+	h.logger.WithField("key", req.Key).Infof("Item %s successful", op)
+	c.JSON(http.StatusOK, models.ItemResponse{Success: true, Message: "Item " + op + " successful!"})
+}
+
+func operationErrorStatus(err error, notStoredStatus int) int {
+	switch {
+	case errors.Is(err, services.ErrCacheMiss):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrNotStored):
+		return notStoredStatus
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"memcached-management/models"
+	"memcached-management/services"
+)
+
+func (h *Handler) HandleListProfiles(c *gin.Context) {
+	c.JSON(http.StatusOK, models.ProfileResponse{Success: true, Profiles: h.profiles.List()})
+}
+
+func (h *Handler) HandleGetProfile(c *gin.Context) {
+	profile, err := h.profiles.Get(c.Param("name"))
+	if err != nil {
+		c.JSON(profileErrorStatus(err), models.ProfileResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ProfileResponse{Success: true, Profiles: []models.Profile{profile}})
+}
+
+func (h *Handler) HandleCreateProfile(c *gin.Context) {
+	var req models.Profile
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Invalid request data")
+		c.JSON(http.StatusBadRequest, models.ProfileResponse{Success: false, Error: "Invalid data"})
+		return
+	}
+
+	if err := h.profiles.Create(req); err != nil {
+		h.logger.WithError(err).WithField("profile", req.Name).Error("Failed to create profile")
+		c.JSON(profileErrorStatus(err), models.ProfileResponse{Success: false, Error: "Error saving profile: " + err.Error()})
+		return
+	}
+
+	profile, _ := h.profiles.Get(req.Name)
+	h.logger.WithField("profile", req.Name).Info("Profile created successfully")
+	c.JSON(http.StatusCreated, models.ProfileResponse{Success: true, Message: "Profile saved successfully!", Profiles: []models.Profile{profile}})
+}
+
+func (h *Handler) HandleUpdateProfile(c *gin.Context) {
+	var req models.Profile
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Invalid request data")
+		c.JSON(http.StatusBadRequest, models.ProfileResponse{Success: false, Error: "Invalid data"})
+		return
+	}
+
+	name := c.Param("name")
+	if err := h.profiles.Update(name, req); err != nil {
+		h.logger.WithError(err).WithField("profile", name).Error("Failed to update profile")
+		c.JSON(profileErrorStatus(err), models.ProfileResponse{Success: false, Error: "Error saving profile: " + err.Error()})
+		return
+	}
+
+	if req.Name == "" {
+		req.Name = name
+	}
+	profile, _ := h.profiles.Get(req.Name)
+	h.logger.WithField("profile", req.Name).Info("Profile updated successfully")
+	c.JSON(http.StatusOK, models.ProfileResponse{Success: true, Message: "Profile saved successfully!", Profiles: []models.Profile{profile}})
+}
+
+func (h *Handler) HandleDeleteProfile(c *gin.Context) {
+	name := c.Param("name")
+	if err := h.profiles.Delete(name); err != nil {
+		h.logger.WithError(err).WithField("profile", name).Error("Failed to delete profile")
+		c.JSON(profileErrorStatus(err), models.ProfileResponse{Success: false, Error: "Error deleting profile: " + err.Error()})
+		return
+	}
+
+	h.logger.WithField("profile", name).Info("Profile deleted successfully")
+	c.JSON(http.StatusOK, models.ProfileResponse{Success: true, Message: "Profile deleted successfully!"})
+}
+
+func profileErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrProfileNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrProfileExists):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrInvalidProfile):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
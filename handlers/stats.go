@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"memcached-management/models"
+)
+
+func (h *Handler) HandleStats(c *gin.Context) {
+	stats, err := h.service(c).Stats()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get stats")
+		c.JSON(http.StatusInternalServerError, models.StatsResponse{Success: false, Error: "Error getting stats: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.StatsResponse{Success: true, Stats: stats})
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"memcached-management/models"
+)
+
+// watchKeepAlive is how often an idle watch stream sends a comment line, so
+// proxies do not drop the connection.
+const watchKeepAlive = 15 * time.Second
+
+// HandleWatch streams the live fetches, mutations and evictions of the
+// connected servers as server-sent "watch" events. The prefix query
+// parameter limits events to one key namespace and types to a comma
+// separated list of event types; skipped line reports always pass. The
+// watch connections are closed when the client goes away.
+func (h *Handler) HandleWatch(c *gin.Context) {
+	prefix := c.Query("prefix")
+	var types map[string]bool
+	if list := c.Query("types"); list != "" {
+		types = make(map[string]bool)
+		for _, t := range strings.Split(list, ",") {
+			types[strings.TrimSpace(t)] = true
+		}
+	}
+
+	stream, err := h.service(c).Watch(c.Request.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to start watch")
+		c.JSON(http.StatusInternalServerError, models.ItemResponse{Success: false, Error: "Error starting watch: " + err.Error()})
+		return
+	}
+	defer stream.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+	h.logger.WithField("prefix", prefix).Info("Watch started")
+
+	keepAlive := time.NewTicker(watchKeepAlive)
+	defer keepAlive.Stop()
+	count := 0
+	for {
+		select {
+		case event, ok := <-stream.Events():
+			if !ok {
+				h.finishWatch(c, stream.Err(), count)
+				return
+			}
+			if event.Type != models.WatchSkipped && (!strings.HasPrefix(event.Key, prefix) || (types != nil && !types[event.Type])) {
+				continue
+			}
+			data, _ := json.Marshal(event)
+			fmt.Fprintf(c.Writer, "event: watch\ndata: %s\n\n", data)
+			c.Writer.Flush()
+			count++
+		case <-keepAlive.C:
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+// finishWatch ends a stream that stopped on its own or because the client
+// left; a server failure is sent as a final "error" event.
+func (h *Handler) finishWatch(c *gin.Context, err error, count int) {
+	logger := h.logger.WithField("events", count)
+	if err == nil || c.Request.Context().Err() != nil {
+		logger.Info("Watch stopped")
+		return
+	}
+
+	logger.WithError(err).Error("Watch failed")
+	data, _ := json.Marshal(models.ItemResponse{Success: false, Error: "Watch failed: " + err.Error()})
+	fmt.Fprintf(c.Writer, "event: error\ndata: %s\n\n", data)
+	c.Writer.Flush()
+}
@@ -0,0 +1,29 @@
+package models
+
+// DecoderRule picks the decoder for keys starting with Prefix. The longest
+// matching prefix wins; keys no rule matches are sniffed.
+type DecoderRule struct {
+	Prefix string `json:"prefix"`
+	// Decoder is a decoder name, or "none" to show the values raw
+	Decoder string `json:"decoder"`
+	// Message is the protobuf message type, e.g. "acme.v1.User", of a
+	// "protobuf" rule. Without it, or when the uploaded descriptors do not
+	// define it, values are shown in the raw wire format.
+	Message string `json:"message,omitempty"`
+}
+
+type DecoderRulesRequest struct {
+	Rules []DecoderRule `json:"rules"`
+}
+
+type DecodersResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Decoders are the registered decoder names, in the order they are tried
+	Decoders []string      `json:"decoders,omitempty"`
+	Rules    []DecoderRule `json:"rules"`
+	// ProtoMessages are the message types of the uploaded protobuf
+	// descriptors
+	ProtoMessages []string `json:"proto_messages,omitempty"`
+}
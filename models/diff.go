@@ -0,0 +1,62 @@
+package models
+
+// DiffRequest compares the keys of two clusters, each given as a server
+// list or a saved profile.
+type DiffRequest struct {
+	A MigrationEndpoint `json:"a"`
+	B MigrationEndpoint `json:"b"`
+	// Prefix limits the comparison to one namespace
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// DiffSide describes a key's value on one side of a diff. Hash is the
+// SHA-256 of the value in hex.
+type DiffSide struct {
+	Size  int    `json:"size"`
+	Hash  string `json:"hash"`
+	Flags uint32 `json:"flags,omitempty"`
+}
+
+// DiffEntry is a key that only exists on one side.
+type DiffEntry struct {
+	Key string `json:"key"`
+	DiffSide
+}
+
+// DiffMismatch is a key whose value or flags differ between the sides.
+type DiffMismatch struct {
+	Key string   `json:"key"`
+	A   DiffSide `json:"a"`
+	B   DiffSide `json:"b"`
+}
+
+// DiffSummary counts keys by outcome. The lists of a DiffReport are capped,
+// these counts are not.
+type DiffSummary struct {
+	KeysA      int `json:"keys_a"`
+	KeysB      int `json:"keys_b"`
+	Identical  int `json:"identical"`
+	OnlyInA    int `json:"only_in_a"`
+	OnlyInB    int `json:"only_in_b"`
+	Mismatched int `json:"mismatched"`
+}
+
+type DiffReport struct {
+	// A and B name the compared sides: server lists or file names
+	A          string         `json:"a"`
+	B          string         `json:"b"`
+	Prefix     string         `json:"prefix,omitempty"`
+	Summary    DiffSummary    `json:"summary"`
+	OnlyInA    []DiffEntry    `json:"only_in_a"`
+	OnlyInB    []DiffEntry    `json:"only_in_b"`
+	Mismatched []DiffMismatch `json:"mismatched"`
+	// Truncated is set when a list holds fewer entries than its count
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+type DiffResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Report  *DiffReport `json:"report,omitempty"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// HotKeyRequest starts a hot key analysis. Zero values take the defaults.
+type HotKeyRequest struct {
+	// WindowSeconds is the sliding window rates are computed over
+	WindowSeconds int `json:"window_seconds,omitempty"`
+	// TopK is how many keys and prefixes are reported
+	TopK int `json:"top_k,omitempty"`
+	// SampleRate is the fraction of events counted, between 0 and 1; counts
+	// are scaled back up
+	SampleRate float64 `json:"sample_rate,omitempty"`
+	// Prefix limits the analysis to one key namespace
+	Prefix string `json:"prefix,omitempty"`
+	// Delimiters end the first key segment, which is the key's prefix
+	Delimiters string `json:"delimiters,omitempty"`
+}
+
+// HotKey is a key or prefix with its estimated request count in the window.
+// Counts come from a Space-Saving sketch, which may overestimate: the true
+// count is between Count-Error and Count.
+type HotKey struct {
+	Key       string  `json:"key"`
+	Count     int64   `json:"count"`
+	Error     int64   `json:"error"`
+	Rate      float64 `json:"rate"`
+	Fetches   int64   `json:"fetches"`
+	Misses    int64   `json:"misses"`
+	Mutations int64   `json:"mutations"`
+}
+
+type HotKeyReport struct {
+	Running       bool      `json:"running"`
+	StartedAt     time.Time `json:"started_at"`
+	WindowSeconds int       `json:"window_seconds"`
+	TopK          int       `json:"top_k"`
+	SampleRate    float64   `json:"sample_rate"`
+	Prefix        string    `json:"prefix,omitempty"`
+	// Events are the fetches and mutations seen since the start; Skipped
+	// counts log lines the servers dropped because the analyzer fell behind
+	Events   int64    `json:"events"`
+	Skipped  int64    `json:"skipped"`
+	Keys     []HotKey `json:"keys"`
+	Prefixes []HotKey `json:"prefixes"`
+	Error    string   `json:"error,omitempty"`
+}
+
+type HotKeyResponse struct {
+	Success bool          `json:"success"`
+	Message string        `json:"message,omitempty"`
+	Error   string        `json:"error,omitempty"`
+	Report  *HotKeyReport `json:"report,omitempty"`
+}
@@ -0,0 +1,176 @@
+package models
+
+// ListKeysRequest pages through or streams the keys of every server.
+type ListKeysRequest struct {
+	// Cursor resumes a listing at the next_cursor of a previous response
+	Cursor string `json:"cursor,omitempty"`
+	// Limit caps the number of keys returned; the server maximum applies
+	// when it is 0 or larger
+	Limit int `json:"limit,omitempty"`
+	// Stream is "ndjson" or "sse" to stream keys as they are scanned
+	// instead of returning one JSON page
+	Stream string `json:"stream,omitempty"`
+	KeyFilter
+}
+
+type ListKeysResponse struct {
+	Success    bool   `json:"success"`
+	Message    string `json:"message,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Items      []Item `json:"items,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// KeyStreamLine is one record of a streamed key listing: a key, or the
+// final summary with Done set.
+type KeyStreamLine struct {
+	Item       *Item  `json:"item,omitempty"`
+	Done       bool   `json:"done,omitempty"`
+	Count      int    `json:"count,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// KeyFilter selects keys while they are scanned. Zero fields are ignored;
+// Glob and Regex are mutually exclusive.
+type KeyFilter struct {
+	Prefix string `json:"prefix,omitempty"`
+	// Glob matches the whole key, e.g. "session:*"
+	Glob  string `json:"glob,omitempty"`
+	Regex string `json:"regex,omitempty"`
+	// MinSize and MaxSize bound the item size in bytes
+	MinSize int `json:"min_size,omitempty"`
+	MaxSize int `json:"max_size,omitempty"`
+	// MinTTL and MaxTTL bound the remaining TTL in seconds; keys that never
+	// expire only pass a MinTTL
+	MinTTL int64 `json:"min_ttl,omitempty"`
+	MaxTTL int64 `json:"max_ttl,omitempty"`
+}
+
+// DeleteByPatternRequest deletes the keys matching a filter. Requests are
+// dry runs that only preview the matches unless Execute is set.
+type DeleteByPatternRequest struct {
+	KeyFilter
+	Execute bool `json:"execute,omitempty"`
+	// BatchSize is the number of keys deleted per batch (defaults to 100)
+	BatchSize int `json:"batch_size,omitempty"`
+}
+
+// DeletePreview describes the keys a delete by pattern would remove.
+type DeletePreview struct {
+	Matched int            `json:"matched"`
+	Bytes   int64          `json:"bytes"`
+	Servers map[string]int `json:"servers,omitempty"`
+	Sample  []string       `json:"sample,omitempty"`
+}
+
+// DeleteSummary counts the outcome of a delete by pattern. Errors holds a
+// sample of the failures.
+type DeleteSummary struct {
+	Matched int      `json:"matched"`
+	Deleted int      `json:"deleted"`
+	Missing int      `json:"missing"`
+	Failed  int      `json:"failed"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+type DeleteByPatternResponse struct {
+	Success bool           `json:"success"`
+	Message string         `json:"message,omitempty"`
+	Error   string         `json:"error,omitempty"`
+	Preview *DeletePreview `json:"preview,omitempty"`
+}
+
+// DeleteProgressLine is one NDJSON line of a running delete by pattern:
+// the totals so far, and finally the summary with Done set.
+type DeleteProgressLine struct {
+	DeleteSummary
+	Done  bool   `json:"done,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// KeyTreeRequest expands one node of the keyspace tree.
+type KeyTreeRequest struct {
+	// Prefix is the node to expand, as returned in a child's prefix; empty
+	// for the root
+	Prefix string `json:"prefix,omitempty"`
+	// Delimiters are the characters separating namespace levels, ":" by
+	// default
+	Delimiters string `json:"delimiters,omitempty"`
+}
+
+// KeyTreeNode is a namespace, or a single key when Leaf is set, with the
+// number of keys and bytes below it.
+type KeyTreeNode struct {
+	Name string `json:"name"`
+	// Prefix expands a namespace; for leaves it is the whole key
+	Prefix string `json:"prefix"`
+	Count  int    `json:"count"`
+	Bytes  int64  `json:"bytes"`
+	Leaf   bool   `json:"leaf,omitempty"`
+}
+
+type KeyTreeResponse struct {
+	Success  bool          `json:"success"`
+	Message  string        `json:"message,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Node     *KeyTreeNode  `json:"node,omitempty"`
+	Children []KeyTreeNode `json:"children,omitempty"`
+	// Truncated is how many smaller children were left out
+	Truncated int `json:"truncated,omitempty"`
+}
+
+// DumpRecord is one item of an export file, written as a JSON line.
+type DumpRecord struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// Encoding is "base64" when the value is not valid UTF-8
+	Encoding string `json:"encoding,omitempty"`
+	Flags    uint32 `json:"flags,omitempty"`
+	// TTL is the remaining time to live at export time, -1 if the item
+	// never expires
+	TTL int64 `json:"ttl"`
+	// ExpiresAt is the unix time the item expires at, 0 if it never does
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+	Server    string `json:"server,omitempty"`
+}
+
+// ImportOptions controls how /import writes a dump. They are sent as form
+// fields next to the uploaded file.
+type ImportOptions struct {
+	// Format is "jsonl" or "csv" (key,value,ttl); guessed from the file name
+	// when empty
+	Format string `form:"format" json:"format,omitempty"`
+	// Mode is "overwrite" (default), "add-only", which reports existing keys
+	// as errors, or "skip-existing", which leaves them alone
+	Mode string `form:"mode" json:"mode,omitempty"`
+	// TTL is "preserve" (default) to keep each item's remaining TTL, or
+	// seconds with memcached semantics applied to every item
+	TTL string `form:"ttl" json:"ttl,omitempty"`
+	// Concurrency is the number of parallel writers (defaults to 8)
+	Concurrency int `form:"concurrency" json:"concurrency,omitempty"`
+}
+
+// ImportLineError reports why one line of an import was not written.
+type ImportLineError struct {
+	Line  int    `json:"line"`
+	Key   string `json:"key,omitempty"`
+	Error string `json:"error"`
+}
+
+type ImportSummary struct {
+	Lines   int `json:"lines"`
+	Written int `json:"written"`
+	// Skipped counts existing keys in skip-existing mode and items whose
+	// preserved TTL ran out
+	Skipped int               `json:"skipped"`
+	Failed  int               `json:"failed"`
+	Errors  []ImportLineError `json:"errors,omitempty"`
+}
+
+type ImportResponse struct {
+	Success bool           `json:"success"`
+	Message string         `json:"message,omitempty"`
+	Error   string         `json:"error,omitempty"`
+	Summary *ImportSummary `json:"summary,omitempty"`
+}
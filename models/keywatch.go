@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// Key change events. Observed is the first sighting of a key that already
+// existed when its watch started.
+const (
+	KeyObserved    = "observed"
+	KeyCreated     = "created"
+	KeyModified    = "modified"
+	KeyDisappeared = "disappeared"
+)
+
+// KeyWatchRequest registers a watch on a single key or on every key under a
+// prefix. Exactly one of Key and Prefix is set.
+type KeyWatchRequest struct {
+	Key    string `json:"key,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	// IntervalSeconds is how often the keys are polled (defaults to 5)
+	IntervalSeconds int `json:"interval_seconds,omitempty"`
+}
+
+type KeyWatch struct {
+	ID              string    `json:"id"`
+	Key             string    `json:"key,omitempty"`
+	Prefix          string    `json:"prefix,omitempty"`
+	IntervalSeconds int       `json:"interval_seconds"`
+	CreatedAt       time.Time `json:"created_at"`
+	// Keys is how many keys the watch tracks; Truncated is set when a
+	// prefix matched more keys than a watch keeps
+	Keys      int        `json:"keys"`
+	Truncated bool       `json:"truncated,omitempty"`
+	Changes   int        `json:"changes"`
+	LastPoll  *time.Time `json:"last_poll,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// KeyVersion is one recorded state of a key. Meta and the value are unset
+// for disappeared keys; Meta is also unset on servers without the meta
+// protocol.
+type KeyVersion struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`
+	Meta     *ItemMeta `json:"meta,omitempty"`
+	Value    string    `json:"value,omitempty"`
+	Encoding string    `json:"encoding,omitempty"`
+	// Truncated is set when only the start of a large value was kept
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// KeyHistory is the recorded versions of a key, oldest first.
+type KeyHistory struct {
+	Key      string       `json:"key"`
+	Versions []KeyVersion `json:"versions"`
+}
+
+// KeyChange notifies a new version of a watched key.
+type KeyChange struct {
+	WatchID string `json:"watch_id"`
+	Key     string `json:"key"`
+	KeyVersion
+}
+
+type KeyWatchResponse struct {
+	Success bool         `json:"success"`
+	Message string       `json:"message,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Watches []KeyWatch   `json:"watches,omitempty"`
+	History []KeyHistory `json:"history,omitempty"`
+}
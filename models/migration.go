@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// MigrationEndpoint names one side of a migration: either a server list or
+// a saved profile.
+type MigrationEndpoint struct {
+	Servers []string `json:"servers,omitempty"`
+	Profile string   `json:"profile,omitempty"`
+}
+
+// MigrationRequest starts a job copying keys from Source to Destination.
+type MigrationRequest struct {
+	Source      MigrationEndpoint `json:"source"`
+	Destination MigrationEndpoint `json:"destination"`
+	// Prefix limits the copy to one namespace
+	Prefix string `json:"prefix,omitempty"`
+	// Mode is "overwrite" (default) or "skip-existing"
+	Mode string `json:"mode,omitempty"`
+	// Concurrency is the number of parallel writers (defaults to 8)
+	Concurrency int `json:"concurrency,omitempty"`
+}
+
+// MigrationStatus is a snapshot of a migration job. State is "running",
+// "completed", "failed" or "cancelled".
+type MigrationStatus struct {
+	ID          string     `json:"id"`
+	State       string     `json:"state"`
+	Source      []string   `json:"source"`
+	Destination []string   `json:"destination"`
+	Prefix      string     `json:"prefix,omitempty"`
+	Copied      int        `json:"copied"`
+	Skipped     int        `json:"skipped"`
+	Failed      int        `json:"failed"`
+	Bytes       int64      `json:"bytes"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	// Elapsed and the rates are computed when the snapshot is taken
+	ElapsedSeconds float64  `json:"elapsed_seconds"`
+	KeysPerSecond  float64  `json:"keys_per_second"`
+	BytesPerSecond float64  `json:"bytes_per_second"`
+	Error          string   `json:"error,omitempty"`
+	Errors         []string `json:"errors,omitempty"`
+}
+
+type MigrationResponse struct {
+	Success bool              `json:"success"`
+	Message string            `json:"message,omitempty"`
+	Error   string            `json:"error,omitempty"`
+	Jobs    []MigrationStatus `json:"jobs,omitempty"`
+}
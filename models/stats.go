@@ -0,0 +1,98 @@
+package models
+
+// ServerStats is the parsed output of stats, stats settings, stats slabs,
+// stats items and stats conns for a single server.
+type ServerStats struct {
+	Server        string            `json:"server"`
+	Error         string            `json:"error,omitempty"`
+	General       GeneralStats      `json:"general"`
+	Settings      map[string]string `json:"settings,omitempty"`
+	Slabs         []SlabStats       `json:"slabs,omitempty"`
+	ActiveSlabs   uint64            `json:"active_slabs"`
+	TotalMalloced uint64            `json:"total_malloced"`
+	Items         []ItemStats       `json:"items,omitempty"`
+	Conns         []ConnStats       `json:"conns,omitempty"`
+}
+
+// GeneralStats holds the most useful counters of the plain stats command.
+// Raw keeps every STAT line, including ones without a typed field.
+type GeneralStats struct {
+	PID              uint64            `json:"pid"`
+	Version          string            `json:"version"`
+	Uptime           uint64            `json:"uptime"`
+	Time             uint64            `json:"time"`
+	Threads          uint64            `json:"threads"`
+	CurrConnections  uint64            `json:"curr_connections"`
+	TotalConnections uint64            `json:"total_connections"`
+	RejectedConns    uint64            `json:"rejected_connections"`
+	CmdGet           uint64            `json:"cmd_get"`
+	CmdSet           uint64            `json:"cmd_set"`
+	CmdFlush         uint64            `json:"cmd_flush"`
+	CmdTouch         uint64            `json:"cmd_touch"`
+	GetHits          uint64            `json:"get_hits"`
+	GetMisses        uint64            `json:"get_misses"`
+	GetExpired       uint64            `json:"get_expired"`
+	DeleteHits       uint64            `json:"delete_hits"`
+	DeleteMisses     uint64            `json:"delete_misses"`
+	IncrHits         uint64            `json:"incr_hits"`
+	IncrMisses       uint64            `json:"incr_misses"`
+	DecrHits         uint64            `json:"decr_hits"`
+	DecrMisses       uint64            `json:"decr_misses"`
+	CasHits          uint64            `json:"cas_hits"`
+	CasMisses        uint64            `json:"cas_misses"`
+	CasBadval        uint64            `json:"cas_badval"`
+	BytesRead        uint64            `json:"bytes_read"`
+	BytesWritten     uint64            `json:"bytes_written"`
+	LimitMaxBytes    uint64            `json:"limit_maxbytes"`
+	Bytes            uint64            `json:"bytes"`
+	CurrItems        uint64            `json:"curr_items"`
+	TotalItems       uint64            `json:"total_items"`
+	Evictions        uint64            `json:"evictions"`
+	Reclaimed        uint64            `json:"reclaimed"`
+	Expired          uint64            `json:"expired_unfetched"`
+	HitRatio         float64           `json:"hit_ratio"`
+	Raw              map[string]string `json:"raw,omitempty"`
+}
+
+// SlabStats describes one slab class from stats slabs.
+type SlabStats struct {
+	ID            int    `json:"id"`
+	ChunkSize     uint64 `json:"chunk_size"`
+	ChunksPerPage uint64 `json:"chunks_per_page"`
+	TotalPages    uint64 `json:"total_pages"`
+	TotalChunks   uint64 `json:"total_chunks"`
+	UsedChunks    uint64 `json:"used_chunks"`
+	FreeChunks    uint64 `json:"free_chunks"`
+	MemRequested  uint64 `json:"mem_requested"`
+	GetHits       uint64 `json:"get_hits"`
+	CmdSet        uint64 `json:"cmd_set"`
+	DeleteHits    uint64 `json:"delete_hits"`
+}
+
+// ItemStats describes the items held by one slab class from stats items.
+type ItemStats struct {
+	SlabID         int    `json:"slab_id"`
+	Number         uint64 `json:"number"`
+	Age            uint64 `json:"age"`
+	Evicted        uint64 `json:"evicted"`
+	EvictedNonzero uint64 `json:"evicted_nonzero"`
+	EvictedTime    uint64 `json:"evicted_time"`
+	OutOfMemory    uint64 `json:"outofmemory"`
+	Reclaimed      uint64 `json:"reclaimed"`
+	ExpiredUnfetch uint64 `json:"expired_unfetched"`
+}
+
+// ConnStats describes one open connection from stats conns.
+type ConnStats struct {
+	FD               int    `json:"fd"`
+	Addr             string `json:"addr,omitempty"`
+	ListenAddr       string `json:"listen_addr,omitempty"`
+	State            string `json:"state,omitempty"`
+	SecsSinceLastCmd uint64 `json:"secs_since_last_cmd"`
+}
+
+type StatsResponse struct {
+	Success bool          `json:"success"`
+	Error   string        `json:"error,omitempty"`
+	Stats   []ServerStats `json:"stats,omitempty"`
+}
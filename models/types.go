@@ -1,19 +1,52 @@
 package models
 
+import "encoding/json"
+
 type ConnectRequest struct {
-	URL string `json:"url"`
+	URL     string   `json:"url"`
+	Servers []string `json:"servers,omitempty"`
+	Profile string   `json:"profile,omitempty"`
 }
 
 type ConnectResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Success  bool     `json:"success"`
+	Message  string   `json:"message,omitempty"`
+	Error    string   `json:"error,omitempty"`
+	Servers  []string `json:"servers,omitempty"`
+	Profile  string   `json:"profile,omitempty"`
+	ReadOnly bool     `json:"read_only,omitempty"`
+}
+
+// Profile is a named connection persisted by the profile store.
+type Profile struct {
+	Name      string   `json:"name"`
+	Servers   []string `json:"servers"`
+	TimeoutMS int      `json:"timeout_ms,omitempty"`
+	ReadOnly  bool     `json:"read_only,omitempty"`
+}
+
+type ProfileResponse struct {
+	Success  bool      `json:"success"`
+	Message  string    `json:"message,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Profiles []Profile `json:"profiles,omitempty"`
 }
 
 type ItemRequest struct {
 	Key   string   `json:"key"`
 	Keys  []string `json:"keys"`
 	Value string   `json:"value"`
+	// Encoding is "base64" when Value carries binary data
+	Encoding string `json:"encoding,omitempty"`
+	// TTL uses memcached semantics: 0 never expires, up to 30 days is
+	// relative seconds, anything larger is an absolute unix timestamp.
+	TTL   int64  `json:"ttl,omitempty"`
+	Flags uint32 `json:"flags,omitempty"`
+	// CAS, when set, makes /set a compare-and-swap against the CAS id the
+	// value was read with.
+	CAS uint64 `json:"cas,omitempty"`
+	// Delta is the amount for /incr and /decr (defaults to 1).
+	Delta uint64 `json:"delta,omitempty"`
 }
 
 type ItemResponse struct {
@@ -26,4 +59,62 @@ type ItemResponse struct {
 type Item struct {
 	Key   string `json:"key"`
 	Value string `json:"value"`
-}
\ No newline at end of file
+	// Encoding is "base64" when the value is not valid UTF-8
+	Encoding string    `json:"encoding,omitempty"`
+	Flags    uint32    `json:"flags,omitempty"`
+	CAS      uint64    `json:"cas,omitempty"`
+	Server   string    `json:"server,omitempty"`
+	Meta     *ItemMeta `json:"meta,omitempty"`
+	// Decoded is the value after automatic decoding, when a known format
+	// such as gzip or msgpack was recognized
+	Decoded *DecodedValue `json:"decoded,omitempty"`
+}
+
+// DecodedValue is a readable view of an item value.
+type DecodedValue struct {
+	// Steps are the decoders applied in order, e.g. ["gzip", "json"]
+	Steps []string `json:"steps"`
+	Value string   `json:"value"`
+	// Encoding is "base64" when the decoded value is still not valid UTF-8
+	Encoding string `json:"encoding,omitempty"`
+	Size     int    `json:"size"`
+	// Tree is the decoded value as a JSON document when the last decoder
+	// produced one (JSON, msgpack, PHP, pickle, Java)
+	Tree json.RawMessage `json:"tree,omitempty"`
+	// Error is set when a decoder forced by a prefix rule fails, or when a
+	// protobuf value is shown in wire format because the rule's message
+	// type is unknown or does not match it
+	Error string `json:"error,omitempty"`
+	// Message is the protobuf message type the value was decoded as
+	Message string `json:"message,omitempty"`
+}
+
+// ItemMeta is the item metadata reported by the meta protocol.
+type ItemMeta struct {
+	// TTL is the remaining time to live in seconds, -1 if it never expires
+	TTL   int64  `json:"ttl"`
+	Size  int    `json:"size"`
+	Flags uint32 `json:"flags"`
+	CAS   uint64 `json:"cas"`
+	// LastAccess is the number of seconds since the item was last accessed,
+	// -1 when the server did not report it
+	LastAccess int64 `json:"last_access"`
+	// Expiration is the absolute unix time the item expires at, set by key
+	// listings
+	Expiration int64  `json:"expiration,omitempty"`
+	SlabClass  int    `json:"slab_class,omitempty"`
+	Server     string `json:"server,omitempty"`
+}
+
+// KeyInfo describes a key found while enumerating a server's slabs.
+// Times are unix timestamps on the server's clock.
+type KeyInfo struct {
+	Key    string `json:"key"`
+	Server string `json:"server"`
+	// Expiration is -1 for keys that never expire
+	Expiration int64 `json:"expiration"`
+	// LastAccess is 0 when the server did not report it (stats cachedump)
+	LastAccess int64 `json:"last_access,omitempty"`
+	Size       int   `json:"size"`
+	SlabClass  int   `json:"slab_class"`
+}
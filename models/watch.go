@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Watch event types. Lines with other memcached log types keep the type the
+// server sent.
+const (
+	WatchFetch    = "fetch"
+	WatchStore    = "store"
+	WatchDelete   = "delete"
+	WatchEviction = "eviction"
+	// WatchSkipped reports log lines the server dropped because the
+	// watcher fell behind
+	WatchSkipped = "skipped"
+)
+
+// WatchEvent is one line of a memcached `watch` log stream.
+type WatchEvent struct {
+	Server string    `json:"server"`
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	Key    string    `json:"key,omitempty"`
+	// Status is the outcome, e.g. found or not_found for fetches and
+	// stored, exists or not_stored for stores
+	Status string `json:"status,omitempty"`
+	// Command is the command behind a store or delete, e.g. set or incr
+	Command   string `json:"command,omitempty"`
+	TTL       *int64 `json:"ttl,omitempty"`
+	Size      int    `json:"size,omitempty"`
+	SlabClass int    `json:"slab_class,omitempty"`
+	// Fetched tells whether an evicted item was ever read
+	Fetched *bool `json:"fetched,omitempty"`
+	// LastAccess is the seconds since an evicted item was last accessed
+	LastAccess *int64 `json:"last_access,omitempty"`
+	Skipped    int    `json:"skipped,omitempty"`
+	// Fields holds the attributes of the line not mapped above
+	Fields map[string]string `json:"fields,omitempty"`
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"memcached-management/models"
+)
+
+const (
+	defaultDeleteBatch = 100
+	// deleteWorkers is how many batches are deleted in parallel
+	deleteWorkers = 8
+	// maxDeleteErrors caps the error samples kept in a delete summary
+	maxDeleteErrors = 20
+	previewSample   = 20
+)
+
+// PreviewDelete scans the keys accepted by match without deleting them and
+// reports how many there are, their total size, how they spread across
+// servers and a sample of them.
+func (s *MemcachedService) PreviewDelete(ctx context.Context, match *KeyMatcher) (models.DeletePreview, error) {
+	preview := models.DeletePreview{Servers: make(map[string]int)}
+
+	st, err := s.state()
+	if err != nil {
+		return preview, err
+	}
+	if match == nil {
+		return preview, fmt.Errorf("%w: a key filter is required", ErrInvalidFilter)
+	}
+
+	err = st.scanKeys(ctx, KeyCursor{}, match, func(key models.KeyInfo, _ KeyCursor) error {
+		preview.Matched++
+		preview.Bytes += int64(key.Size)
+		preview.Servers[key.Server]++
+		if len(preview.Sample) < previewSample {
+			preview.Sample = append(preview.Sample, key.Key)
+		}
+		return nil
+	})
+
+	return preview, err
+}
+
+// DeleteMatching deletes every key accepted by match. Keys are deleted in
+// batches of batchSize while the scan is still running, several batches at
+// a time, and progress is called with the running totals after each batch.
+// Calls to progress never overlap.
+func (s *MemcachedService) DeleteMatching(ctx context.Context, match *KeyMatcher, batchSize int, progress func(models.DeleteSummary)) (models.DeleteSummary, error) {
+	var summary models.DeleteSummary
+
+	st, err := s.writableState()
+	if err != nil {
+		return summary, err
+	}
+	if match == nil {
+		return summary, fmt.Errorf("%w: a key filter is required", ErrInvalidFilter)
+	}
+	if batchSize <= 0 {
+		batchSize = defaultDeleteBatch
+	}
+
+	var mu sync.Mutex
+	batches := make(chan []string)
+
+	var wg sync.WaitGroup
+	for i := 0; i < deleteWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				result := deleteBatch(ctx, st.client, batch)
+
+				mu.Lock()
+				summary.Deleted += result.Deleted
+				summary.Missing += result.Missing
+				summary.Failed += result.Failed
+				for _, msg := range result.Errors {
+					if len(summary.Errors) < maxDeleteErrors {
+						summary.Errors = append(summary.Errors, msg)
+					}
+				}
+				if progress != nil {
+					progress(summary)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	send := func(batch []string) error {
+		select {
+		case batches <- batch:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var batch []string
+	scanErr := st.scanKeys(ctx, KeyCursor{}, match, func(key models.KeyInfo, _ KeyCursor) error {
+		mu.Lock()
+		summary.Matched++
+		mu.Unlock()
+
+		batch = append(batch, key.Key)
+		if len(batch) < batchSize {
+			return nil
+		}
+		full := batch
+		batch = nil
+		return send(full)
+	})
+	if scanErr == nil && len(batch) > 0 {
+		scanErr = send(batch)
+	}
+
+	close(batches)
+	wg.Wait()
+
+	if scanErr == nil {
+		scanErr = ctx.Err()
+	}
+	return summary, scanErr
+}
+
+// deleteBatch deletes keys one by one, counting keys that were already
+// gone as missing rather than failed.
+func deleteBatch(ctx context.Context, client *memcache.Client, keys []string) models.DeleteSummary {
+	var result models.DeleteSummary
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return result
+		}
+
+		err := client.Delete(key)
+		switch {
+		case err == nil:
+			result.Deleted++
+		case errors.Is(err, memcache.ErrCacheMiss):
+			result.Missing++
+		default:
+			result.Failed++
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+	return result
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"memcached-management/models"
+)
+
+// startBulkServer fakes a server holding catalog and session keys.
+// "catalog:gone" is listed but expired, so deleting it reports NOT_FOUND.
+func startBulkServer(t *testing.T) (string, *fakeCache) {
+	cache := newFakeCache()
+	for _, key := range []string{"catalog:1", "session:1", "catalog:2", "catalog:gone", "catalog:3"} {
+		cache.put(key, fakeItem{value: "0123456789", ttl: -1, gone: key == "catalog:gone"})
+	}
+	return startCacheServer(t, cache), cache
+}
+
+func TestPreviewDelete(t *testing.T) {
+	addr, cache := startBulkServer(t)
+
+	service := NewMemcachedService()
+	_ = service.Connect(addr)
+
+	match, _ := NewKeyMatcher(models.KeyFilter{Glob: "catalog:*"})
+	preview, err := service.PreviewDelete(context.Background(), match)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if preview.Matched != 4 || preview.Bytes != 40 || preview.Servers[addr] != 4 || len(preview.Sample) != 4 {
+		t.Errorf("Unexpected preview %+v", preview)
+	}
+	for _, key := range []string{"catalog:1", "session:1", "catalog:2", "catalog:3"} {
+		if _, ok := cache.item(key); !ok {
+			t.Errorf("Expected a preview not to delete, but %s was deleted", key)
+		}
+	}
+}
+
+func TestDeleteMatching(t *testing.T) {
+	addr, cache := startBulkServer(t)
+
+	service := NewMemcachedService()
+	_ = service.Connect(addr)
+
+	match, _ := NewKeyMatcher(models.KeyFilter{Prefix: "catalog:"})
+	var updates int
+	summary, err := service.DeleteMatching(context.Background(), match, 2, func(progress models.DeleteSummary) {
+		updates++
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if summary.Matched != 4 || summary.Deleted != 3 || summary.Missing != 1 || summary.Failed != 0 {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+	if updates != 2 {
+		t.Errorf("Expected one progress update per batch, got %d", updates)
+	}
+	if _, ok := cache.item("session:1"); !ok {
+		t.Error("Expected keys outside the pattern to be kept")
+	}
+}
+
+func TestDeleteMatching_RequiresFilterAndWritable(t *testing.T) {
+	addr, _ := startBulkServer(t)
+
+	service := NewMemcachedService()
+	_ = service.Connect(addr)
+	if _, err := service.DeleteMatching(context.Background(), nil, 0, nil); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("Expected ErrInvalidFilter without a filter, got %v", err)
+	}
+
+	_ = service.ConnectProfile(models.Profile{Servers: []string{addr}, ReadOnly: true})
+	match, _ := NewKeyMatcher(models.KeyFilter{Prefix: "catalog:"})
+	if _, err := service.DeleteMatching(context.Background(), match, 0, nil); err == nil || err.Error() != "connection is read-only" {
+		t.Errorf("Expected 'connection is read-only', got %v", err)
+	}
+}
@@ -0,0 +1,467 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+	"memcached-management/models"
+)
+
+const (
+	// maxDecodedSize stops decompression bombs; memcached items are 1MB by
+	// default, so anything near this is not a real value
+	maxDecodedSize = 16 << 20
+	// maxDecodeSteps bounds nested encodings such as gzip inside zstd
+	maxDecodeSteps = 4
+)
+
+// NoDecoder is the rule decoder that turns decoding off for a prefix.
+const NoDecoder = "none"
+
+var (
+	errDecodedTooLarge = fmt.Errorf("decoded value exceeds %d bytes", maxDecodedSize)
+
+	ErrInvalidDecoderRule = errors.New("invalid decoder rule")
+)
+
+// ValueDecoder recognizes and decodes one storage format. Match sniffs the
+// value (magic bytes, item flags) and should be cheap; Decode may still
+// fail, in which case the next matching decoder is tried.
+type ValueDecoder struct {
+	Name   string
+	Match  func(value []byte, flags uint32) bool
+	Decode func(value []byte) ([]byte, error)
+	// Final decoders produce the text shown to users, such as pretty
+	// printed JSON. The pipeline stops after them; the output of other
+	// decoders, like decompressors, is decoded again.
+	Final bool
+}
+
+// DecoderRegistry runs values through the registered decoders, in
+// registration order, until none of them applies. Rules pick the first
+// decoder by key prefix instead, for formats that cannot be sniffed
+// reliably.
+type DecoderRegistry struct {
+	mu       sync.RWMutex
+	decoders []ValueDecoder
+	rules    []models.DecoderRule
+	protos   *protoSchemas
+}
+
+// NewDecoderRegistry returns a registry with the built-in decoders: gzip,
+// zlib, zstd, snappy, PHP serialize, Python pickle, Java serialization,
+// msgpack, JSON and protobuf (through rules only).
+func NewDecoderRegistry() *DecoderRegistry {
+	r := &DecoderRegistry{}
+	for _, d := range builtinDecoders() {
+		r.Register(d)
+	}
+	return r
+}
+
+// Register adds a decoder after the existing ones.
+func (r *DecoderRegistry) Register(d ValueDecoder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decoders = append(r.decoders, d)
+}
+
+// Names returns the decoder names, in the order they are tried.
+func (r *DecoderRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var names []string
+	seen := make(map[string]bool)
+	for _, d := range r.decoders {
+		if !seen[d.Name] {
+			seen[d.Name] = true
+			names = append(names, d.Name)
+		}
+	}
+	return names
+}
+
+// Rules returns the prefix rules.
+func (r *DecoderRegistry) Rules() []models.DecoderRule {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]models.DecoderRule{}, r.rules...)
+}
+
+// SetRules replaces the prefix rules. Every rule must name a registered
+// decoder or NoDecoder, and prefixes must be unique. Only protobuf rules
+// take a message type, which does not have to be uploaded yet.
+func (r *DecoderRegistry) SetRules(rules []models.DecoderRule) error {
+	names := make(map[string]bool)
+	for _, name := range r.Names() {
+		names[name] = true
+	}
+	prefixes := make(map[string]bool)
+	for _, rule := range rules {
+		if rule.Decoder != NoDecoder && !names[rule.Decoder] {
+			return fmt.Errorf("%w: unknown decoder %q", ErrInvalidDecoderRule, rule.Decoder)
+		}
+		if rule.Message != "" && rule.Decoder != ProtobufDecoder {
+			return fmt.Errorf("%w: only %s rules take a message", ErrInvalidDecoderRule, ProtobufDecoder)
+		}
+		if prefixes[rule.Prefix] {
+			return fmt.Errorf("%w: duplicate prefix %q", ErrInvalidDecoderRule, rule.Prefix)
+		}
+		prefixes[rule.Prefix] = true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules = append([]models.DecoderRule{}, rules...)
+	return nil
+}
+
+// Decode returns the decoded view of the value stored under key, or nil
+// when no decoder recognizes it. When a rule matches the key, its decoder
+// runs first without sniffing; a failure is reported in the result's Error.
+func (r *DecoderRegistry) Decode(key string, value []byte, flags uint32) *models.DecodedValue {
+	r.mu.RLock()
+	decoders, rules, protos := r.decoders, r.rules, r.protos
+	r.mu.RUnlock()
+
+	var steps []string
+	var message string
+	var schemaErr error
+	current := value
+	final := false
+	if rule, ok := matchRule(rules, key); ok {
+		if rule.Decoder == NoDecoder {
+			return nil
+		}
+		var decoded []byte
+		var d ValueDecoder
+		var err error
+		if rule.Decoder == ProtobufDecoder {
+			d = ValueDecoder{Name: ProtobufDecoder, Final: true}
+			decoded, message, schemaErr, err = protos.decode(rule.Message, value)
+		} else {
+			decoded, d, err = decodeWith(decoders, rule.Decoder, value)
+		}
+		if err != nil {
+			return &models.DecodedValue{Steps: []string{rule.Decoder}, Error: err.Error()}
+		}
+		steps = append(steps, d.Name)
+		current = decoded
+		final = d.Final
+	}
+	for !final && len(steps) < maxDecodeSteps {
+		decoded, d, ok := decodeOnce(decoders, current, flags)
+		if !ok {
+			break
+		}
+		steps = append(steps, d.Name)
+		current = decoded
+		final = d.Final
+	}
+	if len(steps) == 0 {
+		return nil
+	}
+
+	result := &models.DecodedValue{Steps: steps, Size: len(current), Message: message}
+	result.Value, result.Encoding = EncodeValue(current)
+	if schemaErr != nil {
+		result.Error = schemaErr.Error()
+	}
+	if final && json.Valid(current) {
+		result.Tree = json.RawMessage(current)
+	}
+	return result
+}
+
+// matchRule returns the rule with the longest prefix of key.
+func matchRule(rules []models.DecoderRule, key string) (models.DecoderRule, bool) {
+	var best models.DecoderRule
+	found := false
+	for _, rule := range rules {
+		if strings.HasPrefix(key, rule.Prefix) && (!found || len(rule.Prefix) > len(best.Prefix)) {
+			best, found = rule, true
+		}
+	}
+	return best, found
+}
+
+// decodeWith runs the decoders called name, without their Match, until one
+// succeeds.
+func decodeWith(decoders []ValueDecoder, name string, value []byte) ([]byte, ValueDecoder, error) {
+	var firstErr error
+	for _, d := range decoders {
+		if d.Name != name {
+			continue
+		}
+		decoded, err := d.Decode(value)
+		if err == nil {
+			return decoded, d, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		firstErr = fmt.Errorf("unknown decoder %q", name)
+	}
+	return nil, ValueDecoder{}, firstErr
+}
+
+func decodeOnce(decoders []ValueDecoder, value []byte, flags uint32) ([]byte, ValueDecoder, bool) {
+	for _, d := range decoders {
+		if !d.Match(value, flags) {
+			continue
+		}
+		if decoded, err := d.Decode(value); err == nil {
+			return decoded, d, true
+		}
+	}
+	return nil, ValueDecoder{}, false
+}
+
+// phpCompressedZlib are the php-memcached flag bits for a zlib compressed
+// value.
+const phpCompressedZlib = 0x10 | 0x20
+
+var (
+	gzipMagic         = []byte{0x1f, 0x8b}
+	zstdMagic         = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	snappyStreamMagic = []byte("\xff\x06\x00\x00sNaPpY")
+)
+
+func builtinDecoders() []ValueDecoder {
+	return []ValueDecoder{
+		{
+			Name:  "gzip",
+			Match: func(value []byte, _ uint32) bool { return bytes.HasPrefix(value, gzipMagic) },
+			Decode: func(value []byte) ([]byte, error) {
+				zr, err := gzip.NewReader(bytes.NewReader(value))
+				if err != nil {
+					return nil, err
+				}
+				return readLimited(zr)
+			},
+		},
+		{
+			Name:  "zlib",
+			Match: func(value []byte, _ uint32) bool { return isZlibHeader(value) },
+			Decode: func(value []byte) ([]byte, error) {
+				zr, err := zlib.NewReader(bytes.NewReader(value))
+				if err != nil {
+					return nil, err
+				}
+				return readLimited(zr)
+			},
+		},
+		{
+			// php-memcached flags compressed items and prefixes the zlib
+			// stream with the uncompressed length.
+			Name: "zlib",
+			Match: func(value []byte, flags uint32) bool {
+				return flags&phpCompressedZlib == phpCompressedZlib && len(value) > 4 && isZlibHeader(value[4:])
+			},
+			Decode: func(value []byte) ([]byte, error) {
+				if len(value) < 4 {
+					return nil, errSerializedTruncated
+				}
+				zr, err := zlib.NewReader(bytes.NewReader(value[4:]))
+				if err != nil {
+					return nil, err
+				}
+				return readLimited(zr)
+			},
+		},
+		{
+			Name:  "zstd",
+			Match: func(value []byte, _ uint32) bool { return bytes.HasPrefix(value, zstdMagic) },
+			Decode: func(value []byte) ([]byte, error) {
+				zr, err := zstd.NewReader(bytes.NewReader(value), zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxDecodedSize))
+				if err != nil {
+					return nil, err
+				}
+				defer zr.Close()
+				return readLimited(zr)
+			},
+		},
+		{
+			Name:  "snappy",
+			Match: func(value []byte, _ uint32) bool { return bytes.HasPrefix(value, snappyStreamMagic) },
+			Decode: func(value []byte) ([]byte, error) {
+				return readLimited(snappy.NewReader(bytes.NewReader(value)))
+			},
+		},
+		{
+			// PHP values have no magic bytes, but the format is strict
+			// enough that a full parse rules out plain text.
+			Name:   "php",
+			Match:  func(value []byte, _ uint32) bool { return looksLikePHP(value) },
+			Decode: decodePHP,
+			Final:  true,
+		},
+		{
+			Name:   "pickle",
+			Match:  looksLikePickle,
+			Decode: decodePickle,
+			Final:  true,
+		},
+		{
+			Name:   "java",
+			Match:  func(value []byte, _ uint32) bool { return bytes.HasPrefix(value, javaMagic) },
+			Decode: decodeJava,
+			Final:  true,
+		},
+		{
+			Name:   "msgpack",
+			Match:  func(value []byte, _ uint32) bool { return isMsgpackContainer(value) },
+			Decode: decodeMsgpack,
+			Final:  true,
+		},
+		{
+			// Raw snappy blocks have no magic bytes. Only binary values
+			// whose block decodes to text are taken for snappy, which keeps
+			// false positives unlikely.
+			Name:  "snappy",
+			Match: func(value []byte, _ uint32) bool { return !utf8.Valid(value) },
+			Decode: func(value []byte) ([]byte, error) {
+				n, err := snappy.DecodedLen(value)
+				if err != nil {
+					return nil, err
+				}
+				if n > maxDecodedSize {
+					return nil, errDecodedTooLarge
+				}
+				decoded, err := snappy.Decode(nil, value)
+				if err != nil {
+					return nil, err
+				}
+				if !utf8.Valid(decoded) {
+					return nil, errors.New("not a snappy block")
+				}
+				return decoded, nil
+			},
+		},
+		{
+			// Only listed so rules can name it; see ProtobufDecoder.
+			Name:   ProtobufDecoder,
+			Match:  func([]byte, uint32) bool { return false },
+			Decode: decodeProtoWire,
+			Final:  true,
+		},
+		{
+			Name: "json",
+			Match: func(value []byte, _ uint32) bool {
+				trimmed := bytes.TrimSpace(value)
+				return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+			},
+			Decode: func(value []byte) ([]byte, error) {
+				var pretty bytes.Buffer
+				if err := json.Indent(&pretty, bytes.TrimSpace(value), "", "  "); err != nil {
+					return nil, err
+				}
+				return pretty.Bytes(), nil
+			},
+			Final: true,
+		},
+	}
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	decoded, err := io.ReadAll(io.LimitReader(r, maxDecodedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) > maxDecodedSize {
+		return nil, errDecodedTooLarge
+	}
+	return decoded, nil
+}
+
+// isZlibHeader checks the two byte zlib header: deflate with a window of
+// at most 32K and a valid check value.
+func isZlibHeader(value []byte) bool {
+	if len(value) < 2 || value[0]&0x0f != 8 || value[0]>>4 > 7 {
+		return false
+	}
+	return (uint16(value[0])<<8|uint16(value[1]))%31 == 0
+}
+
+// isMsgpackContainer reports whether value starts with a msgpack map or
+// array, the only top-level types worth showing as a document.
+func isMsgpackContainer(value []byte) bool {
+	if len(value) == 0 {
+		return false
+	}
+	b := value[0]
+	return b&0xf0 == 0x80 || b&0xf0 == 0x90 || (b >= 0xdc && b <= 0xdf)
+}
+
+// decodeMsgpack converts a msgpack document to indented JSON. The whole
+// value has to be one document.
+func decodeMsgpack(value []byte) ([]byte, error) {
+	r := bytes.NewReader(value)
+	dec := msgpack.NewDecoder(r)
+	dec.SetMapDecoder(decodeMsgpackMap)
+	doc, err := dec.DecodeInterface()
+	if err != nil {
+		return nil, err
+	}
+	if r.Len() > 0 {
+		return nil, errors.New("trailing data after msgpack document")
+	}
+	return json.MarshalIndent(jsonCompatible(doc), "", "  ")
+}
+
+// decodeMsgpackMap decodes a map into a map[interface{}]interface{}. Keys
+// Go cannot hash, such as maps and arrays, which msgpack allows as keys,
+// are keyed by their formatted value.
+func decodeMsgpackMap(d *msgpack.Decoder) (interface{}, error) {
+	n, err := d.DecodeMapLen()
+	if err != nil || n < 0 {
+		return nil, err
+	}
+	m := make(map[interface{}]interface{}, min(n, 1024))
+	for i := 0; i < n; i++ {
+		key, err := d.DecodeInterface()
+		if err != nil {
+			return nil, err
+		}
+		if key != nil && !reflect.TypeOf(key).Comparable() {
+			key = fmt.Sprint(key)
+		}
+		if m[key], err = d.DecodeInterface(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// jsonCompatible turns maps with non-string keys, which msgpack allows,
+// into maps keyed by their formatted key.
+func jsonCompatible(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = jsonCompatible(value)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = jsonCompatible(v[i])
+		}
+		return v
+	}
+	return v
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+	"memcached-management/models"
+)
+
+func gzipBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func zlibBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestDecoderRegistry(t *testing.T) {
+	document := []byte(`{"name":"John","tags":["a","b"]}`)
+	pretty := "{\n  \"name\": \"John\",\n  \"tags\": [\n    \"a\",\n    \"b\"\n  ]\n}"
+
+	zstdEncoder, _ := zstd.NewWriter(nil)
+	defer zstdEncoder.Close()
+
+	var snappyStream bytes.Buffer
+	sw := snappy.NewBufferedWriter(&snappyStream)
+	sw.Write([]byte("framed text"))
+	sw.Close()
+
+	packed, _ := msgpack.Marshal(map[string]interface{}{"id": 7, "name": "John"})
+	numericKeys, _ := msgpack.Marshal(map[int]string{1: "one"})
+
+	phpValue := make([]byte, 4)
+	binary.LittleEndian.PutUint32(phpValue, uint32(len(document)))
+	phpValue = append(phpValue, zlibBytes(document)...)
+
+	tests := []struct {
+		name  string
+		value []byte
+		flags uint32
+		steps string
+		want  string
+	}{
+		{"json", document, 0, "json", pretty},
+		{"gzip json", gzipBytes(document), 0, "gzip,json", pretty},
+		{"zlib text", zlibBytes([]byte("hello")), 0, "zlib", "hello"},
+		{"zstd json", zstdEncoder.EncodeAll(document, nil), 0, "zstd,json", pretty},
+		{"snappy stream", snappyStream.Bytes(), 0, "snappy", "framed text"},
+		{"snappy block", snappy.Encode(nil, []byte(strings.Repeat("block text ", 20))), 0, "snappy", strings.Repeat("block text ", 20)},
+		{"msgpack", packed, 0, "msgpack", "{\n  \"id\": 7,\n  \"name\": \"John\"\n}"},
+		{"msgpack numeric keys", numericKeys, 0, "msgpack", "{\n  \"1\": \"one\"\n}"},
+		{"msgpack map key", []byte("\x81\x81\xa1a\x01\xa1x"), 0, "msgpack", "{\n  \"map[a:1]\": \"x\"\n}"},
+		{"php-memcached zlib", phpValue, 0x30, "zlib,json", pretty},
+		{"gzip binary", gzipBytes([]byte{0xff, 0x00}), 0, "gzip", "/wA="},
+		{"php", []byte(`a:1:{s:2:"id";i:7;}`), 0, "php", "{\n  \"id\": 7\n}"},
+		{"gzip php", gzipBytes([]byte(`a:1:{s:2:"id";i:7;}`)), 0, "gzip,php", "{\n  \"id\": 7\n}"},
+		{"pickle", pickleFixture(t, pickleMemo), 0, "pickle", "{\n  \"a\": [\n    1\n  ],\n  \"b\": {\n    \"__ref\": 2\n  }\n}"},
+		{"java", javaStream(byte(tcString), "hi"), 0, "java", `"hi"`},
+	}
+
+	registry := NewDecoderRegistry()
+	for _, tt := range tests {
+		decoded := registry.Decode("key", tt.value, tt.flags)
+		if decoded == nil {
+			t.Errorf("%s: expected the value to be decoded", tt.name)
+			continue
+		}
+		if strings.Join(decoded.Steps, ",") != tt.steps || decoded.Value != tt.want {
+			t.Errorf("%s: got steps %v and value %q", tt.name, decoded.Steps, decoded.Value)
+		}
+	}
+}
+
+func TestDecoderRegistry_LeavesUnknownValues(t *testing.T) {
+	registry := NewDecoderRegistry()
+	// A msgpack map used as a map key must not panic.
+	for _, value := range [][]byte{[]byte("plain text"), []byte("{not json"), {0xff, 0xfe, 0xfd}, gzipBytes(nil)[:5], {}, []byte("\x83\x830000000")} {
+		if decoded := registry.Decode("key", value, 0); decoded != nil {
+			t.Errorf("%q: expected no decoding, got %+v", value, decoded)
+		}
+	}
+}
+
+func TestDecoderRegistry_Register(t *testing.T) {
+	registry := NewDecoderRegistry()
+	registry.Register(ValueDecoder{
+		Name:   "upper",
+		Match:  func(value []byte, flags uint32) bool { return flags == 42 },
+		Decode: func(value []byte) ([]byte, error) { return bytes.ToUpper(value), nil },
+		Final:  true,
+	})
+
+	decoded := registry.Decode("key", []byte("abc"), 42)
+	if decoded == nil || decoded.Value != "ABC" || decoded.Steps[0] != "upper" {
+		t.Errorf("Expected the registered decoder to run, got %+v", decoded)
+	}
+}
+
+func TestDecoderRegistry_Rules(t *testing.T) {
+	registry := NewDecoderRegistry()
+	phpValue := []byte(`a:1:{s:2:"id";i:7;}`)
+	protocol0 := pickleFixture(t, pickleMemo)[2:]
+
+	if decoded := registry.Decode("py:1", protocol0, 0); decoded != nil {
+		t.Fatalf("Expected an unsniffable pickle to stay raw, got %+v", decoded)
+	}
+
+	err := registry.SetRules([]models.DecoderRule{
+		{Prefix: "py:", Decoder: "pickle"},
+		{Prefix: "raw:", Decoder: NoDecoder},
+		{Prefix: "raw:php:", Decoder: "php"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	decoded := registry.Decode("py:1", protocol0, 0)
+	if decoded == nil || decoded.Steps[0] != "pickle" || decoded.Error != "" {
+		t.Errorf("Expected the rule to force the pickle decoder, got %+v", decoded)
+	} else if compactJSON(t, decoded.Tree) != `{"a":[1],"b":{"__ref":2}}` {
+		t.Errorf("Unexpected tree %s", decoded.Tree)
+	}
+
+	if decoded := registry.Decode("raw:1", phpValue, 0); decoded != nil {
+		t.Errorf("Expected the none rule to disable decoding, got %+v", decoded)
+	}
+	if decoded := registry.Decode("raw:php:1", phpValue, 0); decoded == nil || decoded.Steps[0] != "php" {
+		t.Errorf("Expected the longest prefix to win, got %+v", decoded)
+	}
+
+	decoded = registry.Decode("py:2", []byte("not a pickle"), 0)
+	if decoded == nil || decoded.Error == "" || decoded.Value != "" {
+		t.Errorf("Expected a forced decoder failure to be reported, got %+v", decoded)
+	}
+
+	if len(registry.Rules()) != 3 {
+		t.Errorf("Expected 3 rules, got %+v", registry.Rules())
+	}
+}
+
+func TestDecoderRegistry_SetRulesValidation(t *testing.T) {
+	registry := NewDecoderRegistry()
+	for _, rules := range [][]models.DecoderRule{
+		{{Prefix: "a:", Decoder: "yaml"}},
+		{{Prefix: "a:", Decoder: "php"}, {Prefix: "a:", Decoder: "json"}},
+	} {
+		if err := registry.SetRules(rules); !errors.Is(err, ErrInvalidDecoderRule) {
+			t.Errorf("%+v: expected ErrInvalidDecoderRule, got %v", rules, err)
+		}
+	}
+}
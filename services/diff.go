@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"memcached-management/models"
+)
+
+// ErrInvalidDiff is returned for dump files that cannot be compared.
+var ErrInvalidDiff = errors.New("invalid diff")
+
+// maxDiffEntries caps each list of a diff report; the summary still counts
+// every key.
+const maxDiffEntries = 1000
+
+// keySnapshot maps each key of one side of a diff to its value digest.
+type keySnapshot map[string]models.DiffSide
+
+// DiffServers reads every key under prefix from two clusters, both at the
+// same time, and compares their values.
+func DiffServers(ctx context.Context, a, b models.Profile, prefix string) (models.DiffReport, error) {
+	var match *KeyMatcher
+	if prefix != "" {
+		match = &KeyMatcher{prefix: prefix}
+	}
+
+	var wg sync.WaitGroup
+	var snapA, snapB keySnapshot
+	var serversA, serversB []string
+	var errA, errB error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		snapA, serversA, errA = snapshotProfile(ctx, a, match)
+	}()
+	go func() {
+		defer wg.Done()
+		snapB, serversB, errB = snapshotProfile(ctx, b, match)
+	}()
+	wg.Wait()
+
+	if errA != nil {
+		return models.DiffReport{}, fmt.Errorf("a: %w", errA)
+	}
+	if errB != nil {
+		return models.DiffReport{}, fmt.Errorf("b: %w", errB)
+	}
+
+	report := compareSnapshots(snapA, snapB)
+	report.A = strings.Join(serversA, ",")
+	report.B = strings.Join(serversB, ",")
+	report.Prefix = prefix
+	return report, nil
+}
+
+func snapshotProfile(ctx context.Context, profile models.Profile, match *KeyMatcher) (keySnapshot, []string, error) {
+	s := NewMemcachedService()
+	defer s.Disconnect()
+	if err := s.ConnectProfile(profile); err != nil {
+		return nil, nil, err
+	}
+
+	snapshot := make(keySnapshot)
+	_, _, err := s.Export(ctx, match, func(record models.DumpRecord) error {
+		value, err := DecodeValue(record.Value, record.Encoding)
+		if err != nil {
+			return err
+		}
+		snapshot[record.Key] = newDiffSide(value, record.Flags)
+		return nil
+	})
+	return snapshot, s.Servers(), err
+}
+
+// DiffDumps compares two files written by Export, gzip compressed or not.
+// Unlike Import, any unreadable line fails the diff, since the snapshot it
+// belongs to would be incomplete.
+func DiffDumps(a, b io.Reader, prefix string) (models.DiffReport, error) {
+	snapA, err := snapshotDump(a, prefix)
+	if err != nil {
+		return models.DiffReport{}, fmt.Errorf("a: %w", err)
+	}
+	snapB, err := snapshotDump(b, prefix)
+	if err != nil {
+		return models.DiffReport{}, fmt.Errorf("b: %w", err)
+	}
+
+	report := compareSnapshots(snapA, snapB)
+	report.Prefix = prefix
+	return report, nil
+}
+
+func snapshotDump(r io.Reader, prefix string) (keySnapshot, error) {
+	r, err := maybeGunzip(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDiff, err)
+	}
+
+	snapshot := make(keySnapshot)
+	var lineErr error
+	err = readImportJSONL(r, func(rec importRecord) error {
+		if strings.HasPrefix(rec.key, prefix) {
+			snapshot[rec.key] = newDiffSide(rec.value, rec.flags)
+		}
+		return nil
+	}, func(line int, _ string, err error) {
+		if lineErr == nil {
+			lineErr = fmt.Errorf("%w: line %d: %v", ErrInvalidDiff, line, err)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDiff, err)
+	}
+	return snapshot, lineErr
+}
+
+func newDiffSide(value []byte, flags uint32) models.DiffSide {
+	sum := sha256.Sum256(value)
+	return models.DiffSide{Size: len(value), Hash: hex.EncodeToString(sum[:]), Flags: flags}
+}
+
+// compareSnapshots lists the keys found on only one side and the keys
+// whose value or flags differ, each sorted by key.
+func compareSnapshots(a, b keySnapshot) models.DiffReport {
+	report := models.DiffReport{
+		Summary:    models.DiffSummary{KeysA: len(a), KeysB: len(b)},
+		OnlyInA:    []models.DiffEntry{},
+		OnlyInB:    []models.DiffEntry{},
+		Mismatched: []models.DiffMismatch{},
+	}
+
+	for _, key := range sortedKeys(a) {
+		sideA := a[key]
+		sideB, ok := b[key]
+		switch {
+		case !ok:
+			report.Summary.OnlyInA++
+			if len(report.OnlyInA) < maxDiffEntries {
+				report.OnlyInA = append(report.OnlyInA, models.DiffEntry{Key: key, DiffSide: sideA})
+			}
+		case sideA != sideB:
+			report.Summary.Mismatched++
+			if len(report.Mismatched) < maxDiffEntries {
+				report.Mismatched = append(report.Mismatched, models.DiffMismatch{Key: key, A: sideA, B: sideB})
+			}
+		default:
+			report.Summary.Identical++
+		}
+	}
+	for _, key := range sortedKeys(b) {
+		if _, ok := a[key]; ok {
+			continue
+		}
+		report.Summary.OnlyInB++
+		if len(report.OnlyInB) < maxDiffEntries {
+			report.OnlyInB = append(report.OnlyInB, models.DiffEntry{Key: key, DiffSide: b[key]})
+		}
+	}
+
+	report.Truncated = report.Summary.OnlyInA > len(report.OnlyInA) ||
+		report.Summary.OnlyInB > len(report.OnlyInB) ||
+		report.Summary.Mismatched > len(report.Mismatched)
+	return report
+}
+
+func sortedKeys(snapshot keySnapshot) []string {
+	keys := make([]string, 0, len(snapshot))
+	for key := range snapshot {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"memcached-management/models"
+)
+
+// startDiffServer fakes a server holding values, all with flags 0 and no
+// expiration.
+func startDiffServer(t *testing.T, values map[string]string) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	cache := newFakeCache()
+	for _, key := range keys {
+		cache.set(key, values[key])
+	}
+	return startCacheServer(t, cache)
+}
+
+func TestCompareSnapshots(t *testing.T) {
+	a := keySnapshot{
+		"same":    newDiffSide([]byte("x"), 0),
+		"changed": newDiffSide([]byte("old"), 0),
+		"flags":   newDiffSide([]byte("x"), 1),
+		"gone":    newDiffSide([]byte("x"), 0),
+	}
+	b := keySnapshot{
+		"same":    newDiffSide([]byte("x"), 0),
+		"changed": newDiffSide([]byte("new!"), 0),
+		"flags":   newDiffSide([]byte("x"), 2),
+		"added":   newDiffSide([]byte("y"), 0),
+	}
+
+	report := compareSnapshots(a, b)
+	want := models.DiffSummary{KeysA: 4, KeysB: 4, Identical: 1, OnlyInA: 1, OnlyInB: 1, Mismatched: 2}
+	if report.Summary != want {
+		t.Errorf("Expected %+v, got %+v", want, report.Summary)
+	}
+	if report.OnlyInA[0].Key != "gone" || report.OnlyInB[0].Key != "added" {
+		t.Errorf("Unexpected one-sided keys %+v %+v", report.OnlyInA, report.OnlyInB)
+	}
+	mismatch := report.Mismatched[0]
+	if mismatch.Key != "changed" || mismatch.A.Size != 3 || mismatch.B.Size != 4 || mismatch.A.Hash == mismatch.B.Hash {
+		t.Errorf("Unexpected mismatch %+v", mismatch)
+	}
+	if report.Truncated {
+		t.Error("Expected a complete report")
+	}
+}
+
+func TestDiffDumps(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte(`{"key":"user:1","value":"John","ttl":-1}
+{"key":"user:2","value":"aGk=","encoding":"base64","ttl":-1}
+{"key":"other","value":"x","ttl":-1}
+`))
+	zw.Close()
+	plain := `{"key":"user:1","value":"Jane","ttl":-1}
+{"key":"user:2","value":"hi","ttl":-1}
+`
+
+	report, err := DiffDumps(&compressed, strings.NewReader(plain), "user:")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := models.DiffSummary{KeysA: 2, KeysB: 2, Identical: 1, Mismatched: 1}
+	if report.Summary != want {
+		t.Errorf("Expected %+v, got %+v", want, report.Summary)
+	}
+
+	broken := plain + `{"error":"Error exporting: boom"}` + "\n"
+	if _, err := DiffDumps(strings.NewReader(plain), strings.NewReader(broken), ""); !errors.Is(err, ErrInvalidDiff) || !strings.Contains(err.Error(), "b: invalid diff: line 3") {
+		t.Errorf("Expected the truncated dump to be rejected, got %v", err)
+	}
+}
+
+func TestDiffServers(t *testing.T) {
+	a := startDiffServer(t, map[string]string{"k:1": "one", "k:2": "two", "k:3": "three", "z": "ignored"})
+	b := startDiffServer(t, map[string]string{"k:1": "one", "k:2": "TWO", "k:4": "four"})
+
+	report, err := DiffServers(context.Background(), models.Profile{Servers: []string{a}}, models.Profile{Servers: []string{b}}, "k:")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := models.DiffSummary{KeysA: 3, KeysB: 3, Identical: 1, OnlyInA: 1, OnlyInB: 1, Mismatched: 1}
+	if report.Summary != want {
+		t.Errorf("Expected %+v, got %+v", want, report.Summary)
+	}
+	if report.A != a || report.B != b || report.OnlyInA[0].Key != "k:3" || report.OnlyInB[0].Key != "k:4" || report.Mismatched[0].Key != "k:2" {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	if _, err := DiffServers(context.Background(), models.Profile{Servers: []string{a}}, models.Profile{}, ""); err == nil || !strings.HasPrefix(err.Error(), "b: ") {
+		t.Errorf("Expected an error for side b, got %v", err)
+	}
+}
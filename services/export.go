@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"memcached-management/models"
+)
+
+// Export enumerates the keys accepted by match and calls fn with each item's
+// value, flags and remaining TTL. Servers are exported one after another,
+// reading values over a single meta connection per server while its dump is
+// scanned. Keys that expire or are deleted during the export, and keys the
+// text protocol cannot address, are skipped and counted.
+func (s *MemcachedService) Export(ctx context.Context, match *KeyMatcher, fn func(models.DumpRecord) error) (exported, skipped int, err error) {
+	st, err := s.state()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, server := range st.servers {
+		n, skip, err := st.exportServer(ctx, server, match, fn)
+		exported += n
+		skipped += skip
+		if err != nil {
+			return exported, skipped, fmt.Errorf("%s: %w", server, err)
+		}
+	}
+
+	return exported, skipped, nil
+}
+
+func (st connState) exportServer(ctx context.Context, server string, match *KeyMatcher, fn func(models.DumpRecord) error) (exported, skipped int, err error) {
+	conn, err := dialMeta(server, st.timeout)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+
+	metaSupported := true
+	err = scanServerKeys(ctx, server, st.timeout, func(key models.KeyInfo) error {
+		if !match.Match(key) {
+			return nil
+		}
+		if !legalKey(key.Key) {
+			skipped++
+			return nil
+		}
+
+		now := time.Now()
+		var record models.DumpRecord
+		if metaSupported {
+			value, meta, err := conn.get(key.Key)
+			switch {
+			case errors.Is(err, errMetaUnsupported):
+				metaSupported = false
+			case errors.Is(err, memcache.ErrCacheMiss):
+				skipped++
+				return nil
+			case err != nil:
+				return err
+			default:
+				record = newDumpRecord(key.Key, value, meta.Flags, meta.TTL, server, now)
+			}
+		}
+		if !metaSupported {
+			// Without the meta protocol the TTL comes from the dump.
+			value, flags, err := conn.textGet(key.Key)
+			if errors.Is(err, memcache.ErrCacheMiss) {
+				skipped++
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			ttl := int64(-1)
+			if key.Expiration > 0 {
+				ttl = max(key.Expiration-now.Unix(), 0)
+			}
+			record = newDumpRecord(key.Key, value, flags, ttl, server, now)
+		}
+
+		if err := fn(record); err != nil {
+			return err
+		}
+		exported++
+		return nil
+	})
+
+	return exported, skipped, err
+}
+
+func newDumpRecord(key string, value []byte, flags uint32, ttl int64, server string, now time.Time) models.DumpRecord {
+	record := models.DumpRecord{Key: key, Flags: flags, TTL: ttl, Server: server}
+	record.Value, record.Encoding = EncodeValue(value)
+	if ttl >= 0 {
+		record.ExpiresAt = now.Unix() + ttl
+	}
+	return record
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"memcached-management/models"
+)
+
+func TestExport(t *testing.T) {
+	cache := newFakeCache()
+	cache.put("user:1", fakeItem{value: "John", flags: 7, ttl: 30})
+	cache.put("user:2", fakeItem{value: "Jane", ttl: -1, gone: true})
+	cache.set("blob", "\xff\x00")
+	cache.set("bad key", "x")
+	cache.set("other", "x")
+	addr := startCacheServer(t, cache)
+
+	service := NewMemcachedService()
+	_ = service.Connect(addr)
+
+	match, _ := NewKeyMatcher(models.KeyFilter{Regex: "^(user|blob|bad)"})
+	var records []models.DumpRecord
+	exported, skipped, err := service.Export(context.Background(), match, func(record models.DumpRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if exported != 2 || skipped != 2 {
+		t.Errorf("Expected 2 exported and 2 skipped, got %d and %d", exported, skipped)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %+v", records)
+	}
+
+	user := records[0]
+	if user.Key != "user:1" || user.Value != "John" || user.Encoding != "" || user.Flags != 7 || user.TTL != 30 || user.ExpiresAt == 0 || user.Server != addr {
+		t.Errorf("Unexpected record %+v", user)
+	}
+	blob := records[1]
+	if blob.Value != "/wA=" || blob.Encoding != "base64" || blob.TTL != -1 || blob.ExpiresAt != 0 {
+		t.Errorf("Unexpected binary record %+v", blob)
+	}
+}
+
+func TestExport_NotConnected(t *testing.T) {
+	service := NewMemcachedService()
+	if _, _, err := service.Export(context.Background(), nil, nil); err == nil {
+		t.Error("Expected an error when not connected")
+	}
+}
+
+func TestExport_WithoutMeta(t *testing.T) {
+	// Every key is dumped from the first server, wherever it hashes to, as
+	// after a change of the server list.
+	keys := []string{"a", "b", "c", "d", "e", "f"}
+	dumped := newFakeCache()
+	dumped.noMeta = true
+	for _, key := range keys {
+		dumped.put(key, fakeItem{value: "x", flags: 3, ttl: -1})
+	}
+	first := startCacheServer(t, dumped)
+	empty := newFakeCache()
+	empty.noMeta = true
+	second := startCacheServer(t, empty)
+
+	service := NewMemcachedService()
+	_ = service.Connect(first, second)
+
+	var records []models.DumpRecord
+	exported, skipped, err := service.Export(context.Background(), nil, func(record models.DumpRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if exported != len(keys) || skipped != 0 {
+		t.Errorf("Expected %d exported and none skipped, got %d and %d", len(keys), exported, skipped)
+	}
+	for _, record := range records {
+		if record.Value != "x" || record.Flags != 3 || record.TTL != -1 || record.Server != first {
+			t.Errorf("Unexpected record %+v", record)
+		}
+	}
+}
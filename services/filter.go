@@ -0,0 +1,138 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"memcached-management/models"
+)
+
+// ErrInvalidFilter is returned for key filters with a malformed pattern or
+// an impossible range.
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// KeyMatcher is a compiled models.KeyFilter. A nil matcher matches every
+// key.
+type KeyMatcher struct {
+	prefix           string
+	pattern          *regexp.Regexp
+	minSize, maxSize int
+	minTTL, maxTTL   int64
+	now              func() time.Time
+}
+
+// NewKeyMatcher compiles filter, returning nil when it has no conditions.
+func NewKeyMatcher(filter models.KeyFilter) (*KeyMatcher, error) {
+	if filter == (models.KeyFilter{}) {
+		return nil, nil
+	}
+	if filter.Glob != "" && filter.Regex != "" {
+		return nil, fmt.Errorf("%w: glob and regex cannot be combined", ErrInvalidFilter)
+	}
+	if filter.MinSize < 0 || filter.MaxSize < 0 || filter.MinTTL < 0 || filter.MaxTTL < 0 {
+		return nil, fmt.Errorf("%w: sizes and TTLs cannot be negative", ErrInvalidFilter)
+	}
+	if filter.MaxSize > 0 && filter.MinSize > filter.MaxSize {
+		return nil, fmt.Errorf("%w: min_size is larger than max_size", ErrInvalidFilter)
+	}
+	if filter.MaxTTL > 0 && filter.MinTTL > filter.MaxTTL {
+		return nil, fmt.Errorf("%w: min_ttl is larger than max_ttl", ErrInvalidFilter)
+	}
+
+	m := &KeyMatcher{
+		prefix:  filter.Prefix,
+		minSize: filter.MinSize,
+		maxSize: filter.MaxSize,
+		minTTL:  filter.MinTTL,
+		maxTTL:  filter.MaxTTL,
+		now:     time.Now,
+	}
+
+	expr := filter.Regex
+	if filter.Glob != "" {
+		expr = globToRegexp(filter.Glob)
+	}
+	if expr != "" {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+		}
+		m.pattern = pattern
+	}
+
+	return m, nil
+}
+
+// Match reports whether key passes every condition of the filter. Keys
+// that never expire count as having an infinite TTL.
+func (m *KeyMatcher) Match(key models.KeyInfo) bool {
+	if m == nil {
+		return true
+	}
+	if !strings.HasPrefix(key.Key, m.prefix) {
+		return false
+	}
+	if m.minSize > 0 && key.Size < m.minSize {
+		return false
+	}
+	if m.maxSize > 0 && key.Size > m.maxSize {
+		return false
+	}
+
+	if m.minTTL > 0 || m.maxTTL > 0 {
+		if key.Expiration < 0 {
+			if m.maxTTL > 0 {
+				return false
+			}
+		} else {
+			ttl := key.Expiration - m.now().Unix()
+			if ttl < m.minTTL || (m.maxTTL > 0 && ttl > m.maxTTL) {
+				return false
+			}
+		}
+	}
+
+	return m.pattern == nil || m.pattern.MatchString(key.Key)
+}
+
+// globToRegexp converts a shell-style glob to an anchored regular
+// expression. * matches any run of characters (including ':' and '/'),
+// ? matches one character and [...] is a character class.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			} else {
+				b.WriteString(`\\`)
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"memcached-management/models"
+)
+
+func TestKeyMatcher(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	session := models.KeyInfo{Key: "session:42", Size: 300, Expiration: now.Unix() + 60}
+	catalog := models.KeyInfo{Key: "catalog:v41:item/7", Size: 5000, Expiration: -1}
+
+	tests := []struct {
+		name    string
+		filter  models.KeyFilter
+		session bool
+		catalog bool
+	}{
+		{"empty", models.KeyFilter{}, true, true},
+		{"prefix", models.KeyFilter{Prefix: "session:"}, true, false},
+		{"glob", models.KeyFilter{Glob: "session:*"}, true, false},
+		{"glob across separators", models.KeyFilter{Glob: "catalog:v4?:*"}, false, true},
+		{"glob class", models.KeyFilter{Glob: "session:[0-9]*"}, true, false},
+		{"glob negated class", models.KeyFilter{Glob: "[!s]*"}, false, true},
+		{"glob is anchored", models.KeyFilter{Glob: "42"}, false, false},
+		{"regex", models.KeyFilter{Regex: `item/\d+$`}, false, true},
+		{"min size", models.KeyFilter{MinSize: 1000}, false, true},
+		{"max size", models.KeyFilter{MaxSize: 1000}, true, false},
+		{"min ttl", models.KeyFilter{MinTTL: 120}, false, true},
+		{"max ttl", models.KeyFilter{MaxTTL: 120}, true, false},
+		{"combined", models.KeyFilter{Prefix: "session:", MaxSize: 100}, false, false},
+	}
+
+	for _, tt := range tests {
+		m, err := NewKeyMatcher(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", tt.name, err)
+		}
+		if m != nil {
+			m.now = func() time.Time { return now }
+		}
+		if got := m.Match(session); got != tt.session {
+			t.Errorf("%s: session match = %v, want %v", tt.name, got, tt.session)
+		}
+		if got := m.Match(catalog); got != tt.catalog {
+			t.Errorf("%s: catalog match = %v, want %v", tt.name, got, tt.catalog)
+		}
+	}
+}
+
+func TestNewKeyMatcher_Invalid(t *testing.T) {
+	filters := []models.KeyFilter{
+		{Regex: "("},
+		{Glob: "a*", Regex: "a"},
+		{MinSize: 10, MaxSize: 5},
+		{MinTTL: -1},
+	}
+	for _, filter := range filters {
+		if _, err := NewKeyMatcher(filter); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("%+v: expected ErrInvalidFilter, got %v", filter, err)
+		}
+	}
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := map[string]string{
+		"session:*": `^session:.*$`,
+		"a.b?":      `^a\.b.$`,
+		"[!ab]x":    `^[^ab]x$`,
+		`lit\*`:     `^lit\*$`,
+		"open[":     `^open\[$`,
+	}
+	for glob, want := range tests {
+		if got := globToRegexp(glob); got != want {
+			t.Errorf("%q: got %q, want %q", glob, got, want)
+		}
+	}
+}
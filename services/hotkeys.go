@@ -0,0 +1,372 @@
+package services
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"memcached-management/models"
+)
+
+const (
+	defaultHotKeyWindow = 60
+	maxHotKeyWindow     = 3600
+	defaultHotKeyTopK   = 20
+	maxHotKeyTopK       = 1000
+	// hotKeyBuckets splits the window so old traffic ages out in steps
+	hotKeyBuckets = 6
+	// hotKeyCapacity is how many counters a sketch keeps per reported
+	// key; the extra ones make the top K accurate under churn
+	hotKeyCapacity = 10
+)
+
+var (
+	ErrInvalidHotKeys    = errors.New("invalid hot key options")
+	ErrHotKeysNotStarted = errors.New("hot key analysis not started")
+)
+
+// StartHotKeys starts a hot key analysis on the connected servers, replacing
+// a running one. It watches fetches and mutations until StopHotKeys, a
+// reconnect or a disconnect.
+func (s *MemcachedService) StartHotKeys(req models.HotKeyRequest) (models.HotKeyReport, error) {
+	analyzer, err := NewHotKeyAnalyzer(req)
+	if err != nil {
+		return models.HotKeyReport{}, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := s.Watch(ctx)
+	if err != nil {
+		cancel()
+		return models.HotKeyReport{}, err
+	}
+	analyzer.cancel = cancel
+	go analyzer.run(stream)
+
+	s.mu.Lock()
+	previous := s.hotKeys
+	s.hotKeys = analyzer
+	s.mu.Unlock()
+	if previous != nil {
+		previous.Stop()
+	}
+	return analyzer.Snapshot(), nil
+}
+
+// HotKeys returns the current report of the last analysis started.
+func (s *MemcachedService) HotKeys() (models.HotKeyReport, error) {
+	s.mu.RLock()
+	analyzer := s.hotKeys
+	s.mu.RUnlock()
+	if analyzer == nil {
+		return models.HotKeyReport{}, ErrHotKeysNotStarted
+	}
+	return analyzer.Snapshot(), nil
+}
+
+// StopHotKeys stops the analysis; its last report stays available.
+func (s *MemcachedService) StopHotKeys() (models.HotKeyReport, error) {
+	s.mu.RLock()
+	analyzer := s.hotKeys
+	s.mu.RUnlock()
+	if analyzer == nil {
+		return models.HotKeyReport{}, ErrHotKeysNotStarted
+	}
+	analyzer.Stop()
+	return analyzer.Snapshot(), nil
+}
+
+// HotKeyAnalyzer counts requests per key and per prefix over a sliding
+// window, keeping memory bounded with Space-Saving sketches.
+type HotKeyAnalyzer struct {
+	window     time.Duration
+	topK       int
+	sampleRate float64
+	prefix     string
+	delimiters string
+	cancel     context.CancelFunc
+	now        func() time.Time
+	sample     func() float64
+
+	mu        sync.Mutex
+	started   time.Time
+	running   bool
+	err       error
+	events    int64
+	skipped   int64
+	epoch     int64
+	keys      [hotKeyBuckets]*spaceSaving
+	prefixes  [hotKeyBuckets]*spaceSaving
+	bucketLen time.Duration
+}
+
+// NewHotKeyAnalyzer validates req and returns an analyzer fed through Add.
+func NewHotKeyAnalyzer(req models.HotKeyRequest) (*HotKeyAnalyzer, error) {
+	if req.WindowSeconds == 0 {
+		req.WindowSeconds = defaultHotKeyWindow
+	}
+	if req.TopK == 0 {
+		req.TopK = defaultHotKeyTopK
+	}
+	if req.SampleRate == 0 {
+		req.SampleRate = 1
+	}
+	if req.Delimiters == "" {
+		req.Delimiters = defaultTreeDelimiters
+	}
+	switch {
+	case req.WindowSeconds < hotKeyBuckets || req.WindowSeconds > maxHotKeyWindow:
+		return nil, fmt.Errorf("%w: window must be between %d and %d seconds", ErrInvalidHotKeys, hotKeyBuckets, maxHotKeyWindow)
+	case req.TopK < 1 || req.TopK > maxHotKeyTopK:
+		return nil, fmt.Errorf("%w: top_k must be between 1 and %d", ErrInvalidHotKeys, maxHotKeyTopK)
+	case req.SampleRate < 0 || req.SampleRate > 1 || math.IsNaN(req.SampleRate):
+		return nil, fmt.Errorf("%w: sample_rate must be above 0 and at most 1", ErrInvalidHotKeys)
+	}
+
+	a := &HotKeyAnalyzer{
+		window:     time.Duration(req.WindowSeconds) * time.Second,
+		topK:       req.TopK,
+		sampleRate: req.SampleRate,
+		prefix:     req.Prefix,
+		delimiters: req.Delimiters,
+		cancel:     func() {},
+		now:        time.Now,
+		sample:     rand.Float64,
+		running:    true,
+	}
+	a.bucketLen = a.window / hotKeyBuckets
+	a.started = a.now()
+	for i := range a.keys {
+		a.keys[i] = newSpaceSaving(a.topK * hotKeyCapacity)
+		a.prefixes[i] = newSpaceSaving(a.topK * hotKeyCapacity)
+	}
+	return a, nil
+}
+
+func (a *HotKeyAnalyzer) run(stream *WatchStream) {
+	for event := range stream.Events() {
+		a.Add(event)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.running = false
+	if err := stream.Err(); err != nil {
+		a.err = err
+	}
+}
+
+// Stop ends the analysis and its watch connections.
+func (a *HotKeyAnalyzer) Stop() {
+	a.cancel()
+	a.mu.Lock()
+	a.running = false
+	a.mu.Unlock()
+}
+
+// Add counts a watch event. Only fetches and mutations are requests;
+// skipped line reports are tallied so the report can flag undercounting.
+func (a *HotKeyAnalyzer) Add(event models.WatchEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if event.Type == models.WatchSkipped {
+		a.skipped += int64(event.Skipped)
+		return
+	}
+	if event.Type != models.WatchFetch && event.Type != models.WatchStore && event.Type != models.WatchDelete {
+		return
+	}
+	if event.Key == "" || !strings.HasPrefix(event.Key, a.prefix) {
+		return
+	}
+	if a.sampleRate < 1 && a.sample() >= a.sampleRate {
+		return
+	}
+
+	a.rotate()
+	a.events++
+	i := a.epoch % hotKeyBuckets
+	a.keys[i].add(event)
+	if j := strings.IndexAny(event.Key, a.delimiters); j >= 0 {
+		prefixed := event
+		prefixed.Key = event.Key[:j+1]
+		a.prefixes[i].add(prefixed)
+	}
+}
+
+// rotate clears the buckets that fell out of the window since the last
+// call. The caller holds a.mu.
+func (a *HotKeyAnalyzer) rotate() {
+	epoch := int64(a.now().Sub(a.started) / a.bucketLen)
+	for e := a.epoch + 1; e <= epoch && e <= a.epoch+hotKeyBuckets; e++ {
+		a.keys[e%hotKeyBuckets].reset()
+		a.prefixes[e%hotKeyBuckets].reset()
+	}
+	if epoch > a.epoch {
+		a.epoch = epoch
+	}
+}
+
+// Snapshot returns the top keys and prefixes of the current window.
+func (a *HotKeyAnalyzer) Snapshot() models.HotKeyReport {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rotate()
+
+	// Rates are over the part of the window that has passed.
+	seconds := min(a.now().Sub(a.started), a.window).Seconds()
+	report := models.HotKeyReport{
+		Running:       a.running,
+		StartedAt:     a.started,
+		WindowSeconds: int(a.window / time.Second),
+		TopK:          a.topK,
+		SampleRate:    a.sampleRate,
+		Prefix:        a.prefix,
+		Events:        int64(math.Round(float64(a.events) / a.sampleRate)),
+		Skipped:       a.skipped,
+		Keys:          a.top(a.keys[:], seconds),
+		Prefixes:      a.top(a.prefixes[:], seconds),
+	}
+	if a.err != nil {
+		report.Error = a.err.Error()
+	}
+	return report
+}
+
+// top merges the bucket sketches and returns the topK entries, scaled by
+// the sample rate.
+func (a *HotKeyAnalyzer) top(buckets []*spaceSaving, seconds float64) []models.HotKey {
+	merged := make(map[string]*hotCounter)
+	for _, bucket := range buckets {
+		for key, c := range bucket.counters {
+			m, ok := merged[key]
+			if !ok {
+				m = &hotCounter{key: key}
+				merged[key] = m
+			}
+			m.count += c.count
+			m.err += c.err
+			m.fetches += c.fetches
+			m.misses += c.misses
+			m.mutations += c.mutations
+		}
+	}
+
+	counters := make([]*hotCounter, 0, len(merged))
+	for _, c := range merged {
+		counters = append(counters, c)
+	}
+	sort.Slice(counters, func(i, j int) bool {
+		if counters[i].count != counters[j].count {
+			return counters[i].count > counters[j].count
+		}
+		return counters[i].key < counters[j].key
+	})
+
+	scale := func(n int64) int64 { return int64(math.Round(float64(n) / a.sampleRate)) }
+	keys := []models.HotKey{}
+	for _, c := range counters[:min(len(counters), a.topK)] {
+		key := models.HotKey{
+			Key:       c.key,
+			Count:     scale(c.count),
+			Error:     scale(c.err),
+			Fetches:   scale(c.fetches),
+			Misses:    scale(c.misses),
+			Mutations: scale(c.mutations),
+		}
+		if seconds > 0 {
+			key.Rate = float64(key.Count) / seconds
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// spaceSaving is the Space-Saving top-K sketch: it keeps at most capacity
+// counters, and a new key takes over the smallest one, inheriting its
+// count as the overestimation error.
+type spaceSaving struct {
+	capacity int
+	counters map[string]*hotCounter
+	heap     counterHeap
+}
+
+type hotCounter struct {
+	key       string
+	count     int64
+	err       int64
+	fetches   int64
+	misses    int64
+	mutations int64
+	index     int
+}
+
+func newSpaceSaving(capacity int) *spaceSaving {
+	return &spaceSaving{capacity: capacity, counters: make(map[string]*hotCounter)}
+}
+
+func (s *spaceSaving) reset() {
+	clear(s.counters)
+	s.heap = s.heap[:0]
+}
+
+func (s *spaceSaving) add(event models.WatchEvent) {
+	c, ok := s.counters[event.Key]
+	switch {
+	case ok:
+	case len(s.counters) < s.capacity:
+		c = &hotCounter{key: event.Key}
+		s.counters[c.key] = c
+		heap.Push(&s.heap, c)
+	default:
+		// Replace the least counted key; the per type counts restart.
+		c = s.heap[0]
+		delete(s.counters, c.key)
+		*c = hotCounter{key: event.Key, count: c.count, err: c.count, index: c.index}
+		s.counters[c.key] = c
+	}
+
+	c.count++
+	switch {
+	case event.Type != models.WatchFetch:
+		c.mutations++
+	case event.Status == "not_found":
+		c.fetches++
+		c.misses++
+	default:
+		c.fetches++
+	}
+	heap.Fix(&s.heap, c.index)
+}
+
+// counterHeap is a min-heap of counters by count.
+type counterHeap []*hotCounter
+
+func (h counterHeap) Len() int           { return len(h) }
+func (h counterHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h counterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *counterHeap) Push(x interface{}) {
+	c := x.(*hotCounter)
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *counterHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"testing"
+	"time"
+
+	"memcached-management/models"
+)
+
+func newTestHotKeyAnalyzer(t *testing.T, req models.HotKeyRequest) (*HotKeyAnalyzer, *time.Time) {
+	t.Helper()
+	a, err := NewHotKeyAnalyzer(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := a.started
+	a.now = func() time.Time { return now }
+	return a, &now
+}
+
+func fetch(key string) models.WatchEvent {
+	return models.WatchEvent{Type: models.WatchFetch, Key: key, Status: "found"}
+}
+
+func TestHotKeyAnalyzer(t *testing.T) {
+	a, now := newTestHotKeyAnalyzer(t, models.HotKeyRequest{WindowSeconds: 60, TopK: 2})
+
+	for i := 0; i < 5; i++ {
+		a.Add(fetch("user:1"))
+	}
+	a.Add(models.WatchEvent{Type: models.WatchFetch, Key: "user:2", Status: "not_found"})
+	a.Add(models.WatchEvent{Type: models.WatchFetch, Key: "user:2", Status: "not_found"})
+	a.Add(models.WatchEvent{Type: models.WatchStore, Key: "user:2"})
+	a.Add(fetch("session:9"))
+	a.Add(models.WatchEvent{Type: models.WatchEviction, Key: "user:1"})
+	a.Add(models.WatchEvent{Type: models.WatchSkipped, Skipped: 4})
+
+	*now = now.Add(10 * time.Second)
+	report := a.Snapshot()
+	if report.Events != 9 || report.Skipped != 4 {
+		t.Errorf("Expected 9 events and 4 skipped, got %d and %d", report.Events, report.Skipped)
+	}
+	if len(report.Keys) != 2 || report.Keys[0].Key != "user:1" || report.Keys[0].Count != 5 || report.Keys[0].Rate != 0.5 {
+		t.Fatalf("Unexpected top keys %+v", report.Keys)
+	}
+	if second := report.Keys[1]; second.Key != "user:2" || second.Misses != 2 || second.Fetches != 2 || second.Mutations != 1 {
+		t.Errorf("Unexpected second key %+v", second)
+	}
+	if len(report.Prefixes) != 2 || report.Prefixes[0].Key != "user:" || report.Prefixes[0].Count != 8 || report.Prefixes[1].Key != "session:" {
+		t.Errorf("Unexpected prefixes %+v", report.Prefixes)
+	}
+
+	// Traffic ages out a bucket at a time.
+	*now = now.Add(55 * time.Second)
+	a.Add(fetch("user:3"))
+	report = a.Snapshot()
+	if len(report.Keys) != 1 || report.Keys[0].Key != "user:3" {
+		t.Errorf("Expected only the recent key after the window moved, got %+v", report.Keys)
+	}
+}
+
+func TestHotKeyAnalyzer_SampleRateAndPrefix(t *testing.T) {
+	a, _ := newTestHotKeyAnalyzer(t, models.HotKeyRequest{WindowSeconds: 60, SampleRate: 0.5, Prefix: "user:"})
+	sampled := false
+	a.sample = func() float64 {
+		sampled = !sampled
+		if sampled {
+			return 0.1
+		}
+		return 0.9
+	}
+
+	for i := 0; i < 4; i++ {
+		a.Add(fetch("user:1"))
+		a.Add(fetch("other:1"))
+	}
+	report := a.Snapshot()
+	if len(report.Keys) != 1 || report.Keys[0].Count != 4 || report.Events != 4 {
+		t.Errorf("Expected 2 sampled events scaled to 4 for user:1 only, got %+v", report)
+	}
+}
+
+func TestSpaceSaving(t *testing.T) {
+	s := newSpaceSaving(2)
+	for _, key := range []string{"a", "a", "a", "b", "c"} {
+		s.add(fetch(key))
+	}
+
+	if _, ok := s.counters["b"]; ok || len(s.counters) != 2 {
+		t.Fatalf("Expected c to replace b, got %v", s.counters)
+	}
+	if c := s.counters["c"]; c.count != 2 || c.err != 1 {
+		t.Errorf("Expected c to inherit b's count as error, got %+v", c)
+	}
+	if c := s.counters["a"]; c.count != 3 || c.err != 0 {
+		t.Errorf("Expected a to keep its exact count, got %+v", c)
+	}
+}
+
+func TestNewHotKeyAnalyzer_Validation(t *testing.T) {
+	for _, req := range []models.HotKeyRequest{
+		{WindowSeconds: 1},
+		{WindowSeconds: 7200},
+		{TopK: -1},
+		{TopK: 5000},
+		{SampleRate: 1.5},
+		{SampleRate: -0.1},
+	} {
+		if _, err := NewHotKeyAnalyzer(req); !errors.Is(err, ErrInvalidHotKeys) {
+			t.Errorf("%+v: expected ErrInvalidHotKeys, got %v", req, err)
+		}
+	}
+}
+
+func TestStartHotKeys(t *testing.T) {
+	addr := startFakeServer(t, func(line string, r *bufio.Reader) string {
+		switch line {
+		case "version":
+			return "VERSION 1.6.21\r\n"
+		case watchCommand:
+			return "OK\r\n" +
+				"ts=1700000000.000001 gid=1 type=item_get key=hot status=found clsid=1 cfd=20 size=1\r\n" +
+				"ts=1700000000.000002 gid=2 type=item_get key=hot status=found clsid=1 cfd=20 size=1\r\n"
+		}
+		return "ERROR\r\n"
+	})
+
+	service := NewMemcachedService()
+	if _, err := service.HotKeys(); !errors.Is(err, ErrHotKeysNotStarted) {
+		t.Errorf("Expected ErrHotKeysNotStarted, got %v", err)
+	}
+	if err := service.Connect(addr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.StartHotKeys(models.HotKeyRequest{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		report, _ := service.HotKeys()
+		if len(report.Keys) == 1 && report.Keys[0].Count == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the hot key, got %+v", report)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	report, err := service.StopHotKeys()
+	if err != nil || report.Running {
+		t.Errorf("Expected the analysis to stop, got %+v, %v", report, err)
+	}
+	service.Disconnect()
+	if _, err := service.HotKeys(); !errors.Is(err, ErrHotKeysNotStarted) {
+		t.Errorf("Expected the analysis to be dropped on disconnect, got %v", err)
+	}
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"memcached-management/models"
+)
+
+// ErrInvalidImport is returned for import options that cannot be used.
+var ErrInvalidImport = errors.New("invalid import")
+
+const (
+	defaultImportWorkers = 8
+	maxImportWorkers     = 64
+	// maxImportErrors caps the line errors kept in an import summary
+	maxImportErrors = 1000
+	// maxImportLine fits a 1MB item encoded as base64 with room to spare
+	maxImportLine = 8 << 20
+)
+
+type importOptions struct {
+	csv         bool
+	mode        string
+	preserveTTL bool
+	ttl         int32
+	workers     int
+}
+
+func parseImportOptions(opts models.ImportOptions, now time.Time) (importOptions, error) {
+	o := importOptions{workers: opts.Concurrency, mode: opts.Mode}
+
+	switch opts.Format {
+	case "", "jsonl":
+	case "csv":
+		o.csv = true
+	default:
+		return o, fmt.Errorf("%w: unknown format %q", ErrInvalidImport, opts.Format)
+	}
+
+	switch opts.Mode {
+	case "":
+		o.mode = "overwrite"
+	case "overwrite", "add-only", "skip-existing":
+	default:
+		return o, fmt.Errorf("%w: unknown mode %q", ErrInvalidImport, opts.Mode)
+	}
+
+	if opts.TTL == "" || opts.TTL == "preserve" {
+		o.preserveTTL = true
+	} else {
+		ttl, err := strconv.ParseInt(opts.TTL, 10, 64)
+		if err != nil {
+			return o, fmt.Errorf("%w: ttl must be \"preserve\" or a number of seconds", ErrInvalidImport)
+		}
+		if o.ttl, err = ValidateExpiration(ttl, now); err != nil {
+			return o, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+	}
+
+	if o.workers <= 0 {
+		o.workers = defaultImportWorkers
+	}
+	if o.workers > maxImportWorkers {
+		return o, fmt.Errorf("%w: concurrency cannot exceed %d", ErrInvalidImport, maxImportWorkers)
+	}
+
+	return o, nil
+}
+
+// importRecord is one parsed line waiting to be written.
+type importRecord struct {
+	line  int
+	key   string
+	value []byte
+	flags uint32
+	// expiresAt is the absolute expiration of a JSON dump record, 0 when
+	// the record has none
+	expiresAt int64
+	// ttl uses memcached semantics and applies when expiresAt is 0
+	ttl int64
+}
+
+// Import writes the items of a JSON Lines dump (as produced by Export) or a
+// key,value,ttl CSV file, gzip compressed or not. Lines are parsed in order
+// and written by opts.Concurrency parallel writers. Lines that cannot be
+// parsed or written are reported in the summary and do not stop the import.
+func (s *MemcachedService) Import(ctx context.Context, r io.Reader, opts models.ImportOptions) (models.ImportSummary, error) {
+	var summary models.ImportSummary
+
+	o, err := parseImportOptions(opts, time.Now())
+	if err != nil {
+		return summary, err
+	}
+	st, err := s.writableState()
+	if err != nil {
+		return summary, err
+	}
+	r, err = maybeGunzip(r)
+	if err != nil {
+		return summary, err
+	}
+
+	var mu sync.Mutex
+	fail := func(line int, key string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		summary.Failed++
+		if len(summary.Errors) < maxImportErrors {
+			summary.Errors = append(summary.Errors, models.ImportLineError{Line: line, Key: key, Error: err.Error()})
+		}
+	}
+
+	records := make(chan importRecord)
+	var wg sync.WaitGroup
+	for i := 0; i < o.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rec := range records {
+				if ctx.Err() != nil {
+					continue
+				}
+				skipped, err := writeImportRecord(st.client, o, rec, time.Now())
+				if err != nil {
+					fail(rec.line, rec.key, err)
+					continue
+				}
+				mu.Lock()
+				if skipped {
+					summary.Skipped++
+				} else {
+					summary.Written++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	emit := func(rec importRecord) error {
+		mu.Lock()
+		summary.Lines++
+		mu.Unlock()
+		select {
+		case records <- rec:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	lineError := func(line int, key string, err error) {
+		mu.Lock()
+		summary.Lines++
+		mu.Unlock()
+		fail(line, key, err)
+	}
+
+	if o.csv {
+		err = readImportCSV(r, emit, lineError)
+	} else {
+		err = readImportJSONL(r, emit, lineError)
+	}
+
+	close(records)
+	wg.Wait()
+
+	sort.Slice(summary.Errors, func(i, j int) bool { return summary.Errors[i].Line < summary.Errors[j].Line })
+	if err == nil {
+		err = ctx.Err()
+	}
+	return summary, err
+}
+
+// maybeGunzip transparently decompresses gzip input.
+func maybeGunzip(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(2)
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+func readImportJSONL(r io.Reader, emit func(importRecord) error, lineError func(int, string, error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxImportLine)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var record models.DumpRecord
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			lineError(line, "", fmt.Errorf("invalid JSON: %v", err))
+			continue
+		}
+		if record.Key == "" {
+			lineError(line, "", fmt.Errorf("key is required"))
+			continue
+		}
+
+		value, err := DecodeValue(record.Value, record.Encoding)
+		if err != nil {
+			lineError(line, record.Key, err)
+			continue
+		}
+
+		rec := importRecord{line: line, key: record.Key, value: value, flags: record.Flags, expiresAt: record.ExpiresAt}
+		if record.ExpiresAt == 0 && record.TTL > 0 {
+			rec.ttl = record.TTL
+		}
+		if err := emit(rec); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func readImportCSV(r io.Reader, emit func(importRecord) error, lineError func(int, string, error)) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	first := true
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			lineError(parseErr.Line, "", err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := reader.FieldPos(0)
+
+		if first {
+			first = false
+			if len(fields) >= 2 && strings.EqualFold(fields[0], "key") && strings.EqualFold(fields[1], "value") {
+				continue
+			}
+		}
+
+		if len(fields) < 2 || len(fields) > 3 {
+			lineError(line, "", fmt.Errorf("expected key,value[,ttl], got %d fields", len(fields)))
+			continue
+		}
+
+		rec := importRecord{line: line, key: fields[0], value: []byte(fields[1])}
+		if len(fields) == 3 && strings.TrimSpace(fields[2]) != "" {
+			if rec.ttl, err = strconv.ParseInt(strings.TrimSpace(fields[2]), 10, 64); err != nil {
+				lineError(line, rec.key, fmt.Errorf("invalid ttl %q", fields[2]))
+				continue
+			}
+		}
+		if err := emit(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// writeImportRecord stores one record according to the import mode. It
+// reports skipped for existing keys in skip-existing mode and for records
+// whose preserved TTL has already run out.
+func writeImportRecord(client *memcache.Client, o importOptions, rec importRecord, now time.Time) (bool, error) {
+	if rec.key == "" || !legalKey(rec.key) {
+		return false, memcache.ErrMalformedKey
+	}
+
+	expiration := o.ttl
+	if o.preserveTTL {
+		switch {
+		case rec.expiresAt > 0:
+			remaining := rec.expiresAt - now.Unix()
+			if remaining <= 0 {
+				return true, nil
+			}
+			// Long TTLs have to be sent as the absolute time.
+			if remaining > maxRelativeTTL {
+				remaining = rec.expiresAt
+			}
+			expiration = int32(min(remaining, math.MaxInt32))
+		default:
+			var err error
+			if expiration, err = ValidateExpiration(rec.ttl, now); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	item := &memcache.Item{Key: rec.key, Value: rec.value, Flags: rec.flags, Expiration: expiration}
+	switch o.mode {
+	case "add-only":
+		if err := client.Add(item); errors.Is(err, memcache.ErrNotStored) {
+			return false, fmt.Errorf("key already exists")
+		} else if err != nil {
+			return false, err
+		}
+	case "skip-existing":
+		if err := client.Add(item); errors.Is(err, memcache.ErrNotStored) {
+			return true, nil
+		} else if err != nil {
+			return false, err
+		}
+	default:
+		if err := client.Set(item); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
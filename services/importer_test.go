@@ -0,0 +1,120 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"memcached-management/models"
+)
+
+// startImportServer fakes a server where "existing" is already stored.
+func startImportServer(t *testing.T) (string, *fakeCache) {
+	cache := newFakeCache()
+	cache.set("existing", "old")
+	return startCacheServer(t, cache), cache
+}
+
+func TestImport_JSONL(t *testing.T) {
+	addr, cache := startImportServer(t)
+	service := NewMemcachedService()
+	_ = service.Connect(addr)
+
+	future := time.Now().Unix() + 100
+	dump := fmt.Sprintf(`{"key":"user:1","value":"John","flags":7,"ttl":100,"expires_at":%d}
+{"key":"blob","value":"aGk=","encoding":"base64","ttl":-1}
+
+{"key":"expired","value":"x","ttl":0,"expires_at":1}
+not json
+{"key":"bad key","value":"x","ttl":-1}
+{"error":"Error exporting: boom"}
+`, future)
+
+	summary, err := service.Import(context.Background(), strings.NewReader(dump), models.ImportOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if summary.Lines != 6 || summary.Written != 2 || summary.Skipped != 1 || summary.Failed != 3 {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+	if len(summary.Errors) != 3 || summary.Errors[0].Line != 5 {
+		t.Errorf("Expected line errors to carry line numbers, got %+v", summary.Errors)
+	}
+
+	if got, _ := cache.item("user:1"); got.stored != "set" || got.flags != 7 || got.value != "John" {
+		t.Errorf("Unexpected write for user:1: %+v", got)
+	}
+	if got, _ := cache.item("blob"); got.stored != "set" || got.flags != 0 || got.ttl != -1 || got.value != "hi" {
+		t.Errorf("Expected base64 value to be decoded and never expire, got %+v", got)
+	}
+}
+
+func TestImport_CSVModesAndTTLOverride(t *testing.T) {
+	csv := "key,value,ttl\nexisting,old,60\nnew,\"a,b\",60\n"
+
+	tests := []struct {
+		mode    string
+		written int
+		skipped int
+		failed  int
+	}{
+		{"overwrite", 2, 0, 0},
+		{"add-only", 1, 0, 1},
+		{"skip-existing", 1, 1, 0},
+	}
+
+	for _, tt := range tests {
+		addr, cache := startImportServer(t)
+		service := NewMemcachedService()
+		_ = service.Connect(addr)
+
+		summary, err := service.Import(context.Background(), strings.NewReader(csv), models.ImportOptions{Format: "csv", Mode: tt.mode, TTL: "30", Concurrency: 2})
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", tt.mode, err)
+		}
+		if summary.Lines != 2 || summary.Written != tt.written || summary.Skipped != tt.skipped || summary.Failed != tt.failed {
+			t.Errorf("%s: unexpected summary %+v", tt.mode, summary)
+		}
+		if got, _ := cache.item("new"); got.flags != 0 || got.ttl != 30 || got.value != "a,b" {
+			t.Errorf("%s: expected TTL override on new, got %+v", tt.mode, got)
+		}
+	}
+}
+
+func TestImport_Gzip(t *testing.T) {
+	addr, _ := startImportServer(t)
+	service := NewMemcachedService()
+	_ = service.Connect(addr)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	io.WriteString(gz, `{"key":"zipped","value":"v","ttl":-1}`+"\n")
+	gz.Close()
+
+	summary, err := service.Import(context.Background(), &buf, models.ImportOptions{})
+	if err != nil || summary.Written != 1 {
+		t.Errorf("Expected gzip dump to be imported, got %+v (%v)", summary, err)
+	}
+}
+
+func TestImport_InvalidOptions(t *testing.T) {
+	service := NewMemcachedService()
+	options := []models.ImportOptions{
+		{Format: "xml"},
+		{Mode: "merge"},
+		{TTL: "forever"},
+		{TTL: "-5"},
+		{Concurrency: 1000},
+	}
+	for _, opts := range options {
+		if _, err := service.Import(context.Background(), strings.NewReader(""), opts); !errors.Is(err, ErrInvalidImport) {
+			t.Errorf("%+v: expected ErrInvalidImport, got %v", opts, err)
+		}
+	}
+}
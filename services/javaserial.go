@@ -0,0 +1,512 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+)
+
+// Java object serialization stream constants, from
+// java.io.ObjectStreamConstants.
+const (
+	javaStreamMagic   = 0xaced
+	javaStreamVersion = 5
+	javaBaseHandle    = 0x7e0000
+
+	tcNull           = 0x70
+	tcReference      = 0x71
+	tcClassDesc      = 0x72
+	tcObject         = 0x73
+	tcString         = 0x74
+	tcArray          = 0x75
+	tcClass          = 0x76
+	tcBlockData      = 0x77
+	tcEndBlockData   = 0x78
+	tcReset          = 0x79
+	tcBlockDataLong  = 0x7a
+	tcLongString     = 0x7c
+	tcProxyClassDesc = 0x7d
+	tcEnum           = 0x7e
+
+	scWriteMethod    = 0x01
+	scSerializable   = 0x02
+	scExternalizable = 0x04
+	scBlockData      = 0x08
+)
+
+var javaMagic = []byte{0xac, 0xed, 0x00, 0x05}
+
+// errJavaEndBlock is returned by content when it reads TC_ENDBLOCKDATA,
+// which ends annotation lists.
+var errJavaEndBlock = errors.New("end of block data")
+
+// decodeJava parses a Java serialization stream. Classes are never loaded:
+// objects become maps with "__class" and their serializable fields, and
+// data written by custom writeObject methods is listed under
+// "__annotations".
+func decodeJava(value []byte) ([]byte, error) {
+	p := &javaParser{r: bytes.NewReader(value), refs: make(serializedRefs)}
+	v, err := p.stream()
+	if err != nil {
+		return nil, fmt.Errorf("java: %v at offset %d", err, p.offset())
+	}
+	return marshalSerialized(v, p.refs)
+}
+
+type javaClassDesc struct {
+	name   string
+	flags  byte
+	fields []javaField
+	super  *javaClassDesc
+}
+
+type javaField struct {
+	typecode byte
+	name     string
+}
+
+type javaParser struct {
+	r       *bytes.Reader
+	handles []interface{}
+	refs    serializedRefs
+	items   int
+}
+
+func (p *javaParser) offset() int {
+	return int(p.r.Size()) - p.r.Len()
+}
+
+// stream reads the header and every top-level object. A single object is
+// returned as is, several as a list.
+func (p *javaParser) stream() (interface{}, error) {
+	var magic, version uint16
+	if err := p.read(&magic); err != nil {
+		return nil, err
+	}
+	if err := p.read(&version); err != nil {
+		return nil, err
+	}
+	if magic != javaStreamMagic || version != javaStreamVersion {
+		return nil, errors.New("not a serialization stream")
+	}
+
+	var contents []interface{}
+	for p.r.Len() > 0 {
+		v, err := p.content(0)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, v)
+	}
+	if len(contents) == 1 {
+		return contents[0], nil
+	}
+	return contents, nil
+}
+
+func (p *javaParser) read(v interface{}) error {
+	if err := binary.Read(p.r, binary.BigEndian, v); err != nil {
+		return errSerializedTruncated
+	}
+	return nil
+}
+
+func (p *javaParser) bytes(n int64) ([]byte, error) {
+	if n < 0 || n > int64(p.r.Len()) {
+		return nil, errSerializedTruncated
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(p.r, buf); err != nil {
+		return nil, errSerializedTruncated
+	}
+	return buf, nil
+}
+
+// utf reads a string with a 2 byte length in modified UTF-8.
+func (p *javaParser) utf() (string, error) {
+	var n uint16
+	if err := p.read(&n); err != nil {
+		return "", err
+	}
+	data, err := p.bytes(int64(n))
+	if err != nil {
+		return "", err
+	}
+	return modifiedUTF8(data), nil
+}
+
+func (p *javaParser) newHandle(v interface{}) int {
+	p.handles = append(p.handles, v)
+	p.refs.add(v, len(p.handles)-1)
+	return len(p.handles) - 1
+}
+
+func (p *javaParser) content(depth int) (interface{}, error) {
+	if depth > maxSerializedDepth {
+		return nil, errors.New("nesting too deep")
+	}
+	if p.items++; p.items > maxSerializedItems {
+		return nil, errSerializedTooLarge
+	}
+	tc, err := p.r.ReadByte()
+	if err != nil {
+		return nil, errSerializedTruncated
+	}
+
+	switch tc {
+	case tcNull:
+		return nil, nil
+	case tcReference:
+		var handle int32
+		if err := p.read(&handle); err != nil {
+			return nil, err
+		}
+		i := int(handle) - javaBaseHandle
+		if i < 0 || i >= len(p.handles) {
+			return nil, fmt.Errorf("invalid handle 0x%x", handle)
+		}
+		return p.handles[i], nil
+	case tcString, tcLongString:
+		var n int64
+		if tc == tcString {
+			var short uint16
+			err = p.read(&short)
+			n = int64(short)
+		} else {
+			err = p.read(&n)
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := p.bytes(n)
+		if err != nil {
+			return nil, err
+		}
+		s := modifiedUTF8(data)
+		p.newHandle(s)
+		return s, nil
+	case tcClassDesc, tcProxyClassDesc:
+		p.r.UnreadByte()
+		return p.classDesc(depth)
+	case tcClass:
+		desc, err := p.classDesc(depth)
+		if err != nil {
+			return nil, err
+		}
+		class := newOrderedMap()
+		class.Set("__javaClass", className(desc))
+		p.newHandle(class)
+		return class, nil
+	case tcEnum:
+		desc, err := p.classDesc(depth)
+		if err != nil {
+			return nil, err
+		}
+		enum := classObject(className(desc))
+		p.newHandle(enum)
+		name, err := p.content(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		enum.Set("__enum", name)
+		return enum, nil
+	case tcArray:
+		return p.array(depth)
+	case tcObject:
+		return p.object(depth)
+	case tcBlockData, tcBlockDataLong:
+		var n int64
+		if tc == tcBlockData {
+			var short uint8
+			err = p.read(&short)
+			n = int64(short)
+		} else {
+			var long int32
+			err = p.read(&long)
+			n = int64(long)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return p.bytes(n)
+	case tcEndBlockData:
+		return nil, errJavaEndBlock
+	case tcReset:
+		p.handles = p.handles[:0]
+		return p.content(depth + 1)
+	}
+	return nil, fmt.Errorf("unsupported type code 0x%02x", tc)
+}
+
+// classDesc reads a class descriptor, which may also be null or a
+// reference to an earlier one.
+func (p *javaParser) classDesc(depth int) (*javaClassDesc, error) {
+	tc, err := p.r.ReadByte()
+	if err != nil {
+		return nil, errSerializedTruncated
+	}
+	switch tc {
+	case tcNull:
+		return nil, nil
+	case tcReference:
+		p.r.UnreadByte()
+		v, err := p.content(depth)
+		if err != nil {
+			return nil, err
+		}
+		desc, ok := v.(*javaClassDesc)
+		if !ok {
+			return nil, errors.New("reference is not a class descriptor")
+		}
+		return desc, nil
+	case tcClassDesc:
+		desc := &javaClassDesc{}
+		if desc.name, err = p.utf(); err != nil {
+			return nil, err
+		}
+		var serialVersionUID int64
+		if err := p.read(&serialVersionUID); err != nil {
+			return nil, err
+		}
+		p.newHandle(desc)
+		if err := p.read(&desc.flags); err != nil {
+			return nil, err
+		}
+		var count uint16
+		if err := p.read(&count); err != nil {
+			return nil, err
+		}
+		for i := 0; i < int(count); i++ {
+			field, err := p.field(depth)
+			if err != nil {
+				return nil, err
+			}
+			desc.fields = append(desc.fields, field)
+		}
+		if _, err := p.annotations(depth); err != nil {
+			return nil, err
+		}
+		desc.super, err = p.classDesc(depth + 1)
+		return desc, err
+	case tcProxyClassDesc:
+		desc := &javaClassDesc{name: "$Proxy"}
+		p.newHandle(desc)
+		var count int32
+		if err := p.read(&count); err != nil {
+			return nil, err
+		}
+		for i := int32(0); i < count; i++ {
+			if _, err := p.utf(); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := p.annotations(depth); err != nil {
+			return nil, err
+		}
+		desc.super, err = p.classDesc(depth + 1)
+		return desc, err
+	}
+	return nil, fmt.Errorf("expected a class descriptor, got 0x%02x", tc)
+}
+
+func (p *javaParser) field(depth int) (javaField, error) {
+	var f javaField
+	if err := p.read(&f.typecode); err != nil {
+		return f, err
+	}
+	name, err := p.utf()
+	if err != nil {
+		return f, err
+	}
+	f.name = name
+	switch f.typecode {
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z':
+	case 'L', '[':
+		// The field's type name, as a string object.
+		if _, err := p.content(depth + 1); err != nil {
+			return f, err
+		}
+	default:
+		return f, fmt.Errorf("invalid field type %q", f.typecode)
+	}
+	return f, nil
+}
+
+// annotations reads contents up to TC_ENDBLOCKDATA.
+func (p *javaParser) annotations(depth int) ([]interface{}, error) {
+	var items []interface{}
+	for {
+		v, err := p.content(depth + 1)
+		if err == errJavaEndBlock {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+}
+
+func (p *javaParser) object(depth int) (interface{}, error) {
+	desc, err := p.classDesc(depth)
+	if err != nil {
+		return nil, err
+	}
+	if desc == nil {
+		return nil, errors.New("object without a class")
+	}
+	obj := classObject(desc.name)
+	p.newHandle(obj)
+
+	// Class data is written from the topmost superclass down.
+	var chain []*javaClassDesc
+	for d := desc; d != nil; d = d.super {
+		chain = append([]*javaClassDesc{d}, chain...)
+	}
+	var annotations []interface{}
+	for _, d := range chain {
+		switch {
+		case d.flags&scExternalizable != 0:
+			if d.flags&scBlockData == 0 {
+				return nil, fmt.Errorf("%s uses the old externalizable format", d.name)
+			}
+			items, err := p.annotations(depth)
+			if err != nil {
+				return nil, err
+			}
+			annotations = append(annotations, items...)
+		case d.flags&scSerializable != 0:
+			for _, f := range d.fields {
+				v, err := p.fieldValue(f.typecode, depth)
+				if err != nil {
+					return nil, err
+				}
+				obj.Set(f.name, v)
+			}
+			if d.flags&scWriteMethod != 0 {
+				items, err := p.annotations(depth)
+				if err != nil {
+					return nil, err
+				}
+				annotations = append(annotations, items...)
+			}
+		}
+	}
+	if len(annotations) > 0 {
+		obj.Set("__annotations", annotations)
+	}
+	return obj, nil
+}
+
+func (p *javaParser) array(depth int) (interface{}, error) {
+	desc, err := p.classDesc(depth)
+	if err != nil {
+		return nil, err
+	}
+	if desc == nil || len(desc.name) < 2 || desc.name[0] != '[' {
+		return nil, errors.New("array without an array class")
+	}
+	list := &serializedList{}
+	handle := p.newHandle(list)
+	var n int32
+	if err := p.read(&n); err != nil {
+		return nil, err
+	}
+	if n < 0 || int(n) > p.r.Len() || int(n) > maxSerializedItems {
+		return nil, errSerializedTooLarge
+	}
+	// byte[] is common enough to keep as bytes rather than a list of
+	// numbers.
+	if desc.name[1] == 'B' {
+		data, err := p.bytes(int64(n))
+		p.handles[handle] = data
+		p.refs.add(data, handle)
+		return data, err
+	}
+	for i := int32(0); i < n; i++ {
+		v, err := p.fieldValue(desc.name[1], depth)
+		if err != nil {
+			return nil, err
+		}
+		list.items = append(list.items, v)
+	}
+	return list, nil
+}
+
+func (p *javaParser) fieldValue(typecode byte, depth int) (interface{}, error) {
+	switch typecode {
+	case 'B':
+		var v int8
+		err := p.read(&v)
+		return int64(v), err
+	case 'C':
+		var v uint16
+		err := p.read(&v)
+		return string(utf16.Decode([]uint16{v})), err
+	case 'D':
+		var v float64
+		err := p.read(&v)
+		return v, err
+	case 'F':
+		var v float32
+		err := p.read(&v)
+		return float64(v), err
+	case 'I':
+		var v int32
+		err := p.read(&v)
+		return int64(v), err
+	case 'J':
+		var v int64
+		err := p.read(&v)
+		return v, err
+	case 'S':
+		var v int16
+		err := p.read(&v)
+		return int64(v), err
+	case 'Z':
+		var v uint8
+		err := p.read(&v)
+		return v != 0, err
+	case 'L', '[':
+		v, err := p.content(depth + 1)
+		if desc, ok := v.(*javaClassDesc); ok {
+			v = className(desc)
+		}
+		return v, err
+	}
+	return nil, fmt.Errorf("invalid field type %q", typecode)
+}
+
+func className(desc *javaClassDesc) string {
+	if desc == nil {
+		return ""
+	}
+	return desc.name
+}
+
+// modifiedUTF8 decodes Java's modified UTF-8, which encodes NUL as two
+// bytes and supplementary characters as separately encoded surrogates.
+func modifiedUTF8(data []byte) string {
+	units := make([]uint16, 0, len(data))
+	for i := 0; i < len(data); {
+		b := data[i]
+		switch {
+		case b < 0x80:
+			units = append(units, uint16(b))
+			i++
+		case b&0xe0 == 0xc0 && i+1 < len(data):
+			units = append(units, uint16(b&0x1f)<<6|uint16(data[i+1]&0x3f))
+			i += 2
+		case b&0xf0 == 0xe0 && i+2 < len(data):
+			units = append(units, uint16(b&0x0f)<<12|uint16(data[i+1]&0x3f)<<6|uint16(data[i+2]&0x3f))
+			i += 3
+		default:
+			units = append(units, 0xfffd)
+			i++
+		}
+	}
+	return string(utf16.Decode(units))
+}
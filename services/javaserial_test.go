@@ -0,0 +1,128 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+// javaStream builds a serialization stream from type codes, numbers and
+// strings, which are written with their 2 byte length.
+func javaStream(parts ...interface{}) []byte {
+	var buf bytes.Buffer
+	buf.Write(javaMagic)
+	for _, part := range parts {
+		switch part := part.(type) {
+		case string:
+			binary.Write(&buf, binary.BigEndian, uint16(len(part)))
+			buf.WriteString(part)
+		case []byte:
+			buf.Write(part)
+		default:
+			binary.Write(&buf, binary.BigEndian, part)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeJava(t *testing.T) {
+	// class User implements Serializable { int id; String name; }
+	userClass := []interface{}{
+		byte(tcClassDesc), "User", int64(1), byte(scSerializable), uint16(2),
+		byte('I'), "id",
+		byte('L'), "name", byte(tcString), "Ljava/lang/String;",
+		byte(tcEndBlockData), byte(tcNull),
+	}
+	user := append([]interface{}{byte(tcObject)}, userClass...)
+	user = append(user, int32(7), byte(tcString), "Ana")
+
+	// The second object reuses the class and the string by handle: the
+	// class is 0x7e0000, "Ljava/lang/String;" 0x7e0001, the first user
+	// 0x7e0002 and "Ana" 0x7e0003.
+	second := []interface{}{byte(tcObject), byte(tcReference), int32(javaBaseHandle), int32(8), byte(tcReference), int32(javaBaseHandle + 3)}
+
+	bytesArray := []interface{}{
+		byte(tcArray), byte(tcClassDesc), "[B", int64(1), byte(scSerializable), uint16(0), byte(tcEndBlockData), byte(tcNull),
+		int32(2), []byte{0xff, 0x00},
+	}
+
+	// class Box implements Serializable { private void writeObject(...) }
+	withWriteObject := []interface{}{
+		byte(tcObject), byte(tcClassDesc), "Box", int64(1), byte(scSerializable | scWriteMethod), uint16(0), byte(tcEndBlockData), byte(tcNull),
+		byte(tcBlockData), byte(4), int32(3), byte(tcString), "item", byte(tcEndBlockData),
+	}
+
+	tests := []struct {
+		name  string
+		parts []interface{}
+		want  string
+	}{
+		{"object", user, `{"__class":"User","id":7,"name":"Ana"}`},
+		{"references", append(append([]interface{}{}, user...), second...), `[{"__class":"User","id":7,"name":"Ana"},{"__class":"User","id":8,"name":"Ana"}]`},
+		{"string", []interface{}{byte(tcString), "caf\xc3\xa9 \xc0\x80"}, `"café \u0000"`},
+		{"byte array", bytesArray, `{"__base64":"/wA="}`},
+		{"write method", withWriteObject, `{"__class":"Box","__annotations":["\u0000\u0000\u0000\u0003","item"]}`},
+	}
+
+	for _, tt := range tests {
+		decoded, err := decodeJava(javaStream(tt.parts...))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got := compactJSON(t, decoded); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestDecodeJava_Invalid(t *testing.T) {
+	for name, value := range map[string][]byte{
+		"bad magic":     []byte("\xac\xed\x00\x04\x70"),
+		"truncated":     javaStream(byte(tcString), uint16(10), []byte("abc")),
+		"bad handle":    javaStream(byte(tcReference), int32(javaBaseHandle+5)),
+		"unknown code":  javaStream(byte(0x42)),
+		"old external":  javaStream(byte(tcObject), byte(tcClassDesc), "Ext", int64(1), byte(scExternalizable), uint16(0), byte(tcEndBlockData), byte(tcNull)),
+		"huge array":    javaStream(byte(tcArray), byte(tcClassDesc), "[I", int64(1), byte(scSerializable), uint16(0), byte(tcEndBlockData), byte(tcNull), int32(1<<30)),
+		"missing class": javaStream(byte(tcObject), byte(tcNull)),
+	} {
+		if _, err := decodeJava(value); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestDecodeJava_SharedReferences(t *testing.T) {
+	// new Object[0], then 30 arrays holding the previous one twice. The
+	// class is handle 0x7e0000 and the nth array 0x7e0001+n.
+	parts := []interface{}{
+		byte(tcArray), byte(tcClassDesc), "[Ljava.lang.Object;", int64(1), byte(scSerializable), uint16(0), byte(tcEndBlockData), byte(tcNull), int32(0),
+	}
+	for n := int32(0); n < 30; n++ {
+		previous := int32(javaBaseHandle) + 1 + n
+		parts = append(parts, byte(tcArray), byte(tcReference), int32(javaBaseHandle), int32(2),
+			byte(tcReference), previous, byte(tcReference), previous)
+	}
+
+	done := make(chan struct{})
+	var decoded []byte
+	var err error
+	go func() {
+		decoded, err = decodeJava(javaStream(parts...))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out decoding a stream with shared references")
+	}
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(decoded) > 16<<10 || !strings.Contains(compactJSON(t, decoded), `{"__ref":1}`) {
+		t.Errorf("Expected repeats to be written as references, got %d bytes", len(decoded))
+	}
+}
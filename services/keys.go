@@ -0,0 +1,306 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"memcached-management/models"
+)
+
+// errMetadumpUnavailable is returned when a server rejects lru_crawler
+// metadump, because it predates memcached 1.4.31, the crawler is disabled
+// or another crawl is already running.
+var errMetadumpUnavailable = errors.New("lru_crawler metadump is not available")
+
+// cacheDumpEntry matches "ITEM <key> [<size> b; <expiration> s]".
+var cacheDumpEntry = regexp.MustCompile(`^ITEM (\S+) \[(\d+) b; (\d+) s\]`)
+
+// keyScanner enumerates the keys of one server over a raw connection.
+type keyScanner struct {
+	server  string
+	conn    net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+}
+
+// ErrInvalidCursor is returned for key cursors that were not produced by a
+// previous scan of the same connection.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// KeyCursor is a position in a sequential key scan: the index of a server
+// in the connection's server list and the number of keys already read from
+// it.
+type KeyCursor struct {
+	Server int
+	Offset int
+}
+
+// String encodes the cursor for clients as an opaque token.
+func (c KeyCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", c.Server, c.Offset)))
+}
+
+// ParseKeyCursor decodes a token from KeyCursor.String. The empty token is
+// the start of the scan.
+func ParseKeyCursor(token string) (KeyCursor, error) {
+	var c KeyCursor
+	if token == "" {
+		return c, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	server, offset, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return c, ErrInvalidCursor
+	}
+	if c.Server, err = strconv.Atoi(server); err != nil || c.Server < 0 {
+		return KeyCursor{}, ErrInvalidCursor
+	}
+	if c.Offset, err = strconv.Atoi(offset); err != nil || c.Offset < 0 {
+		return KeyCursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// ScanKeys walks the keys of every server in turn, starting at from, and
+// calls fn with each key accepted by match and its position. The scan reads
+// straight from the protocol stream and stops when fn returns an error or
+// ctx is cancelled.
+//
+// Positions are offsets in the server's dump order, counting filtered keys
+// too. That order changes as items are written and evicted, so resuming
+// from a cursor is best effort.
+func (s *MemcachedService) ScanKeys(ctx context.Context, from KeyCursor, match *KeyMatcher, fn func(models.KeyInfo, KeyCursor) error) error {
+	st, err := s.state()
+	if err != nil {
+		return err
+	}
+	return st.scanKeys(ctx, from, match, fn)
+}
+
+func (st connState) scanKeys(ctx context.Context, from KeyCursor, match *KeyMatcher, fn func(models.KeyInfo, KeyCursor) error) error {
+	if from.Server > len(st.servers) {
+		return ErrInvalidCursor
+	}
+
+	for i := from.Server; i < len(st.servers); i++ {
+		skip := 0
+		if i == from.Server {
+			skip = from.Offset
+		}
+
+		offset := 0
+		err := scanServerKeys(ctx, st.servers[i], st.timeout, func(key models.KeyInfo) error {
+			at := KeyCursor{Server: i, Offset: offset}
+			offset++
+			if at.Offset < skip || !match.Match(key) {
+				return nil
+			}
+			return fn(key, at)
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", st.servers[i], err)
+		}
+	}
+
+	return nil
+}
+
+// scanServerKeys calls fn for every key stored on server. It prefers
+// lru_crawler metadump, which walks the whole LRU, and falls back to
+// stats cachedump, which is limited to about 2MB of keys per slab class.
+// Scanning stops at the first error returned by fn or when ctx is done.
+func scanServerKeys(ctx context.Context, server string, timeout time.Duration, fn func(models.KeyInfo) error) error {
+	conn, err := net.DialTimeout("tcp", server, timeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	// Closing the connection unblocks a read waiting on a slow dump.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	ks := &keyScanner{server: server, conn: conn, r: bufio.NewReader(conn), timeout: timeout}
+
+	err = ks.metadump(fn)
+	if errors.Is(err, errMetadumpUnavailable) {
+		err = ks.cachedump(fn)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func (ks *keyScanner) send(cmd string) error {
+	ks.conn.SetDeadline(time.Now().Add(ks.timeout))
+	_, err := fmt.Fprintf(ks.conn, "%s\r\n", cmd)
+	return err
+}
+
+// readLine reads one reply line. The deadline is pushed forward on every
+// line so large dumps are bounded by server stalls, not by their size.
+func (ks *keyScanner) readLine() (string, error) {
+	ks.conn.SetReadDeadline(time.Now().Add(ks.timeout))
+	line, err := ks.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (ks *keyScanner) metadump(fn func(models.KeyInfo) error) error {
+	if err := ks.send("lru_crawler metadump all"); err != nil {
+		return err
+	}
+
+	first := true
+	for {
+		line, err := ks.readLine()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case line == "END":
+			return nil
+		case first && (line == "ERROR" || strings.HasPrefix(line, "CLIENT_ERROR") || strings.HasPrefix(line, "BUSY")):
+			return fmt.Errorf("%w: %s", errMetadumpUnavailable, line)
+		case strings.HasPrefix(line, "SERVER_ERROR"):
+			return fmt.Errorf("server replied %q", line)
+		}
+		first = false
+
+		key, ok := parseMetadumpLine(line)
+		if !ok {
+			continue
+		}
+		key.Server = ks.server
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+}
+
+// parseMetadumpLine parses a line such as
+// "key=user%3A1 exp=-1 la=1700000000 cas=7 fetch=no cls=1 size=68".
+// Keys are URI encoded by the server.
+func parseMetadumpLine(line string) (models.KeyInfo, bool) {
+	var info models.KeyInfo
+	for _, field := range strings.Fields(line) {
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+
+		var err error
+		switch name {
+		case "key":
+			info.Key, err = url.PathUnescape(value)
+		case "exp":
+			info.Expiration, err = strconv.ParseInt(value, 10, 64)
+		case "la":
+			info.LastAccess, err = strconv.ParseInt(value, 10, 64)
+		case "cls":
+			info.SlabClass, err = strconv.Atoi(value)
+		case "size":
+			info.Size, err = strconv.Atoi(value)
+		}
+		if err != nil {
+			return info, false
+		}
+	}
+	return info, info.Key != ""
+}
+
+func (ks *keyScanner) stats(cmd string) (map[string]string, error) {
+	if err := ks.send(cmd); err != nil {
+		return nil, err
+	}
+	return readStats(ks.r)
+}
+
+func (ks *keyScanner) cachedump(fn func(models.KeyInfo) error) error {
+	general, err := ks.stats("stats")
+	if err != nil {
+		return err
+	}
+	now := int64(parseUint(general["time"]))
+	// cachedump reports expirations as absolute times based on the process
+	// start, which is also what it prints for items that never expire on
+	// older servers.
+	started := now - int64(parseUint(general["uptime"]))
+
+	items, err := ks.stats("stats items")
+	if err != nil {
+		return err
+	}
+
+	for _, slab := range parseItemStats(items) {
+		if err := ks.send(fmt.Sprintf("stats cachedump %d 0", slab.SlabID)); err != nil {
+			return err
+		}
+
+		for {
+			line, err := ks.readLine()
+			if err != nil {
+				return err
+			}
+			if line == "END" {
+				break
+			}
+			if line == "ERROR" || strings.HasPrefix(line, "CLIENT_ERROR") || strings.HasPrefix(line, "SERVER_ERROR") {
+				return fmt.Errorf("server replied %q", line)
+			}
+
+			key, ok := parseCachedumpLine(line, started)
+			if !ok || (key.Expiration != -1 && key.Expiration <= now) {
+				continue
+			}
+			key.Server = ks.server
+			key.SlabClass = slab.SlabID
+			if err := fn(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseCachedumpLine parses "ITEM <key> [<size> b; <expiration> s]". An
+// expiration of 0 or the process start time means the item never expires.
+// cachedump does not report the last access time.
+func parseCachedumpLine(line string, started int64) (models.KeyInfo, bool) {
+	matches := cacheDumpEntry.FindStringSubmatch(line)
+	if matches == nil {
+		return models.KeyInfo{}, false
+	}
+
+	size, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return models.KeyInfo{}, false
+	}
+	exp, err := strconv.ParseInt(matches[3], 10, 64)
+	if err != nil {
+		return models.KeyInfo{}, false
+	}
+	if exp == 0 || exp == started {
+		exp = -1
+	}
+
+	return models.KeyInfo{Key: matches[1], Expiration: exp, Size: size}, true
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"memcached-management/models"
+)
+
+func TestParseMetadumpLine(t *testing.T) {
+	key, ok := parseMetadumpLine("key=user%3A1%20x exp=1700000100 la=1700000000 cas=7 fetch=no cls=3 size=68")
+	if !ok {
+		t.Fatal("Expected line to parse")
+	}
+	if key.Key != "user:1 x" || key.Expiration != 1700000100 || key.LastAccess != 1700000000 || key.SlabClass != 3 || key.Size != 68 {
+		t.Errorf("Unexpected key info: %+v", key)
+	}
+
+	if _, ok := parseMetadumpLine("exp=-1 la=1 cls=1 size=2"); ok {
+		t.Error("Expected line without a key to be rejected")
+	}
+	if _, ok := parseMetadumpLine("key=a exp=never"); ok {
+		t.Error("Expected malformed expiration to be rejected")
+	}
+}
+
+func TestParseCachedumpLine(t *testing.T) {
+	tests := []struct {
+		line string
+		exp  int64
+		size int
+	}{
+		{"ITEM session:1 [12 b; 1700000500 s]", 1700000500, 12},
+		{"ITEM forever [3 b; 0 s]", -1, 3},
+		{"ITEM legacy [3 b; 1699990000 s]", -1, 3},
+	}
+
+	for _, tt := range tests {
+		key, ok := parseCachedumpLine(tt.line, 1699990000)
+		if !ok {
+			t.Errorf("%q: expected line to parse", tt.line)
+			continue
+		}
+		if key.Expiration != tt.exp || key.Size != tt.size {
+			t.Errorf("%q: got %+v", tt.line, key)
+		}
+	}
+
+	if _, ok := parseCachedumpLine("STAT foo 1", 0); ok {
+		t.Error("Expected non ITEM line to be rejected")
+	}
+}
+
+func TestGetAllKeys_Metadump(t *testing.T) {
+	addr := startFakeServer(t, func(line string, r *bufio.Reader) string {
+		if line == "lru_crawler metadump all" {
+			return "key=a%3A1 exp=-1 la=1700000000 cas=1 fetch=no cls=1 size=60\n" +
+				"key=b exp=1700000300 la=1700000010 cas=2 fetch=yes cls=2 size=120\n" +
+				"END\r\n"
+		}
+		return "ERROR\r\n"
+	})
+
+	service := NewMemcachedService()
+	_ = service.Connect(addr)
+
+	keys, err := service.GetAllKeys()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("Expected 2 keys, got %+v", keys)
+	}
+	if keys[0].Key != "a:1" || keys[0].Expiration != -1 || keys[0].Server != addr {
+		t.Errorf("Unexpected first key: %+v", keys[0])
+	}
+	if keys[1].Key != "b" || keys[1].SlabClass != 2 || keys[1].Size != 120 {
+		t.Errorf("Unexpected second key: %+v", keys[1])
+	}
+}
+
+func TestGetAllKeys_CachedumpFallback(t *testing.T) {
+	addr := startFakeServer(t, func(line string, r *bufio.Reader) string {
+		switch line {
+		case "lru_crawler metadump all":
+			return "ERROR\r\n"
+		case "stats":
+			return "STAT uptime 1000\r\nSTAT time 1700001000\r\nEND\r\n"
+		case "stats items":
+			return "STAT items:1:number 2\r\nSTAT items:4:number 1\r\nEND\r\n"
+		case "stats cachedump 1 0":
+			return "ITEM live [5 b; 1700002000 s]\r\nITEM expired [5 b; 1700000500 s]\r\nEND\r\n"
+		case "stats cachedump 4 0":
+			return "ITEM forever [900 b; 1700000000 s]\r\nEND\r\n"
+		}
+		return "ERROR\r\n"
+	})
+
+	service := NewMemcachedService()
+	_ = service.Connect(addr)
+
+	keys, err := service.GetAllKeys()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("Expected expired key to be skipped, got %+v", keys)
+	}
+	if keys[0].Key != "live" || keys[0].SlabClass != 1 || keys[0].Expiration != 1700002000 {
+		t.Errorf("Unexpected first key: %+v", keys[0])
+	}
+	if keys[1].Key != "forever" || keys[1].SlabClass != 4 || keys[1].Expiration != -1 || keys[1].Size != 900 {
+		t.Errorf("Unexpected second key: %+v", keys[1])
+	}
+}
+
+func TestKeyCursor_RoundTrip(t *testing.T) {
+	cursor := KeyCursor{Server: 2, Offset: 1500}
+	parsed, err := ParseKeyCursor(cursor.String())
+	if err != nil || parsed != cursor {
+		t.Errorf("Expected %+v, got %+v (%v)", cursor, parsed, err)
+	}
+
+	if parsed, err := ParseKeyCursor(""); err != nil || parsed != (KeyCursor{}) {
+		t.Errorf("Expected empty cursor to start the scan, got %+v (%v)", parsed, err)
+	}
+	for _, token := range []string{"!!", "MTI", "LTE6MA"} {
+		if _, err := ParseKeyCursor(token); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%q: expected ErrInvalidCursor, got %v", token, err)
+		}
+	}
+}
+
+// startMetadumpServer fakes a server whose metadump lists keys in order.
+func startMetadumpServer(t *testing.T, keys ...string) string {
+	cache := newFakeCache()
+	for _, key := range keys {
+		cache.set(key, "")
+	}
+	return startCacheServer(t, cache)
+}
+
+func TestScanKeys_ResumesFromCursor(t *testing.T) {
+	first := startMetadumpServer(t, "a", "b", "c")
+	second := startMetadumpServer(t, "d", "e")
+
+	service := NewMemcachedService()
+	_ = service.Connect(first, second)
+
+	var keys []string
+	var positions []KeyCursor
+	err := service.ScanKeys(context.Background(), KeyCursor{Server: 0, Offset: 2}, nil, func(key models.KeyInfo, at KeyCursor) error {
+		keys = append(keys, key.Key)
+		positions = append(positions, at)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if strings.Join(keys, ",") != "c,d,e" {
+		t.Errorf("Expected c,d,e, got %v", keys)
+	}
+	if positions[0] != (KeyCursor{0, 2}) || positions[1] != (KeyCursor{1, 0}) {
+		t.Errorf("Unexpected positions %+v", positions)
+	}
+
+	stop := errors.New("stop")
+	keys = nil
+	err = service.ScanKeys(context.Background(), KeyCursor{}, nil, func(key models.KeyInfo, at KeyCursor) error {
+		keys = append(keys, key.Key)
+		return stop
+	})
+	if !errors.Is(err, stop) || len(keys) != 1 {
+		t.Errorf("Expected the scan to stop after one key, got %v (%v)", keys, err)
+	}
+
+	match, _ := NewKeyMatcher(models.KeyFilter{Glob: "[bd]"})
+	positions = nil
+	err = service.ScanKeys(context.Background(), KeyCursor{}, match, func(key models.KeyInfo, at KeyCursor) error {
+		positions = append(positions, at)
+		return nil
+	})
+	if err != nil || len(positions) != 2 || positions[0] != (KeyCursor{0, 1}) || positions[1] != (KeyCursor{1, 0}) {
+		t.Errorf("Expected filtered keys at their dump positions, got %+v (%v)", positions, err)
+	}
+
+	if err := service.ScanKeys(context.Background(), KeyCursor{Server: 5}, nil, nil); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
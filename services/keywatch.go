@@ -0,0 +1,640 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"memcached-management/models"
+)
+
+const (
+	defaultKeyWatchInterval = 5
+	maxKeyWatchInterval     = 3600
+	// maxKeyWatches bounds the watches of a connection
+	maxKeyWatches = 50
+	// maxWatchedKeys bounds the keys a prefix watch tracks
+	maxWatchedKeys = 100
+	// maxDiscoveredKeys bounds the keys a discovery scan keeps per prefix;
+	// twice maxWatchedKeys always leaves a watch new keys to pick from
+	maxDiscoveredKeys = 2 * maxWatchedKeys
+	// keyDiscoveryInterval is the least time between two scans for the
+	// keys under the watched prefixes; in between, prefix watches only
+	// read the keys they already track
+	keyDiscoveryInterval = 30 * time.Second
+	// maxKeyVersions is how many versions are kept per key
+	maxKeyVersions = 20
+	// maxVersionValue is how much of a value a version keeps
+	maxVersionValue = 16 << 10
+	// keyChangeBuffer is how many changes may wait for a slow subscriber;
+	// further changes are dropped for it
+	keyChangeBuffer = 64
+)
+
+var (
+	ErrInvalidKeyWatch  = errors.New("invalid key watch")
+	ErrKeyWatchNotFound = errors.New("key watch not found")
+)
+
+// errWatchedKeysFull stops a discovery scan once every watched prefix has
+// more keys than it keeps.
+var errWatchedKeysFull = errors.New("watched keys limit reached")
+
+// AddKeyWatch registers a watch that polls a key, or the keys under a
+// prefix, until it is removed or the connection changes.
+func (s *MemcachedService) AddKeyWatch(req models.KeyWatchRequest) (models.KeyWatch, error) {
+	set, err := s.keyWatchSet()
+	if err != nil {
+		return models.KeyWatch{}, err
+	}
+	return set.Add(req)
+}
+
+// KeyWatches returns the watches of the current connection.
+func (s *MemcachedService) KeyWatches() []models.KeyWatch {
+	s.mu.RLock()
+	set := s.keyWatches
+	s.mu.RUnlock()
+	if set == nil {
+		return []models.KeyWatch{}
+	}
+	return set.List()
+}
+
+// KeyWatchHistory returns a watch with the recorded versions of its keys.
+func (s *MemcachedService) KeyWatchHistory(id string) (models.KeyWatch, []models.KeyHistory, error) {
+	s.mu.RLock()
+	set := s.keyWatches
+	s.mu.RUnlock()
+	if set == nil {
+		return models.KeyWatch{}, nil, ErrKeyWatchNotFound
+	}
+	return set.History(id)
+}
+
+func (s *MemcachedService) RemoveKeyWatch(id string) error {
+	s.mu.RLock()
+	set := s.keyWatches
+	s.mu.RUnlock()
+	if set == nil {
+		return ErrKeyWatchNotFound
+	}
+	return set.Remove(id)
+}
+
+// SubscribeKeyChanges returns a channel of the changes found by the
+// watches of the current connection, and a function to unsubscribe. The
+// channel is closed when the connection changes.
+func (s *MemcachedService) SubscribeKeyChanges() (<-chan models.KeyChange, func(), error) {
+	set, err := s.keyWatchSet()
+	if err != nil {
+		return nil, nil, err
+	}
+	changes, unsubscribe := set.Subscribe()
+	return changes, unsubscribe, nil
+}
+
+func (s *MemcachedService) keyWatchSet() (*KeyWatchSet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client == nil {
+		return nil, fmt.Errorf("not connected to Memcached")
+	}
+	if s.keyWatches == nil {
+		s.keyWatches = newKeyWatchSet(s.connState)
+	}
+	return s.keyWatches, nil
+}
+
+// KeyWatchSet polls the watched keys of one connection with meta gets,
+// records a version whenever a key's CAS id or value changes, and fans the
+// changes out to subscribers.
+type KeyWatchSet struct {
+	st  connState
+	now func() time.Time
+
+	mu          sync.Mutex
+	watches     map[string]*keyWatch
+	subscribers map[chan models.KeyChange]struct{}
+	closed      bool
+
+	discovery keyDiscovery
+}
+
+// keyDiscovery is the last scan for the keys under the watched prefixes,
+// shared by the prefix watches of a set.
+type keyDiscovery struct {
+	mu   sync.Mutex
+	gen  uint64
+	time time.Time
+	// keys holds up to maxDiscoveredKeys keys found under each prefix
+	// the scan covered
+	keys map[string][]string
+	// overflow marks the prefixes with more keys than that
+	overflow map[string]bool
+}
+
+type keyWatch struct {
+	status models.KeyWatch
+	cancel context.CancelFunc
+	// polled is set after the first poll; keys found before it are
+	// observed rather than created
+	polled bool
+	// discovered is the discovery scan whose keys the watch last read;
+	// only the poller touches it
+	discovered uint64
+	keys       map[string]*keyState
+}
+
+type keyState struct {
+	present  bool
+	cas      uint64
+	sum      uint64
+	versions []models.KeyVersion
+}
+
+// keyObservation is what one poll read for a key.
+type keyObservation struct {
+	present bool
+	value   []byte
+	cas     uint64
+	meta    *models.ItemMeta
+}
+
+func newKeyWatchSet(st connState) *KeyWatchSet {
+	return &KeyWatchSet{
+		st:          st,
+		now:         time.Now,
+		watches:     make(map[string]*keyWatch),
+		subscribers: make(map[chan models.KeyChange]struct{}),
+	}
+}
+
+// Add validates req and starts polling it.
+func (ks *KeyWatchSet) Add(req models.KeyWatchRequest) (models.KeyWatch, error) {
+	if req.IntervalSeconds == 0 {
+		req.IntervalSeconds = defaultKeyWatchInterval
+	}
+	switch {
+	case (req.Key == "") == (req.Prefix == ""):
+		return models.KeyWatch{}, fmt.Errorf("%w: either a key or a prefix is required", ErrInvalidKeyWatch)
+	case !legalKey(req.Key + req.Prefix):
+		return models.KeyWatch{}, fmt.Errorf("%w: %v", ErrInvalidKeyWatch, memcache.ErrMalformedKey)
+	case req.IntervalSeconds < 1 || req.IntervalSeconds > maxKeyWatchInterval:
+		return models.KeyWatch{}, fmt.Errorf("%w: interval must be between 1 and %d seconds", ErrInvalidKeyWatch, maxKeyWatchInterval)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &keyWatch{
+		status: models.KeyWatch{
+			ID:              newJobID(),
+			Key:             req.Key,
+			Prefix:          req.Prefix,
+			IntervalSeconds: req.IntervalSeconds,
+			CreatedAt:       ks.now(),
+		},
+		cancel: cancel,
+		keys:   make(map[string]*keyState),
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if ks.closed {
+		cancel()
+		return models.KeyWatch{}, fmt.Errorf("not connected to Memcached")
+	}
+	if len(ks.watches) >= maxKeyWatches {
+		cancel()
+		return models.KeyWatch{}, fmt.Errorf("%w: at most %d watches are allowed", ErrInvalidKeyWatch, maxKeyWatches)
+	}
+	ks.watches[w.status.ID] = w
+
+	go ks.run(ctx, w)
+	return w.snapshot(), nil
+}
+
+// List returns the watches, oldest first.
+func (ks *KeyWatchSet) List() []models.KeyWatch {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	watches := make([]models.KeyWatch, 0, len(ks.watches))
+	for _, w := range ks.watches {
+		watches = append(watches, w.snapshot())
+	}
+	sort.Slice(watches, func(i, j int) bool {
+		if !watches[i].CreatedAt.Equal(watches[j].CreatedAt) {
+			return watches[i].CreatedAt.Before(watches[j].CreatedAt)
+		}
+		return watches[i].ID < watches[j].ID
+	})
+	return watches
+}
+
+// History returns a watch and the versions of its keys, sorted by key.
+func (ks *KeyWatchSet) History(id string) (models.KeyWatch, []models.KeyHistory, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	w, ok := ks.watches[id]
+	if !ok {
+		return models.KeyWatch{}, nil, ErrKeyWatchNotFound
+	}
+	history := []models.KeyHistory{}
+	for key, state := range w.keys {
+		if len(state.versions) == 0 {
+			continue
+		}
+		versions := append([]models.KeyVersion(nil), state.versions...)
+		history = append(history, models.KeyHistory{Key: key, Versions: versions})
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].Key < history[j].Key })
+	return w.snapshot(), history, nil
+}
+
+// Remove stops a watch and drops its history.
+func (ks *KeyWatchSet) Remove(id string) error {
+	ks.mu.Lock()
+	w, ok := ks.watches[id]
+	delete(ks.watches, id)
+	ks.mu.Unlock()
+
+	if !ok {
+		return ErrKeyWatchNotFound
+	}
+	w.cancel()
+	return nil
+}
+
+// Subscribe returns a channel of the changes found from now on. Changes are
+// dropped for a subscriber that falls keyChangeBuffer changes behind.
+func (ks *KeyWatchSet) Subscribe() (<-chan models.KeyChange, func()) {
+	ch := make(chan models.KeyChange, keyChangeBuffer)
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if ks.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	ks.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		ks.mu.Lock()
+		defer ks.mu.Unlock()
+		if _, ok := ks.subscribers[ch]; ok {
+			delete(ks.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Stop ends every watch and closes the subscriber channels.
+func (ks *KeyWatchSet) Stop() {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.closed = true
+	for id, w := range ks.watches {
+		w.cancel()
+		delete(ks.watches, id)
+	}
+	for ch := range ks.subscribers {
+		delete(ks.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (ks *KeyWatchSet) run(ctx context.Context, w *keyWatch) {
+	ticker := time.NewTicker(time.Duration(w.status.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		ks.poll(ctx, w)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll reads the keys of w and records what changed since the last poll.
+// A prefix watch reads the keys it already tracks plus the new keys of the
+// latest discovery scan, so deleted and expired keys are noticed too.
+func (ks *KeyWatchSet) poll(ctx context.Context, w *keyWatch) {
+	keys := []string{w.status.Key}
+	truncated := false
+	if w.status.Prefix != "" {
+		var err error
+		if keys, truncated, err = ks.prefixKeys(ctx, w); err != nil {
+			ks.pollFailed(w, err)
+			return
+		}
+	}
+
+	conns := make(map[string]*metaConn)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	observed := make(map[string]keyObservation, len(keys))
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return
+		}
+		obs, err := ks.read(conns, key)
+		if err != nil {
+			ks.pollFailed(w, fmt.Errorf("%s: %w", key, err))
+			return
+		}
+		observed[key] = obs
+	}
+	ks.record(w, observed, truncated)
+}
+
+// prefixKeys returns the keys a prefix watch reads: the ones it tracks as
+// present and, once per discovery scan, new ones the scan found, as long as
+// the watch has room.
+func (ks *KeyWatchSet) prefixKeys(ctx context.Context, w *keyWatch) ([]string, bool, error) {
+	found, overflow, gen, err := ks.discover(ctx, w.status.Prefix)
+	if err != nil {
+		return nil, false, err
+	}
+
+	seen := make(map[string]bool)
+	var keys []string
+	ks.mu.Lock()
+	for key, state := range w.keys {
+		if state.present {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	truncated := w.status.Truncated
+	ks.mu.Unlock()
+
+	if gen == w.discovered {
+		return keys, truncated, nil
+	}
+	w.discovered = gen
+	truncated = overflow
+	for _, key := range found {
+		if seen[key] {
+			continue
+		}
+		if len(keys) >= maxWatchedKeys {
+			truncated = true
+			break
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys, truncated, nil
+}
+
+// discover returns the keys the last discovery scan found under prefix and
+// the scan's generation. The keys of every watched prefix are scanned again
+// when that scan is older than keyDiscoveryInterval or did not cover prefix,
+// so the prefix watches of a set share one metadump.
+func (ks *KeyWatchSet) discover(ctx context.Context, prefix string) ([]string, bool, uint64, error) {
+	d := &ks.discovery
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, covered := d.keys[prefix]; !covered || ks.now().Sub(d.time) >= keyDiscoveryInterval {
+		prefixes := map[string]bool{prefix: true}
+		ks.mu.Lock()
+		for _, w := range ks.watches {
+			if w.status.Prefix != "" {
+				prefixes[w.status.Prefix] = true
+			}
+		}
+		ks.mu.Unlock()
+
+		keys := make(map[string][]string, len(prefixes))
+		for p := range prefixes {
+			keys[p] = nil
+		}
+		overflow := make(map[string]bool)
+		err := ks.st.scanKeys(ctx, KeyCursor{}, nil, func(key models.KeyInfo, _ KeyCursor) error {
+			for p := range prefixes {
+				switch {
+				case overflow[p] || !strings.HasPrefix(key.Key, p):
+				case len(keys[p]) >= maxDiscoveredKeys:
+					overflow[p] = true
+				default:
+					keys[p] = append(keys[p], key.Key)
+				}
+			}
+			if len(overflow) == len(prefixes) {
+				return errWatchedKeysFull
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errWatchedKeysFull) {
+			return nil, false, 0, err
+		}
+		d.gen++
+		d.time = ks.now()
+		d.keys, d.overflow = keys, overflow
+	}
+
+	return d.keys[prefix], d.overflow[prefix], d.gen, nil
+}
+
+// read fetches key with a meta get on the server that owns it, reusing
+// conns across the keys of a poll. Servers without the meta protocol are
+// read with gets, which has no TTL or last access.
+func (ks *KeyWatchSet) read(conns map[string]*metaConn, key string) (keyObservation, error) {
+	server, err := ks.st.pickServer(key)
+	if err != nil {
+		return keyObservation{}, err
+	}
+	conn, ok := conns[server]
+	if !ok {
+		if conn, err = dialMeta(server, ks.st.timeout); err != nil {
+			return keyObservation{}, err
+		}
+		conns[server] = conn
+	}
+
+	value, meta, err := conn.get(key)
+	if errors.Is(err, errMetaUnsupported) {
+		item, err := ks.st.client.Get(key)
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			return keyObservation{}, nil
+		}
+		if err != nil {
+			return keyObservation{}, err
+		}
+		return keyObservation{present: true, value: item.Value, cas: item.CasID}, nil
+	}
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return keyObservation{}, nil
+	}
+	if err != nil {
+		return keyObservation{}, err
+	}
+	return keyObservation{present: true, value: value, cas: meta.CAS, meta: &meta}, nil
+}
+
+// record compares a poll with the known state of the keys of w, stores a
+// version for every key that was created, modified or disappeared and
+// notifies the subscribers.
+func (ks *KeyWatchSet) record(w *keyWatch, observed map[string]keyObservation, truncated bool) {
+	now := ks.now()
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if ks.watches[w.status.ID] != w {
+		// Removed during the poll.
+		return
+	}
+
+	keys := make([]string, 0, len(observed))
+	for key := range observed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		obs := observed[key]
+		sum := valueSum(obs)
+		state, known := w.keys[key]
+
+		var event string
+		switch {
+		case !known && !obs.present:
+			// A missing key is remembered so its creation is reported.
+			if w.status.Key != "" {
+				w.keys[key] = &keyState{}
+			}
+			continue
+		case !known:
+			if !w.makeRoom() {
+				truncated = true
+				continue
+			}
+			event = models.KeyCreated
+			if !w.polled {
+				event = models.KeyObserved
+			}
+			state = &keyState{}
+			w.keys[key] = state
+		case state.present && !obs.present:
+			event = models.KeyDisappeared
+		case !state.present && obs.present:
+			event = models.KeyCreated
+		case obs.present && (obs.cas != state.cas || sum != state.sum):
+			event = models.KeyModified
+		default:
+			continue
+		}
+
+		version := newKeyVersion(now, event, obs)
+		state.present, state.cas, state.sum = obs.present, obs.cas, sum
+		state.versions = append(state.versions, version)
+		if len(state.versions) > maxKeyVersions {
+			state.versions = state.versions[len(state.versions)-maxKeyVersions:]
+		}
+		w.status.Changes++
+		ks.broadcast(models.KeyChange{WatchID: w.status.ID, Key: key, KeyVersion: version})
+	}
+
+	w.polled = true
+	w.status.LastPoll = &now
+	w.status.Truncated = truncated
+	w.status.Error = ""
+}
+
+func (ks *KeyWatchSet) pollFailed(w *keyWatch, err error) {
+	// The next poll reads the keys of the last discovery scan again.
+	w.discovered = 0
+	now := ks.now()
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	w.status.LastPoll = &now
+	w.status.Error = err.Error()
+}
+
+// broadcast sends change to every subscriber with room for it. The caller
+// holds ks.mu.
+func (ks *KeyWatchSet) broadcast(change models.KeyChange) {
+	for ch := range ks.subscribers {
+		select {
+		case ch <- change:
+		default:
+		}
+	}
+}
+
+// makeRoom reports whether w can track another key, forgetting the key
+// that disappeared longest ago when it is full. The caller holds ks.mu.
+func (w *keyWatch) makeRoom() bool {
+	if len(w.keys) < maxWatchedKeys {
+		return true
+	}
+	oldest := ""
+	var oldestTime time.Time
+	for key, state := range w.keys {
+		if state.present || len(state.versions) == 0 {
+			continue
+		}
+		if t := state.versions[len(state.versions)-1].Time; oldest == "" || t.Before(oldestTime) {
+			oldest, oldestTime = key, t
+		}
+	}
+	if oldest == "" {
+		return false
+	}
+	delete(w.keys, oldest)
+	return true
+}
+
+// snapshot returns the status of w. The caller holds ks.mu.
+func (w *keyWatch) snapshot() models.KeyWatch {
+	status := w.status
+	status.Keys = len(w.keys)
+	return status
+}
+
+func newKeyVersion(now time.Time, event string, obs keyObservation) models.KeyVersion {
+	version := models.KeyVersion{Time: now, Event: event, Meta: obs.meta}
+	if !obs.present {
+		return version
+	}
+
+	value := obs.value
+	if len(value) > maxVersionValue {
+		// Cut at a character boundary so text stays text.
+		n := maxVersionValue
+		for n > 0 && !utf8.RuneStart(value[n]) {
+			n--
+		}
+		value = value[:n]
+		version.Truncated = true
+	}
+	version.Value, version.Encoding = EncodeValue(value)
+	return version
+}
+
+// valueSum hashes a value so changes are noticed on servers that run
+// without CAS ids.
+func valueSum(obs keyObservation) uint64 {
+	if !obs.present {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write(obs.value)
+	return h.Sum64()
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"memcached-management/models"
+)
+
+// newTestKeyWatch registers a watch on a set connected to addr without
+// starting its poller, so tests poll by hand.
+func newTestKeyWatch(t *testing.T, addr string, req models.KeyWatchRequest) (*KeyWatchSet, *keyWatch) {
+	t.Helper()
+	service := NewMemcachedService()
+	if err := service.Connect(addr); err != nil {
+		t.Fatal(err)
+	}
+	st, _ := service.state()
+	ks := newKeyWatchSet(st)
+	return ks, addTestKeyWatch(ks, "w1", req)
+}
+
+func addTestKeyWatch(ks *KeyWatchSet, id string, req models.KeyWatchRequest) *keyWatch {
+	w := &keyWatch{
+		status: models.KeyWatch{ID: id, Key: req.Key, Prefix: req.Prefix, IntervalSeconds: 1},
+		cancel: func() {},
+		keys:   make(map[string]*keyState),
+	}
+	ks.watches[w.status.ID] = w
+	return w
+}
+
+func drainChanges(changes <-chan models.KeyChange) []string {
+	var events []string
+	for {
+		select {
+		case change := <-changes:
+			events = append(events, change.Key+" "+change.Event+" "+change.Value)
+		default:
+			return events
+		}
+	}
+}
+
+func TestKeyWatch_Key(t *testing.T) {
+	store := newFakeCache()
+	ks, w := newTestKeyWatch(t, startCacheServer(t, store), models.KeyWatchRequest{Key: "config"})
+	changes, unsubscribe := ks.Subscribe()
+	defer unsubscribe()
+	ctx := context.Background()
+
+	ks.poll(ctx, w)
+	store.set("config", "v1")
+	ks.poll(ctx, w)
+	ks.poll(ctx, w)
+	store.set("config", "v2")
+	ks.poll(ctx, w)
+	store.delete("config")
+	ks.poll(ctx, w)
+
+	expected := []string{"config created v1", "config modified v2", "config disappeared "}
+	if events := drainChanges(changes); strings.Join(events, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %q, got %q", expected, events)
+	}
+
+	watch, history, err := ks.History("w1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if watch.Changes != 3 || watch.Keys != 1 || watch.LastPoll == nil || watch.Error != "" {
+		t.Errorf("Unexpected watch %+v", watch)
+	}
+	if len(history) != 1 || len(history[0].Versions) != 3 {
+		t.Fatalf("Expected 3 versions, got %+v", history)
+	}
+	if v := history[0].Versions[1]; v.Meta == nil || v.Meta.CAS != 2 || v.Meta.TTL != -1 {
+		t.Errorf("Expected the version to carry its meta, got %+v", v)
+	}
+}
+
+func TestKeyWatch_Prefix(t *testing.T) {
+	store := newFakeCache()
+	store.set("user:1", "a")
+	store.set("other:1", "x")
+	ks, w := newTestKeyWatch(t, startCacheServer(t, store), models.KeyWatchRequest{Prefix: "user:"})
+	now := time.Now()
+	ks.now = func() time.Time { return now }
+	changes, unsubscribe := ks.Subscribe()
+	defer unsubscribe()
+	ctx := context.Background()
+
+	ks.poll(ctx, w)
+	store.set("user:2", "b")
+	store.set("other:1", "y")
+	store.delete("user:1")
+	ks.poll(ctx, w)
+	now = now.Add(keyDiscoveryInterval)
+	ks.poll(ctx, w)
+
+	// New keys wait for the next discovery scan; known ones are read on
+	// every poll.
+	expected := []string{"user:1 observed a", "user:1 disappeared ", "user:2 created b"}
+	if events := drainChanges(changes); strings.Join(events, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %q, got %q", expected, events)
+	}
+}
+
+func TestKeyWatch_SharedDiscovery(t *testing.T) {
+	store := newFakeCache()
+	store.set("user:1", "a")
+	store.set("order:1", "x")
+	ks, users := newTestKeyWatch(t, startCacheServer(t, store), models.KeyWatchRequest{Prefix: "user:"})
+	orders := addTestKeyWatch(ks, "w2", models.KeyWatchRequest{Prefix: "order:"})
+	now := time.Now()
+	ks.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		ks.poll(ctx, users)
+		ks.poll(ctx, orders)
+	}
+	if store.scans != 1 {
+		t.Errorf("Expected the watches to share one scan, got %d", store.scans)
+	}
+
+	now = now.Add(keyDiscoveryInterval)
+	ks.poll(ctx, users)
+	ks.poll(ctx, orders)
+	if store.scans != 2 {
+		t.Errorf("Expected one more scan after the interval, got %d", store.scans)
+	}
+
+	for _, w := range []*keyWatch{users, orders} {
+		watch, _, err := ks.History(w.status.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if watch.Keys != 1 || watch.Error != "" {
+			t.Errorf("Unexpected watch %+v", watch)
+		}
+	}
+}
+
+func TestKeyWatch_WithoutMeta(t *testing.T) {
+	store := newFakeCache()
+	store.noMeta, store.noCAS = true, true
+	store.set("config", "v1")
+	ks, w := newTestKeyWatch(t, startCacheServer(t, store), models.KeyWatchRequest{Key: "config"})
+	changes, unsubscribe := ks.Subscribe()
+	defer unsubscribe()
+	ctx := context.Background()
+
+	ks.poll(ctx, w)
+	ks.poll(ctx, w)
+	store.set("config", "v2")
+	ks.poll(ctx, w)
+
+	// Without CAS ids changes are found by comparing values.
+	expected := []string{"config observed v1", "config modified v2"}
+	if events := drainChanges(changes); strings.Join(events, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %q, got %q", expected, events)
+	}
+}
+
+func TestKeyWatchSet_Lifecycle(t *testing.T) {
+	ks := newKeyWatchSet(connState{})
+	for _, req := range []models.KeyWatchRequest{
+		{},
+		{Key: "a", Prefix: "b"},
+		{Key: "bad key"},
+		{Key: "a", IntervalSeconds: -1},
+		{Key: "a", IntervalSeconds: 7200},
+	} {
+		if _, err := ks.Add(req); !errors.Is(err, ErrInvalidKeyWatch) {
+			t.Errorf("%+v: expected ErrInvalidKeyWatch, got %v", req, err)
+		}
+	}
+	if err := ks.Remove("missing"); !errors.Is(err, ErrKeyWatchNotFound) {
+		t.Errorf("Expected ErrKeyWatchNotFound, got %v", err)
+	}
+
+	changes, _ := ks.Subscribe()
+	ks.Stop()
+	if _, ok := <-changes; ok {
+		t.Error("Expected Stop to close the subscriber channel")
+	}
+	if _, err := ks.Add(models.KeyWatchRequest{Key: "a"}); err == nil {
+		t.Error("Expected an error adding to a stopped set")
+	}
+}
+
+func TestAddKeyWatch(t *testing.T) {
+	store := newFakeCache()
+	store.set("config", "v1")
+	addr := startCacheServer(t, store)
+
+	service := NewMemcachedService()
+	if _, err := service.AddKeyWatch(models.KeyWatchRequest{Key: "config"}); err == nil {
+		t.Error("Expected an error when not connected")
+	}
+	if err := service.Connect(addr); err != nil {
+		t.Fatal(err)
+	}
+	changes, _, err := service.SubscribeKeyChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	watch, err := service.AddKeyWatch(models.KeyWatchRequest{Key: "config"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if watch.IntervalSeconds != defaultKeyWatchInterval || len(service.KeyWatches()) != 1 {
+		t.Errorf("Unexpected watch %+v", watch)
+	}
+
+	select {
+	case change := <-changes:
+		if change.WatchID != watch.ID || change.Event != models.KeyObserved {
+			t.Errorf("Unexpected change %+v", change)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the first poll")
+	}
+
+	if err := service.RemoveKeyWatch(watch.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := service.KeyWatchHistory(watch.ID); !errors.Is(err, ErrKeyWatchNotFound) {
+		t.Errorf("Expected ErrKeyWatchNotFound, got %v", err)
+	}
+
+	service.Disconnect()
+	if _, ok := <-changes; ok {
+		t.Error("Expected disconnect to close the subscriber channel")
+	}
+	if watches := service.KeyWatches(); len(watches) != 0 {
+		t.Errorf("Expected no watches after disconnect, got %+v", watches)
+	}
+}
+
+func TestNewKeyVersion_Truncates(t *testing.T) {
+	value := strings.Repeat("a", maxVersionValue-1) + "é"
+	version := newKeyVersion(time.Now(), models.KeyCreated, keyObservation{present: true, value: []byte(value + "tail")})
+	if !version.Truncated || version.Encoding != "" || len(version.Value) != maxVersionValue-1 {
+		t.Errorf("Expected the value cut before the split character, got %d bytes, encoding %q", len(version.Value), version.Encoding)
+	}
+}
@@ -1,36 +1,207 @@
 package services
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net"
-	"regexp"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bradfitz/gomemcache/memcache"
+	"memcached-management/models"
 )
 
+const defaultTimeout = 5 * time.Second
+
+// ErrCASConflict means a compare-and-swap failed because the item changed
+// since its CAS id was read.
+var ErrCASConflict = memcache.ErrCASConflict
+
 type MemcachedService struct {
-	client *memcache.Client
-	host   string
+	mu sync.RWMutex
+	connState
+	// hotKeys is the last hot key analysis, stopped with the connection
+	hotKeys *HotKeyAnalyzer
+	// keyWatches polls the watched keys of the connection
+	keyWatches *KeyWatchSet
+}
+
+// connState is the connection a MemcachedService is bound to. Operations
+// work on a copy so a concurrent Connect never changes servers mid-request.
+type connState struct {
+	client   *memcache.Client
+	selector *memcache.ServerList
+	servers  []string
+	timeout  time.Duration
+	readOnly bool
+	// addrNames maps resolved selector addresses back to configured names
+	addrNames map[string]string
 }
 
 func NewMemcachedService() *MemcachedService {
 	return &MemcachedService{}
 }
 
-func (s *MemcachedService) Connect(url string) error {
-	host := strings.TrimSpace(url)
-	if host == "" {
-		return fmt.Errorf("URL is required")
+// Connect accepts one or more server addresses (each may also be a comma
+// separated list) and distributes keys across them with the same
+// ServerList hashing used by gomemcache clients.
+func (s *MemcachedService) Connect(urls ...string) error {
+	return s.ConnectProfile(models.Profile{Servers: urls})
+}
+
+// ConnectProfile connects using the servers, timeout and read-only flag of
+// a connection profile.
+func (s *MemcachedService) ConnectProfile(profile models.Profile) error {
+	servers, err := parseServers(profile.Servers)
+	if err != nil {
+		return err
 	}
-	
+
+	selector := new(memcache.ServerList)
+	if err := selector.SetServers(servers...); err != nil {
+		return fmt.Errorf("invalid server list: %v", err)
+	}
+
+	timeout := defaultTimeout
+	if profile.TimeoutMS > 0 {
+		timeout = time.Duration(profile.TimeoutMS) * time.Millisecond
+	}
+
+	addrNames := make(map[string]string, len(servers))
+	i := 0
+	selector.Each(func(addr net.Addr) error {
+		addrNames[addr.String()] = servers[i]
+		i++
+		return nil
+	})
+
+	client := memcache.NewFromSelector(selector)
+	client.Timeout = timeout
+
+	s.mu.Lock()
+	previous, hotKeys, keyWatches := s.client, s.hotKeys, s.keyWatches
+	s.connState = connState{
+		client:    client,
+		selector:  selector,
+		servers:   servers,
+		timeout:   timeout,
+		readOnly:  profile.ReadOnly,
+		addrNames: addrNames,
+	}
+	s.hotKeys, s.keyWatches = nil, nil
+	s.mu.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+	if hotKeys != nil {
+		hotKeys.Stop()
+	}
+	if keyWatches != nil {
+		keyWatches.Stop()
+	}
+
+	return client.Ping()
+}
+
+// Disconnect drops the current connection and closes its idle sockets.
+func (s *MemcachedService) Disconnect() {
+	s.mu.Lock()
+	previous, hotKeys, keyWatches := s.client, s.hotKeys, s.keyWatches
+	s.connState = connState{}
+	s.hotKeys, s.keyWatches = nil, nil
+	s.mu.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+	if hotKeys != nil {
+		hotKeys.Stop()
+	}
+	if keyWatches != nil {
+		keyWatches.Stop()
+	}
+}
+
+// Servers returns the normalized server addresses of the current connection.
+func (s *MemcachedService) Servers() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.servers...)
+}
+
+func (s *MemcachedService) state() (connState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.client == nil {
+		return connState{}, fmt.Errorf("not connected to Memcached")
+	}
+	return s.connState, nil
+}
+
+// pickServer returns the configured server that owns key under the
+// ServerList hashing, so raw protocol commands reach the same node as the
+// client would.
+func (st connState) pickServer(key string) (string, error) {
+	addr, err := st.selector.PickServer(key)
+	if err != nil {
+		return "", err
+	}
+	if name, ok := st.addrNames[addr.String()]; ok {
+		return name, nil
+	}
+	return addr.String(), nil
+}
+
+func (s *MemcachedService) writableState() (connState, error) {
+	st, err := s.state()
+	if err != nil {
+		return st, err
+	}
+	if st.readOnly {
+		return st, fmt.Errorf("connection is read-only")
+	}
+	return st, nil
+}
+
+func parseServers(urls []string) ([]string, error) {
+	var servers []string
+	seen := make(map[string]bool)
+
+	for _, url := range urls {
+		for _, part := range strings.Split(url, ",") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+
+			host, err := normalizeHost(part)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[host] {
+				seen[host] = true
+				servers = append(servers, host)
+			}
+		}
+	}
+
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("URL is required")
+	}
+
+	return servers, nil
+}
+
+func normalizeHost(url string) (string, error) {
+	host := strings.TrimSpace(url)
 	if strings.Contains(host, "://") {
-		return fmt.Errorf("invalid URL format")
+		return "", fmt.Errorf("invalid URL format")
 	}
-	
+
 	if !strings.Contains(host, ":") {
 		host += ":11211"
 	}
@@ -40,52 +211,117 @@ func (s *MemcachedService) Connect(url string) error {
 		host = strings.Replace(host, "memcached:", "localhost:", 1)
 	}
 
-	s.host = host
-	s.client = memcache.New(host)
-	s.client.Timeout = 5 * time.Second
-
-	return s.client.Ping()
+	return host, nil
 }
 
 func (s *MemcachedService) IsConnected() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.client != nil
 }
 
-func (s *MemcachedService) Set(key, value string) error {
-	if s.client == nil {
-		return fmt.Errorf("not connected to Memcached")
+// IsReadOnly reports whether write operations are disabled for the
+// current connection.
+func (s *MemcachedService) IsReadOnly() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readOnly
+}
+
+// Set stores value under key with the given client flags. ttl follows
+// memcached's rules: 0 never expires, up to 30 days is relative to now and
+// anything larger is an absolute unix timestamp.
+func (s *MemcachedService) Set(key, value string, flags uint32, ttl int64) error {
+	st, err := s.writableState()
+	if err != nil {
+		return err
 	}
-	
+
+	item, err := newItem(key, value, flags, ttl)
+	if err != nil {
+		return err
+	}
+	return st.client.Set(item)
+}
+
+// CompareAndSwap stores value only if the item still has the CAS id it was
+// read with. ErrCASConflict is returned when the item was modified or
+// deleted in between.
+func (s *MemcachedService) CompareAndSwap(key, value string, flags uint32, ttl int64, cas uint64) error {
+	st, err := s.writableState()
+	if err != nil {
+		return err
+	}
+
+	item, err := newItem(key, value, flags, ttl)
+	if err != nil {
+		return err
+	}
+	item.CasID = cas
+
+	err = st.client.CompareAndSwap(item)
+	if errors.Is(err, memcache.ErrCacheMiss) || errors.Is(err, memcache.ErrNotStored) {
+		return fmt.Errorf("%w: item no longer exists", ErrCASConflict)
+	}
+	return err
+}
+
+func newItem(key, value string, flags uint32, ttl int64) (*memcache.Item, error) {
 	key = strings.TrimSpace(key)
-	value = strings.TrimSpace(value)
 	
 	if key == "" || value == "" {
-		return fmt.Errorf("key and value are required")
+		return nil, fmt.Errorf("key and value are required")
 	}
 	
 	if len(key) > 250 {
-		return fmt.Errorf("key too long (max 250 characters)")
+		return nil, fmt.Errorf("key too long (max 250 characters)")
+	}
+
+	expiration, err := ValidateExpiration(ttl, time.Now())
+	if err != nil {
+		return nil, err
 	}
 
-	item := &memcache.Item{Key: key, Value: []byte(value)}
-	return s.client.Set(item)
+	return &memcache.Item{Key: key, Value: []byte(value), Flags: flags, Expiration: expiration}, nil
+}
+
+// maxRelativeTTL is the largest expiration memcached treats as relative to
+// now; larger values are read as absolute unix timestamps.
+const maxRelativeTTL = 30 * 24 * 60 * 60
+
+// ValidateExpiration checks ttl against memcached's 30-day rule and returns
+// the value to send on the wire.
+func ValidateExpiration(ttl int64, now time.Time) (int32, error) {
+	switch {
+	case ttl < 0:
+		return 0, fmt.Errorf("TTL must not be negative")
+	case ttl <= maxRelativeTTL:
+		return int32(ttl), nil
+	case ttl > math.MaxInt32:
+		return 0, fmt.Errorf("TTL %d is beyond the largest unix timestamp memcached accepts", ttl)
+	case ttl <= now.Unix():
+		return 0, fmt.Errorf("TTL over 30 days is read as a unix timestamp and %d is in the past", ttl)
+	}
+	return int32(ttl), nil
 }
 
 func (s *MemcachedService) Get(key string) (*memcache.Item, error) {
-	if s.client == nil {
-		return nil, fmt.Errorf("not connected to Memcached")
+	st, err := s.state()
+	if err != nil {
+		return nil, err
 	}
 	
 	if key == "" {
 		return nil, fmt.Errorf("key is required")
 	}
 
-	return s.client.Get(key)
+	return st.client.Get(key)
 }
 
 func (s *MemcachedService) GetMultiple(keys []string) ([]memcache.Item, error) {
-	if s.client == nil {
-		return nil, fmt.Errorf("not connected to Memcached")
+	st, err := s.state()
+	if err != nil {
+		return nil, err
 	}
 	
 	if len(keys) == 0 {
@@ -94,7 +330,7 @@ func (s *MemcachedService) GetMultiple(keys []string) ([]memcache.Item, error) {
 
 	var items []memcache.Item
 	for _, key := range keys {
-		if item, err := s.client.Get(key); err == nil {
+		if item, err := st.client.Get(key); err == nil {
 			items = append(items, *item)
 		}
 	}
@@ -107,71 +343,58 @@ func (s *MemcachedService) GetMultiple(keys []string) ([]memcache.Item, error) {
 }
 
 func (s *MemcachedService) Delete(key string) error {
-	if s.client == nil {
-		return fmt.Errorf("not connected to Memcached")
+	st, err := s.writableState()
+	if err != nil {
+		return err
 	}
 	
 	if key == "" {
 		return fmt.Errorf("key is required")
 	}
 
-	return s.client.Delete(key)
+	return st.client.Delete(key)
 }
 
 func (s *MemcachedService) FlushAll() error {
-	if s.client == nil {
-		return fmt.Errorf("not connected to Memcached")
+	st, err := s.writableState()
+	if err != nil {
+		return err
 	}
 
-	return s.client.FlushAll()
+	return st.client.FlushAll()
 }
 
-func (s *MemcachedService) GetAllKeys() ([]string, error) {
-	if s.client == nil {
-		return nil, fmt.Errorf("not connected to Memcached")
-	}
-
-	conn, err := net.Dial("tcp", s.host)
+// GetAllKeys dumps the keys of every server in parallel and reports the
+// server, expiration, last access, size and slab class of each key.
+func (s *MemcachedService) GetAllKeys() ([]models.KeyInfo, error) {
+	st, err := s.state()
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect: %v", err)
+		return nil, err
 	}
-	defer conn.Close()
 
-	// Get slab IDs
-	fmt.Fprintf(conn, "stats items\r\n")
-	scanner := bufio.NewScanner(conn)
-	slabIDs := make(map[int]bool)
-	
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "END" {
-			break
-		}
-		re := regexp.MustCompile(`STAT items:(\d+):number`)
-		matches := re.FindStringSubmatch(line)
-		if len(matches) > 1 {
-			if slabID, err := strconv.Atoi(matches[1]); err == nil {
-				slabIDs[slabID] = true
-			}
-		}
+	results := make([][]models.KeyInfo, len(st.servers))
+	errs := make([]error, len(st.servers))
+
+	var wg sync.WaitGroup
+	for i, server := range st.servers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+			errs[i] = scanServerKeys(context.Background(), server, st.timeout, func(key models.KeyInfo) error {
+				results[i] = append(results[i], key)
+				return nil
+			})
+		}(i, server)
 	}
+	wg.Wait()
 
-	// Get keys from each slab
-	var keys []string
-	for slabID := range slabIDs {
-		fmt.Fprintf(conn, "stats cachedump %d 0\r\n", slabID)
-		for scanner.Scan() {
-			line := scanner.Text()
-			if line == "END" {
-				break
-			}
-			re := regexp.MustCompile(`ITEM ([^\s]+)`)
-			matches := re.FindStringSubmatch(line)
-			if len(matches) > 1 {
-				keys = append(keys, matches[1])
-			}
+	var keys []models.KeyInfo
+	for i, server := range st.servers {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("%s: %v", server, errs[i])
 		}
+		keys = append(keys, results[i]...)
 	}
 
 	return keys, nil
-}
\ No newline at end of file
+}
@@ -1,7 +1,13 @@
 package services
 
 import (
+	"bufio"
+	"errors"
+	"strings"
 	"testing"
+	"time"
+
+	"memcached-management/models"
 )
 
 func TestNewMemcachedService(t *testing.T) {
@@ -42,8 +48,8 @@ func TestConnect_HostnameNormalization(t *testing.T) {
 	// Test memcached hostname normalization
 	_ = service.Connect("memcached:11211")
 	// We expect this to fail since memcached isn't running, but the hostname should be normalized
-	if service.host != "localhost:11211" {
-		t.Errorf("Expected host to be normalized to 'localhost:11211', got '%s'", service.host)
+	if len(service.servers) != 1 || service.servers[0] != "localhost:11211" {
+		t.Errorf("Expected host to be normalized to 'localhost:11211', got '%v'", service.servers)
 	}
 }
 
@@ -53,14 +59,43 @@ func TestConnect_DefaultPort(t *testing.T) {
 	// Test default port addition
 	_ = service.Connect("localhost")
 	// We expect this to fail since memcached isn't running, but port should be added
-	if service.host != "localhost:11211" {
-		t.Errorf("Expected host to be 'localhost:11211', got '%s'", service.host)
+	if len(service.servers) != 1 || service.servers[0] != "localhost:11211" {
+		t.Errorf("Expected host to be 'localhost:11211', got '%v'", service.servers)
+	}
+}
+
+func TestConnect_MultipleServers(t *testing.T) {
+	service := NewMemcachedService()
+
+	// Servers may be passed separately or as a comma separated list
+	_ = service.Connect("localhost:11211, memcached:11212", "127.0.0.1", "localhost:11211")
+
+	expected := []string{"localhost:11211", "localhost:11212", "127.0.0.1:11211"}
+	servers := service.Servers()
+	if len(servers) != len(expected) {
+		t.Fatalf("Expected %d servers, got %v", len(expected), servers)
+	}
+	for i, server := range expected {
+		if servers[i] != server {
+			t.Errorf("Expected server %d to be '%s', got '%s'", i, server, servers[i])
+		}
+	}
+}
+
+func TestConnect_EmptyServerList(t *testing.T) {
+	service := NewMemcachedService()
+	err := service.Connect(" , ", "")
+	if err == nil {
+		t.Error("Expected error for empty server list")
+	}
+	if err.Error() != "URL is required" {
+		t.Errorf("Expected 'URL is required', got '%s'", err.Error())
 	}
 }
 
 func TestSet_NotConnected(t *testing.T) {
 	service := NewMemcachedService()
-	err := service.Set("key", "value")
+	err := service.Set("key", "value", 0, 0)
 	if err == nil {
 		t.Error("Expected error when not connected")
 	}
@@ -72,14 +107,14 @@ func TestSet_NotConnected(t *testing.T) {
 func TestSet_EmptyKeyValue(t *testing.T) {
 	service := NewMemcachedService()
 	// Simulate connection
-	service.host = "localhost:11211"
+	service.servers = []string{"localhost:11211"}
 	
-	err := service.Set("", "value")
+	err := service.Set("", "value", 0, 0)
 	if err == nil {
 		t.Error("Expected error for empty key")
 	}
 	
-	err = service.Set("key", "")
+	err = service.Set("key", "", 0, 0)
 	if err == nil {
 		t.Error("Expected error for empty value")
 	}
@@ -93,7 +128,7 @@ func TestSet_KeyTooLong(t *testing.T) {
 		longKey[i] = 'a'
 	}
 	
-	err := service.Set(string(longKey), "value")
+	err := service.Set(string(longKey), "value", 0, 0)
 	if err == nil {
 		t.Error("Expected error for key too long")
 	}
@@ -103,6 +138,60 @@ func TestSet_KeyTooLong(t *testing.T) {
 	}
 }
 
+func TestSet_ReadOnly(t *testing.T) {
+	service := NewMemcachedService()
+	_ = service.ConnectProfile(models.Profile{Servers: []string{"localhost"}, ReadOnly: true})
+
+	if !service.IsReadOnly() {
+		t.Fatal("Expected service to be read-only")
+	}
+
+	for name, err := range map[string]error{
+		"set":    service.Set("key", "value", 0, 0),
+		"delete": service.Delete("key"),
+		"flush":  service.FlushAll(),
+	} {
+		if err == nil || err.Error() != "connection is read-only" {
+			t.Errorf("Expected 'connection is read-only' from %s, got '%v'", name, err)
+		}
+	}
+}
+
+func TestValidateExpiration(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		ttl     int64
+		want    int32
+		wantErr bool
+	}{
+		{ttl: 0, want: 0},
+		{ttl: 60, want: 60},
+		{ttl: 30 * 24 * 60 * 60, want: 30 * 24 * 60 * 60},
+		{ttl: 30*24*60*60 + 1, wantErr: true},
+		{ttl: 1700000000, wantErr: true},
+		{ttl: 1700003600, want: 1700003600},
+		{ttl: -1, wantErr: true},
+		{ttl: 1 << 40, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ValidateExpiration(tt.ttl, now)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Expected error for TTL %d", tt.ttl)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for TTL %d: %v", tt.ttl, err)
+		}
+		if got != tt.want {
+			t.Errorf("Expected expiration %d for TTL %d, got %d", tt.want, tt.ttl, got)
+		}
+	}
+}
+
 func TestGet_NotConnected(t *testing.T) {
 	service := NewMemcachedService()
 	_, err := service.Get("key")
@@ -195,4 +284,42 @@ func TestGetAllKeys_NotConnected(t *testing.T) {
 	if err.Error() != "not connected to Memcached" {
 		t.Errorf("Expected 'not connected to Memcached', got '%s'", err.Error())
 	}
+}
+
+func TestCompareAndSwap_FakeServer(t *testing.T) {
+	addr := startFakeServer(t, func(line string, r *bufio.Reader) string {
+		fields := strings.Fields(line)
+		if len(fields) != 6 || fields[0] != "cas" {
+			return "ERROR\r\n"
+		}
+		r.ReadString('\n') // data block
+		switch fields[5] {
+		case "10":
+			return "STORED\r\n"
+		case "11":
+			return "EXISTS\r\n"
+		}
+		return "NOT_FOUND\r\n"
+	})
+
+	service := NewMemcachedService()
+	_ = service.Connect(addr)
+
+	if err := service.CompareAndSwap("key", "value", 0, 0, 10); err != nil {
+		t.Errorf("Expected swap to succeed, got %v", err)
+	}
+	if err := service.CompareAndSwap("key", "value", 0, 0, 11); !errors.Is(err, ErrCASConflict) {
+		t.Errorf("Expected ErrCASConflict for a modified item, got %v", err)
+	}
+	if err := service.CompareAndSwap("key", "value", 0, 0, 12); !errors.Is(err, ErrCASConflict) {
+		t.Errorf("Expected ErrCASConflict for a deleted item, got %v", err)
+	}
+}
+
+func TestCompareAndSwap_NotConnected(t *testing.T) {
+	service := NewMemcachedService()
+	err := service.CompareAndSwap("key", "value", 0, 0, 1)
+	if err == nil || err.Error() != "not connected to Memcached" {
+		t.Errorf("Expected 'not connected to Memcached', got '%v'", err)
+	}
 }
\ No newline at end of file
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"memcached-management/models"
+)
+
+// errMetaUnsupported is returned when the server does not understand the
+// meta protocol (memcached older than 1.6).
+var errMetaUnsupported = errors.New("server does not support the meta protocol")
+
+// GetWithMeta reads key with the meta protocol (mg <key> v t s f c la) and
+// returns the item together with its remaining TTL, size, flags, CAS id and
+// last access. Servers without meta support fall back to a plain get and a
+// nil ItemMeta.
+func (s *MemcachedService) GetWithMeta(key string) (*memcache.Item, *models.ItemMeta, error) {
+	st, err := s.state()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if key == "" {
+		return nil, nil, fmt.Errorf("key is required")
+	}
+	if !legalKey(key) {
+		return nil, nil, memcache.ErrMalformedKey
+	}
+
+	server, err := st.pickServer(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn, err := dialMeta(server, st.timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+
+	value, meta, err := conn.get(key)
+	if errors.Is(err, errMetaUnsupported) {
+		item, err := st.client.Get(key)
+		return item, nil, err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	item := &memcache.Item{Key: key, Value: value, Flags: meta.Flags, CasID: meta.CAS}
+	return item, &meta, nil
+}
+
+// metaConn is a raw connection to one server for meta protocol commands.
+// Bulk readers keep one open instead of dialing per key.
+type metaConn struct {
+	server  string
+	conn    net.Conn
+	rw      *bufio.ReadWriter
+	timeout time.Duration
+}
+
+func dialMeta(server string, timeout time.Duration) (*metaConn, error) {
+	conn, err := net.DialTimeout("tcp", server, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %v", err)
+	}
+
+	return &metaConn{
+		server:  server,
+		conn:    conn,
+		rw:      bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)),
+		timeout: timeout,
+	}, nil
+}
+
+func (mc *metaConn) Close() error {
+	return mc.conn.Close()
+}
+
+func (mc *metaConn) get(key string) ([]byte, models.ItemMeta, error) {
+	mc.conn.SetDeadline(time.Now().Add(mc.timeout))
+	value, meta, err := metaGet(mc.rw, key)
+	meta.Server = mc.server
+	return value, meta, err
+}
+
+// textGet reads key with a plain get, for servers without the meta
+// protocol. Unlike the client, it asks this server whatever the key hashes
+// to.
+func (mc *metaConn) textGet(key string) ([]byte, uint32, error) {
+	mc.conn.SetDeadline(time.Now().Add(mc.timeout))
+
+	if _, err := fmt.Fprintf(mc.rw, "get %s\r\n", key); err != nil {
+		return nil, 0, err
+	}
+	if err := mc.rw.Flush(); err != nil {
+		return nil, 0, err
+	}
+
+	line, err := mc.rw.ReadString('\n')
+	if err != nil {
+		return nil, 0, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "END" {
+		return nil, 0, memcache.ErrCacheMiss
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[0] != "VALUE" || fields[1] != key {
+		return nil, 0, fmt.Errorf("unexpected get reply %q", line)
+	}
+	flags, err := strconv.ParseUint(fields[2], 10, 32)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unexpected get reply %q", line)
+	}
+	size, err := strconv.Atoi(fields[3])
+	if err != nil || size < 0 {
+		return nil, 0, fmt.Errorf("unexpected get reply %q", line)
+	}
+
+	value := make([]byte, size+2)
+	if _, err := io.ReadFull(mc.rw, value); err != nil {
+		return nil, 0, err
+	}
+	if string(value[size:]) != "\r\n" {
+		return nil, 0, fmt.Errorf("corrupt get reply for %q", key)
+	}
+	end, err := mc.rw.ReadString('\n')
+	if err != nil {
+		return nil, 0, err
+	}
+	if end = strings.TrimRight(end, "\r\n"); end != "END" {
+		return nil, 0, fmt.Errorf("unexpected get reply %q", end)
+	}
+
+	return value[:size], uint32(flags), nil
+}
+
+// metaGet sends one mg command and parses its reply.
+func metaGet(rw *bufio.ReadWriter, key string) ([]byte, models.ItemMeta, error) {
+	var meta models.ItemMeta
+
+	if _, err := fmt.Fprintf(rw, "mg %s v t s f c la\r\n", key); err != nil {
+		return nil, meta, err
+	}
+	if err := rw.Flush(); err != nil {
+		return nil, meta, err
+	}
+
+	line, err := rw.ReadString('\n')
+	if err != nil {
+		return nil, meta, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+
+	switch {
+	case line == "EN":
+		return nil, meta, memcache.ErrCacheMiss
+	case line == "ERROR":
+		return nil, meta, errMetaUnsupported
+	case strings.HasPrefix(line, "CLIENT_ERROR"), strings.HasPrefix(line, "SERVER_ERROR"):
+		return nil, meta, fmt.Errorf("server replied %q", line)
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "VA" {
+		return nil, meta, fmt.Errorf("unexpected meta reply %q", line)
+	}
+	size, err := strconv.Atoi(fields[1])
+	if err != nil || size < 0 {
+		return nil, meta, fmt.Errorf("unexpected meta reply %q", line)
+	}
+	if err := parseMetaFlags(fields[2:], &meta); err != nil {
+		return nil, meta, err
+	}
+
+	value := make([]byte, size+2)
+	if _, err := io.ReadFull(rw, value); err != nil {
+		return nil, meta, err
+	}
+	if string(value[size:]) != "\r\n" {
+		return nil, meta, fmt.Errorf("corrupt meta reply for %q", key)
+	}
+
+	return value[:size], meta, nil
+}
+
+// parseMetaFlags reads the return flags of a meta reply, such as
+// "t-1 s5 f0 c12 l30". memcached reads only the first letter of a request
+// flag, so the "la" of the request comes back as "l".
+func parseMetaFlags(flags []string, meta *models.ItemMeta) error {
+	for _, flag := range flags {
+		var err error
+		switch {
+		case strings.HasPrefix(flag, "l"):
+			meta.LastAccess, err = strconv.ParseInt(flag[1:], 10, 64)
+		case strings.HasPrefix(flag, "t"):
+			meta.TTL, err = strconv.ParseInt(flag[1:], 10, 64)
+		case strings.HasPrefix(flag, "s"):
+			meta.Size, err = strconv.Atoi(flag[1:])
+		case strings.HasPrefix(flag, "f"):
+			var flags uint64
+			flags, err = strconv.ParseUint(flag[1:], 10, 32)
+			meta.Flags = uint32(flags)
+		case strings.HasPrefix(flag, "c"):
+			meta.CAS, err = strconv.ParseUint(flag[1:], 10, 64)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid meta flag %q", flag)
+		}
+	}
+	return nil
+}
+
+// legalKey mirrors memcached's text protocol key rules: at most 250 bytes
+// with no spaces or control characters.
+func legalKey(key string) bool {
+	if len(key) > 250 {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func newTestReadWriter(reply string) (*bufio.ReadWriter, *bytes.Buffer) {
+	var sent bytes.Buffer
+	return bufio.NewReadWriter(bufio.NewReader(strings.NewReader(reply)), bufio.NewWriter(&sent)), &sent
+}
+
+func TestMetaGet_Hit(t *testing.T) {
+	rw, sent := newTestReadWriter("VA 5 t120 s5 f42 c99 l3\r\nhello\r\n")
+
+	value, meta, err := metaGet(rw, "greeting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.String() != "mg greeting v t s f c la\r\n" {
+		t.Errorf("Unexpected command %q", sent.String())
+	}
+	if string(value) != "hello" {
+		t.Errorf("Expected value 'hello', got '%s'", value)
+	}
+	if meta.TTL != 120 || meta.Size != 5 || meta.Flags != 42 || meta.CAS != 99 || meta.LastAccess != 3 {
+		t.Errorf("Unexpected meta %+v", meta)
+	}
+}
+
+func TestMetaGet_NoExpiry(t *testing.T) {
+	rw, _ := newTestReadWriter("VA 0 t-1 s0 f0 c1 l0\r\n\r\n")
+
+	value, meta, err := metaGet(rw, "empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(value) != 0 || meta.TTL != -1 {
+		t.Errorf("Expected empty value with TTL -1, got %q %+v", value, meta)
+	}
+}
+
+func TestMetaGet_Miss(t *testing.T) {
+	rw, _ := newTestReadWriter("EN\r\n")
+	if _, _, err := metaGet(rw, "missing"); !errors.Is(err, memcache.ErrCacheMiss) {
+		t.Errorf("Expected ErrCacheMiss, got %v", err)
+	}
+}
+
+func TestMetaGet_Unsupported(t *testing.T) {
+	rw, _ := newTestReadWriter("ERROR\r\n")
+	if _, _, err := metaGet(rw, "key"); !errors.Is(err, errMetaUnsupported) {
+		t.Errorf("Expected errMetaUnsupported, got %v", err)
+	}
+}
+
+func TestMetaGet_CorruptValue(t *testing.T) {
+	rw, _ := newTestReadWriter("VA 2 t-1\r\nhello\r\n")
+	if _, _, err := metaGet(rw, "key"); err == nil {
+		t.Error("Expected error for a value that does not match its size")
+	}
+}
+
+func TestLegalKey(t *testing.T) {
+	if !legalKey("user:123") {
+		t.Error("Expected 'user:123' to be legal")
+	}
+	for _, key := range []string{"with space", "new\nline", strings.Repeat("a", 251)} {
+		if legalKey(key) {
+			t.Errorf("Expected %q to be illegal", key)
+		}
+	}
+}
+
+func TestGetWithMeta_FakeServer(t *testing.T) {
+	addr := startFakeServer(t, func(line string, r *bufio.Reader) string {
+		switch line {
+		case "mg user:1 v t s f c la":
+			return "VA 4 t30 s4 f7 c55 l2\r\nJohn\r\n"
+		case "mg legacy v t s f c la":
+			return "ERROR\r\n"
+		case "gets legacy":
+			return "VALUE legacy 0 3\r\nold\r\nEND\r\n"
+		}
+		return "EN\r\n"
+	})
+
+	service := NewMemcachedService()
+	// The fake server does not answer the version ping
+	_ = service.Connect(addr)
+
+	item, meta, err := service.GetWithMeta("user:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(item.Value) != "John" || item.Flags != 7 || item.CasID != 55 {
+		t.Errorf("Unexpected item %+v", item)
+	}
+	if meta == nil || meta.TTL != 30 || meta.LastAccess != 2 || meta.Server != addr {
+		t.Errorf("Unexpected meta %+v", meta)
+	}
+
+	item, meta, err = service.GetWithMeta("legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(item.Value) != "old" || meta != nil {
+		t.Errorf("Expected fallback to plain get without meta, got %+v %+v", item, meta)
+	}
+
+	if _, _, err := service.GetWithMeta("missing"); !errors.Is(err, memcache.ErrCacheMiss) {
+		t.Errorf("Expected ErrCacheMiss, got %v", err)
+	}
+}
+
+func TestGetWithMeta_NotConnected(t *testing.T) {
+	service := NewMemcachedService()
+	_, _, err := service.GetWithMeta("key")
+	if err == nil || err.Error() != "not connected to Memcached" {
+		t.Errorf("Expected 'not connected to Memcached', got '%v'", err)
+	}
+}
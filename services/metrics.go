@@ -0,0 +1,232 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"memcached-management/models"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the request duration
+// histogram.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// RequestMetrics records latency and error counts of the visualizer's own
+// HTTP handlers.
+type RequestMetrics struct {
+	mu     sync.Mutex
+	routes map[routeKey]*routeMetrics
+	errors map[errorKey]uint64
+}
+
+type routeKey struct {
+	method string
+	route  string
+}
+
+type errorKey struct {
+	routeKey
+	status int
+}
+
+type routeMetrics struct {
+	count   uint64
+	sum     float64
+	buckets []uint64
+}
+
+func NewRequestMetrics() *RequestMetrics {
+	return &RequestMetrics{
+		routes: make(map[routeKey]*routeMetrics),
+		errors: make(map[errorKey]uint64),
+	}
+}
+
+// Observe records one handled request. Responses with a status of 400 or
+// above also count as errors.
+func (m *RequestMetrics) Observe(method, route string, status int, duration time.Duration) {
+	key := routeKey{method: method, route: route}
+	seconds := duration.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rm, ok := m.routes[key]
+	if !ok {
+		rm = &routeMetrics{buckets: make([]uint64, len(latencyBuckets))}
+		m.routes[key] = rm
+	}
+	rm.count++
+	rm.sum += seconds
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			rm.buckets[i]++
+		}
+	}
+
+	if status >= 400 {
+		m.errors[errorKey{routeKey: key, status: status}]++
+	}
+}
+
+// WritePrometheus writes the server stats and request metrics in the
+// Prometheus text exposition format.
+func WritePrometheus(w io.Writer, stats []models.ServerStats, requests *RequestMetrics, sessions int) error {
+	bw := bufio.NewWriter(w)
+
+	writeServerMetrics(bw, stats)
+	writeSlabMetrics(bw, stats)
+	if requests != nil {
+		requests.write(bw)
+	}
+
+	writeHeader(bw, "memcached_visualizer_sessions", "gauge", "Number of active visualizer sessions.")
+	fmt.Fprintf(bw, "memcached_visualizer_sessions %d\n", sessions)
+
+	return bw.Flush()
+}
+
+type serverMetric struct {
+	name  string
+	kind  string
+	help  string
+	value func(models.GeneralStats) float64
+}
+
+var serverMetrics = []serverMetric{
+	{"memcached_uptime_seconds", "counter", "Number of seconds since the server started.", func(g models.GeneralStats) float64 { return float64(g.Uptime) }},
+	{"memcached_commands_get_total", "counter", "Total number of get commands.", func(g models.GeneralStats) float64 { return float64(g.CmdGet) }},
+	{"memcached_commands_set_total", "counter", "Total number of set commands.", func(g models.GeneralStats) float64 { return float64(g.CmdSet) }},
+	{"memcached_get_hits_total", "counter", "Total number of get requests that found an item.", func(g models.GeneralStats) float64 { return float64(g.GetHits) }},
+	{"memcached_get_misses_total", "counter", "Total number of get requests that missed.", func(g models.GeneralStats) float64 { return float64(g.GetMisses) }},
+	{"memcached_items_evicted_total", "counter", "Total number of valid items evicted to free memory.", func(g models.GeneralStats) float64 { return float64(g.Evictions) }},
+	{"memcached_items_reclaimed_total", "counter", "Total number of times an expired item's memory was reused.", func(g models.GeneralStats) float64 { return float64(g.Reclaimed) }},
+	{"memcached_current_bytes", "gauge", "Bytes currently used to store items.", func(g models.GeneralStats) float64 { return float64(g.Bytes) }},
+	{"memcached_limit_bytes", "gauge", "Number of bytes the server is allowed to use for storage.", func(g models.GeneralStats) float64 { return float64(g.LimitMaxBytes) }},
+	{"memcached_current_items", "gauge", "Number of items currently stored.", func(g models.GeneralStats) float64 { return float64(g.CurrItems) }},
+	{"memcached_items_total", "counter", "Total number of items stored since the server started.", func(g models.GeneralStats) float64 { return float64(g.TotalItems) }},
+	{"memcached_current_connections", "gauge", "Number of open client connections.", func(g models.GeneralStats) float64 { return float64(g.CurrConnections) }},
+	{"memcached_connections_total", "counter", "Total number of connections opened since the server started.", func(g models.GeneralStats) float64 { return float64(g.TotalConnections) }},
+	{"memcached_read_bytes_total", "counter", "Total number of bytes read from the network.", func(g models.GeneralStats) float64 { return float64(g.BytesRead) }},
+	{"memcached_written_bytes_total", "counter", "Total number of bytes written to the network.", func(g models.GeneralStats) float64 { return float64(g.BytesWritten) }},
+}
+
+func writeServerMetrics(w *bufio.Writer, stats []models.ServerStats) {
+	writeHeader(w, "memcached_up", "gauge", "Whether the last scrape of the server succeeded.")
+	for _, s := range stats {
+		up := 1
+		if s.Error != "" {
+			up = 0
+		}
+		fmt.Fprintf(w, "memcached_up{server=%s} %d\n", quoteLabel(s.Server), up)
+	}
+
+	for _, metric := range serverMetrics {
+		writeHeader(w, metric.name, metric.kind, metric.help)
+		for _, s := range stats {
+			if s.Error != "" {
+				continue
+			}
+			fmt.Fprintf(w, "%s{server=%s} %s\n", metric.name, quoteLabel(s.Server), formatFloat(metric.value(s.General)))
+		}
+	}
+}
+
+func writeSlabMetrics(w *bufio.Writer, stats []models.ServerStats) {
+	writeHeader(w, "memcached_slab_current_items", "gauge", "Number of items currently stored in the slab class.")
+	for _, s := range stats {
+		for _, item := range s.Items {
+			fmt.Fprintf(w, "memcached_slab_current_items{server=%s,slab=\"%d\"} %d\n", quoteLabel(s.Server), item.SlabID, item.Number)
+		}
+	}
+
+	writeHeader(w, "memcached_slab_items_evicted_total", "counter", "Number of items evicted from the slab class.")
+	for _, s := range stats {
+		for _, item := range s.Items {
+			fmt.Fprintf(w, "memcached_slab_items_evicted_total{server=%s,slab=\"%d\"} %d\n", quoteLabel(s.Server), item.SlabID, item.Evicted)
+		}
+	}
+
+	writeHeader(w, "memcached_slab_chunk_size_bytes", "gauge", "Size of the chunks in the slab class.")
+	for _, s := range stats {
+		for _, slab := range s.Slabs {
+			fmt.Fprintf(w, "memcached_slab_chunk_size_bytes{server=%s,slab=\"%d\"} %d\n", quoteLabel(s.Server), slab.ID, slab.ChunkSize)
+		}
+	}
+
+	writeHeader(w, "memcached_slab_used_chunks", "gauge", "Number of chunks allocated to items in the slab class.")
+	for _, s := range stats {
+		for _, slab := range s.Slabs {
+			fmt.Fprintf(w, "memcached_slab_used_chunks{server=%s,slab=\"%d\"} %d\n", quoteLabel(s.Server), slab.ID, slab.UsedChunks)
+		}
+	}
+}
+
+func (m *RequestMetrics) write(w *bufio.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]routeKey, 0, len(m.routes))
+	for key := range m.routes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].method < keys[j].method
+	})
+
+	name := "memcached_visualizer_http_request_duration_seconds"
+	writeHeader(w, name, "histogram", "Latency of the visualizer's HTTP handlers.")
+	for _, key := range keys {
+		rm := m.routes[key]
+		labels := fmt.Sprintf("method=%s,route=%s", quoteLabel(key.method), quoteLabel(key.route))
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(bound), rm.buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, rm.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, formatFloat(rm.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, rm.count)
+	}
+
+	errorKeys := make([]errorKey, 0, len(m.errors))
+	for key := range m.errors {
+		errorKeys = append(errorKeys, key)
+	}
+	sort.Slice(errorKeys, func(i, j int) bool {
+		if errorKeys[i].route != errorKeys[j].route {
+			return errorKeys[i].route < errorKeys[j].route
+		}
+		if errorKeys[i].method != errorKeys[j].method {
+			return errorKeys[i].method < errorKeys[j].method
+		}
+		return errorKeys[i].status < errorKeys[j].status
+	})
+
+	writeHeader(w, "memcached_visualizer_http_request_errors_total", "counter", "Number of handler responses with a 4xx or 5xx status.")
+	for _, key := range errorKeys {
+		fmt.Fprintf(w, "memcached_visualizer_http_request_errors_total{method=%s,route=%s,status=\"%d\"} %d\n",
+			quoteLabel(key.method), quoteLabel(key.route), key.status, m.errors[key])
+	}
+}
+
+func writeHeader(w *bufio.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quoteLabel(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
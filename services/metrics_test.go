@@ -0,0 +1,83 @@
+package services
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"memcached-management/models"
+)
+
+func TestWritePrometheus_ServerStats(t *testing.T) {
+	stats := []models.ServerStats{
+		{
+			Server:  "cache-1:11211",
+			General: models.GeneralStats{GetHits: 75, GetMisses: 25, Evictions: 3, Bytes: 1024, CurrItems: 10},
+			Slabs:   []models.SlabStats{{ID: 1, ChunkSize: 96, UsedChunks: 10}},
+			Items:   []models.ItemStats{{SlabID: 1, Number: 10, Evicted: 3}},
+		},
+		{Server: "cache-2:11211", Error: "failed to connect"},
+	}
+
+	var buf bytes.Buffer
+	if err := WritePrometheus(&buf, stats, nil, 2); err != nil {
+		t.Fatal(err)
+	}
+	output := buf.String()
+
+	expected := []string{
+		`memcached_up{server="cache-1:11211"} 1`,
+		`memcached_up{server="cache-2:11211"} 0`,
+		`memcached_get_hits_total{server="cache-1:11211"} 75`,
+		`memcached_get_misses_total{server="cache-1:11211"} 25`,
+		`memcached_items_evicted_total{server="cache-1:11211"} 3`,
+		`memcached_current_bytes{server="cache-1:11211"} 1024`,
+		`memcached_current_items{server="cache-1:11211"} 10`,
+		`memcached_slab_current_items{server="cache-1:11211",slab="1"} 10`,
+		`memcached_slab_chunk_size_bytes{server="cache-1:11211",slab="1"} 96`,
+		`memcached_visualizer_sessions 2`,
+		`# TYPE memcached_get_hits_total counter`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected output to contain %q", line)
+		}
+	}
+	if strings.Contains(output, `memcached_get_hits_total{server="cache-2:11211"}`) {
+		t.Error("Expected failed servers to only report memcached_up")
+	}
+}
+
+func TestRequestMetrics_Histogram(t *testing.T) {
+	metrics := NewRequestMetrics()
+	metrics.Observe("POST", "/get", http.StatusOK, 20*time.Millisecond)
+	metrics.Observe("POST", "/get", http.StatusNotFound, 2*time.Second)
+
+	var buf bytes.Buffer
+	if err := WritePrometheus(&buf, nil, metrics, 0); err != nil {
+		t.Fatal(err)
+	}
+	output := buf.String()
+
+	expected := []string{
+		`memcached_visualizer_http_request_duration_seconds_bucket{method="POST",route="/get",le="0.01"} 0`,
+		`memcached_visualizer_http_request_duration_seconds_bucket{method="POST",route="/get",le="0.025"} 1`,
+		`memcached_visualizer_http_request_duration_seconds_bucket{method="POST",route="/get",le="2.5"} 2`,
+		`memcached_visualizer_http_request_duration_seconds_bucket{method="POST",route="/get",le="+Inf"} 2`,
+		`memcached_visualizer_http_request_duration_seconds_count{method="POST",route="/get"} 2`,
+		`memcached_visualizer_http_request_errors_total{method="POST",route="/get",status="404"} 1`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected output to contain %q", line)
+		}
+	}
+}
+
+func TestQuoteLabel(t *testing.T) {
+	if got := quoteLabel("a\"b\\c\nd"); got != `"a\"b\\c\nd"` {
+		t.Errorf("Unexpected escaped label %s", got)
+	}
+}
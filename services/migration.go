@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"memcached-management/models"
+)
+
+var (
+	ErrMigrationNotFound = errors.New("migration not found")
+	// ErrInvalidMigration is returned for migrations that cannot start, such
+	// as copying a cluster onto itself.
+	ErrInvalidMigration = errors.New("invalid migration")
+)
+
+// maxFinishedMigrations is how many finished jobs are kept for status
+// queries; older ones are dropped as new jobs start.
+const maxFinishedMigrations = 20
+
+// MigrationManager runs key migrations between two clusters in the
+// background. Jobs use their own connections, so they outlive the session
+// that started them.
+type MigrationManager struct {
+	mu   sync.Mutex
+	jobs map[string]*migrationJob
+	now  func() time.Time
+}
+
+type migrationJob struct {
+	mu     sync.Mutex
+	status models.MigrationStatus
+	cancel context.CancelFunc
+	now    func() time.Time
+}
+
+func NewMigrationManager() *MigrationManager {
+	return &MigrationManager{
+		jobs: make(map[string]*migrationJob),
+		now:  time.Now,
+	}
+}
+
+// Start connects to both clusters and, if that works, copies every key
+// under prefix from source to destination in the background. Values keep
+// their flags and remaining TTL.
+func (m *MigrationManager) Start(source, destination models.Profile, req models.MigrationRequest) (models.MigrationStatus, error) {
+	mode := req.Mode
+	if mode == "" {
+		mode = "overwrite"
+	}
+	if mode != "overwrite" && mode != "skip-existing" {
+		return models.MigrationStatus{}, fmt.Errorf("%w: unknown mode %q", ErrInvalidMigration, req.Mode)
+	}
+	opts, err := parseImportOptions(models.ImportOptions{Mode: mode, Concurrency: req.Concurrency}, m.now())
+	if err != nil {
+		return models.MigrationStatus{}, fmt.Errorf("%w: %v", ErrInvalidMigration, err)
+	}
+
+	src := NewMemcachedService()
+	if err := src.ConnectProfile(source); err != nil {
+		src.Disconnect()
+		return models.MigrationStatus{}, fmt.Errorf("source: %w", err)
+	}
+	dst := NewMemcachedService()
+	if err := dst.ConnectProfile(destination); err != nil {
+		src.Disconnect()
+		dst.Disconnect()
+		return models.MigrationStatus{}, fmt.Errorf("destination: %w", err)
+	}
+
+	fail := func(err error) (models.MigrationStatus, error) {
+		src.Disconnect()
+		dst.Disconnect()
+		return models.MigrationStatus{}, err
+	}
+	if dst.IsReadOnly() {
+		return fail(fmt.Errorf("%w: destination is read-only", ErrInvalidMigration))
+	}
+	srcServers, dstServers := src.Servers(), dst.Servers()
+	for _, server := range dstServers {
+		if slices.Contains(srcServers, server) {
+			return fail(fmt.Errorf("%w: %s is both source and destination", ErrInvalidMigration, server))
+		}
+	}
+
+	var match *KeyMatcher
+	if req.Prefix != "" {
+		match = &KeyMatcher{prefix: req.Prefix}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &migrationJob{
+		cancel: cancel,
+		now:    m.now,
+		status: models.MigrationStatus{
+			ID:          newJobID(),
+			State:       "running",
+			Source:      srcServers,
+			Destination: dstServers,
+			Prefix:      req.Prefix,
+			StartedAt:   m.now(),
+		},
+	}
+
+	m.mu.Lock()
+	m.pruneLocked()
+	m.jobs[job.status.ID] = job
+	m.mu.Unlock()
+
+	go job.run(ctx, src, dst, match, opts)
+
+	return job.snapshot(), nil
+}
+
+func (m *MigrationManager) Get(id string) (models.MigrationStatus, error) {
+	m.mu.Lock()
+	job, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok {
+		return models.MigrationStatus{}, ErrMigrationNotFound
+	}
+	return job.snapshot(), nil
+}
+
+// List returns every known job, newest first.
+func (m *MigrationManager) List() []models.MigrationStatus {
+	m.mu.Lock()
+	jobs := make([]*migrationJob, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, job)
+	}
+	m.mu.Unlock()
+
+	result := make([]models.MigrationStatus, 0, len(jobs))
+	for _, job := range jobs {
+		result = append(result, job.snapshot())
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].StartedAt.After(result[j].StartedAt) })
+	return result
+}
+
+// Cancel stops a running job. Items already copied stay in the
+// destination.
+func (m *MigrationManager) Cancel(id string) (models.MigrationStatus, error) {
+	m.mu.Lock()
+	job, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok {
+		return models.MigrationStatus{}, ErrMigrationNotFound
+	}
+	job.cancel()
+	return job.snapshot(), nil
+}
+
+// pruneLocked drops the oldest finished jobs beyond maxFinishedMigrations.
+func (m *MigrationManager) pruneLocked() {
+	var finished []*migrationJob
+	for _, job := range m.jobs {
+		if job.snapshot().FinishedAt != nil {
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) < maxFinishedMigrations {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].snapshot().FinishedAt.Before(*finished[j].snapshot().FinishedAt)
+	})
+	for _, job := range finished[:len(finished)-maxFinishedMigrations+1] {
+		delete(m.jobs, job.status.ID)
+	}
+}
+
+func (j *migrationJob) run(ctx context.Context, src, dst *MemcachedService, match *KeyMatcher, opts importOptions) {
+	defer src.Disconnect()
+	defer dst.Disconnect()
+	defer j.cancel()
+
+	st, err := dst.writableState()
+	if err != nil {
+		j.finish(ctx, err)
+		return
+	}
+
+	records := make(chan importRecord)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rec := range records {
+				if ctx.Err() != nil {
+					continue
+				}
+				skipped, err := writeImportRecord(st.client, opts, rec, j.now())
+				j.record(rec, skipped, err)
+			}
+		}()
+	}
+
+	_, skipped, err := src.Export(ctx, match, func(record models.DumpRecord) error {
+		value, err := DecodeValue(record.Value, record.Encoding)
+		if err != nil {
+			return err
+		}
+		select {
+		case records <- importRecord{key: record.Key, value: value, flags: record.Flags, expiresAt: record.ExpiresAt}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(records)
+	wg.Wait()
+
+	j.mu.Lock()
+	j.status.Skipped += skipped
+	j.mu.Unlock()
+	j.finish(ctx, err)
+}
+
+func (j *migrationJob) record(rec importRecord, skipped bool, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	switch {
+	case err != nil:
+		j.status.Failed++
+		if len(j.status.Errors) < maxDeleteErrors {
+			j.status.Errors = append(j.status.Errors, fmt.Sprintf("%s: %v", rec.key, err))
+		}
+	case skipped:
+		j.status.Skipped++
+	default:
+		j.status.Copied++
+		j.status.Bytes += int64(len(rec.value))
+	}
+}
+
+func (j *migrationJob) finish(ctx context.Context, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	finished := j.now()
+	j.status.FinishedAt = &finished
+	switch {
+	case ctx.Err() != nil && errors.Is(err, context.Canceled):
+		j.status.State = "cancelled"
+	case err != nil:
+		j.status.State = "failed"
+		j.status.Error = err.Error()
+	default:
+		j.status.State = "completed"
+	}
+}
+
+// snapshot copies the status and fills in the elapsed time and rates.
+func (j *migrationJob) snapshot() models.MigrationStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	status := j.status
+	status.Errors = slices.Clone(j.status.Errors)
+
+	end := j.now()
+	if status.FinishedAt != nil {
+		end = *status.FinishedAt
+	}
+	status.ElapsedSeconds = end.Sub(status.StartedAt).Seconds()
+	if status.ElapsedSeconds > 0 {
+		status.KeysPerSecond = float64(status.Copied) / status.ElapsedSeconds
+		status.BytesPerSecond = float64(status.Bytes) / status.ElapsedSeconds
+	}
+	return status
+}
+
+func newJobID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
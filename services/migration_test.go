@@ -0,0 +1,122 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"memcached-management/models"
+)
+
+// startMigrationSource fakes a source whose values are "value-<key>" with
+// flags 3 and never expire. "gone" is listed but expired.
+func startMigrationSource(t *testing.T, keys ...string) string {
+	cache := newFakeCache()
+	for _, key := range keys {
+		cache.put(key, fakeItem{value: "value-" + key, flags: 3, ttl: -1, gone: key == "gone"})
+	}
+	return startCacheServer(t, cache)
+}
+
+// startMigrationDestination fakes an empty destination that calls hold
+// before answering each set.
+func startMigrationDestination(t *testing.T, hold func()) (string, *fakeCache) {
+	cache := newFakeCache()
+	cache.hold = hold
+	return startCacheServer(t, cache), cache
+}
+
+func waitForMigration(t *testing.T, m *MigrationManager, id string) models.MigrationStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		status, err := m.Get(id)
+		if err != nil {
+			t.Fatalf("Expected job %s, got %v", id, err)
+		}
+		if status.FinishedAt != nil {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Migration %s did not finish", id)
+	return models.MigrationStatus{}
+}
+
+func TestMigration_CopiesKeys(t *testing.T) {
+	source := startMigrationSource(t, "user:1", "user:2", "gone", "other")
+	destination, cache := startMigrationDestination(t, nil)
+
+	m := NewMigrationManager()
+	status, err := m.Start(models.Profile{Servers: []string{source}}, models.Profile{Servers: []string{destination}}, models.MigrationRequest{Prefix: "user:"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if status.ID == "" || status.State != "running" {
+		t.Errorf("Unexpected initial status %+v", status)
+	}
+
+	status = waitForMigration(t, m, status.ID)
+	if status.State != "completed" || status.Copied != 2 || status.Skipped != 0 || status.Failed != 0 {
+		t.Errorf("Unexpected final status %+v", status)
+	}
+	if status.Bytes != int64(len("value-user:1")*2) {
+		t.Errorf("Expected bytes to count copied values, got %d", status.Bytes)
+	}
+	if got, _ := cache.item("user:1"); got.flags != 3 || got.ttl != -1 || got.value != "value-user:1" {
+		t.Errorf("Expected value and flags to be copied, got %+v", got)
+	}
+	if _, ok := cache.item("other"); ok {
+		t.Error("Expected keys outside the prefix to be left alone")
+	}
+
+	if jobs := m.List(); len(jobs) != 1 || jobs[0].ID != status.ID {
+		t.Errorf("Expected the job to be listed, got %+v", jobs)
+	}
+}
+
+func TestMigration_Cancel(t *testing.T) {
+	release := make(chan struct{})
+	source := startMigrationSource(t, "a", "b", "c", "d", "e")
+	destination, _ := startMigrationDestination(t, func() { <-release })
+
+	m := NewMigrationManager()
+	status, err := m.Start(models.Profile{Servers: []string{source}}, models.Profile{Servers: []string{destination}}, models.MigrationRequest{Concurrency: 1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := m.Cancel(status.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	close(release)
+
+	status = waitForMigration(t, m, status.ID)
+	if status.State != "cancelled" || status.Copied >= 5 {
+		t.Errorf("Expected a cancelled partial copy, got %+v", status)
+	}
+}
+
+func TestMigration_Validation(t *testing.T) {
+	source := startMigrationSource(t)
+	m := NewMigrationManager()
+
+	if _, err := m.Start(models.Profile{Servers: []string{source}}, models.Profile{Servers: []string{source}}, models.MigrationRequest{}); !errors.Is(err, ErrInvalidMigration) {
+		t.Errorf("Expected copying a server onto itself to fail, got %v", err)
+	}
+	if _, err := m.Start(models.Profile{Servers: []string{source}}, models.Profile{Servers: []string{source}, ReadOnly: true}, models.MigrationRequest{}); !errors.Is(err, ErrInvalidMigration) {
+		t.Errorf("Expected a read-only destination to fail, got %v", err)
+	}
+	if _, err := m.Start(models.Profile{Servers: []string{source}}, models.Profile{Servers: []string{"127.0.0.1:1"}}, models.MigrationRequest{Mode: "add-only"}); !errors.Is(err, ErrInvalidMigration) {
+		t.Errorf("Expected an unknown mode to fail, got %v", err)
+	}
+	if _, err := m.Get("missing"); !errors.Is(err, ErrMigrationNotFound) {
+		t.Errorf("Expected ErrMigrationNotFound, got %v", err)
+	}
+	if _, err := m.Cancel("missing"); !errors.Is(err, ErrMigrationNotFound) {
+		t.Errorf("Expected ErrMigrationNotFound, got %v", err)
+	}
+	if jobs := m.List(); len(jobs) != 0 {
+		t.Errorf("Expected rejected migrations not to be kept, got %+v", jobs)
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+var (
+	// ErrCacheMiss means the key does not exist.
+	ErrCacheMiss = memcache.ErrCacheMiss
+	// ErrNotStored means the condition of Add, Replace, Append or Prepend
+	// was not met.
+	ErrNotStored = memcache.ErrNotStored
+)
+
+// Increment atomically adds delta to the numeric value of key and returns
+// the new value.
+func (s *MemcachedService) Increment(key string, delta uint64) (uint64, error) {
+	st, err := s.writableState()
+	if err != nil {
+		return 0, err
+	}
+	if key == "" {
+		return 0, fmt.Errorf("key is required")
+	}
+
+	return st.client.Increment(key, delta)
+}
+
+// Decrement atomically subtracts delta from the numeric value of key. As in
+// memcached, the value does not go below zero.
+func (s *MemcachedService) Decrement(key string, delta uint64) (uint64, error) {
+	st, err := s.writableState()
+	if err != nil {
+		return 0, err
+	}
+	if key == "" {
+		return 0, fmt.Errorf("key is required")
+	}
+
+	return st.client.Decrement(key, delta)
+}
+
+// Add stores the item only if key does not exist yet; otherwise it returns
+// ErrNotStored.
+func (s *MemcachedService) Add(key, value string, flags uint32, ttl int64) error {
+	st, err := s.writableState()
+	if err != nil {
+		return err
+	}
+
+	item, err := newItem(key, value, flags, ttl)
+	if err != nil {
+		return err
+	}
+	return st.client.Add(item)
+}
+
+// Replace stores the item only if key already exists; otherwise it returns
+// ErrNotStored.
+func (s *MemcachedService) Replace(key, value string, flags uint32, ttl int64) error {
+	st, err := s.writableState()
+	if err != nil {
+		return err
+	}
+
+	item, err := newItem(key, value, flags, ttl)
+	if err != nil {
+		return err
+	}
+	return st.client.Replace(item)
+}
+
+// Append adds value after the existing value of key, keeping its flags and
+// expiration. ErrNotStored is returned if key does not exist.
+func (s *MemcachedService) Append(key, value string) error {
+	st, err := s.writableState()
+	if err != nil {
+		return err
+	}
+	if key == "" || value == "" {
+		return fmt.Errorf("key and value are required")
+	}
+
+	return st.client.Append(&memcache.Item{Key: strings.TrimSpace(key), Value: []byte(value)})
+}
+
+// Prepend adds value before the existing value of key, keeping its flags
+// and expiration. ErrNotStored is returned if key does not exist.
+func (s *MemcachedService) Prepend(key, value string) error {
+	st, err := s.writableState()
+	if err != nil {
+		return err
+	}
+	if key == "" || value == "" {
+		return fmt.Errorf("key and value are required")
+	}
+
+	return st.client.Prepend(&memcache.Item{Key: strings.TrimSpace(key), Value: []byte(value)})
+}
+
+// Touch updates the expiration of key without fetching it. ttl follows the
+// same rules as Set.
+func (s *MemcachedService) Touch(key string, ttl int64) error {
+	st, err := s.writableState()
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+
+	expiration, err := ValidateExpiration(ttl, time.Now())
+	if err != nil {
+		return err
+	}
+	return st.client.Touch(key, expiration)
+}
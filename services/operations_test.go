@@ -0,0 +1,114 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newOperationsServer fakes a server holding "counter" = 5 and "blob".
+func newOperationsServer(t *testing.T) *MemcachedService {
+	addr := startFakeServer(t, func(line string, r *bufio.Reader) string {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return "ERROR\r\n"
+		}
+		exists := fields[1] == "counter" || fields[1] == "blob"
+
+		switch fields[0] {
+		case "incr":
+			if !exists {
+				return "NOT_FOUND\r\n"
+			}
+			return "8\r\n"
+		case "decr":
+			if !exists {
+				return "NOT_FOUND\r\n"
+			}
+			return "2\r\n"
+		case "add":
+			r.ReadString('\n')
+			if exists {
+				return "NOT_STORED\r\n"
+			}
+			return "STORED\r\n"
+		case "replace", "append", "prepend":
+			r.ReadString('\n')
+			if !exists {
+				return "NOT_STORED\r\n"
+			}
+			return "STORED\r\n"
+		case "touch":
+			if !exists {
+				return "NOT_FOUND\r\n"
+			}
+			return "TOUCHED\r\n"
+		}
+		return "ERROR\r\n"
+	})
+
+	service := NewMemcachedService()
+	_ = service.Connect(addr)
+	return service
+}
+
+func TestIncrementDecrement(t *testing.T) {
+	service := newOperationsServer(t)
+
+	if value, err := service.Increment("counter", 3); err != nil || value != 8 {
+		t.Errorf("Expected 8, got %d (%v)", value, err)
+	}
+	if value, err := service.Decrement("counter", 3); err != nil || value != 2 {
+		t.Errorf("Expected 2, got %d (%v)", value, err)
+	}
+	if _, err := service.Increment("missing", 1); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("Expected ErrCacheMiss, got %v", err)
+	}
+}
+
+func TestConditionalWrites(t *testing.T) {
+	service := newOperationsServer(t)
+
+	if err := service.Add("new", "value", 0, 0); err != nil {
+		t.Errorf("Expected add of a new key to succeed, got %v", err)
+	}
+	if err := service.Add("blob", "value", 0, 0); !errors.Is(err, ErrNotStored) {
+		t.Errorf("Expected ErrNotStored adding an existing key, got %v", err)
+	}
+	if err := service.Replace("missing", "value", 0, 0); !errors.Is(err, ErrNotStored) {
+		t.Errorf("Expected ErrNotStored replacing a missing key, got %v", err)
+	}
+	if err := service.Append("blob", "-tail"); err != nil {
+		t.Errorf("Expected append to succeed, got %v", err)
+	}
+	if err := service.Prepend("missing", "head-"); !errors.Is(err, ErrNotStored) {
+		t.Errorf("Expected ErrNotStored prepending to a missing key, got %v", err)
+	}
+}
+
+func TestTouch(t *testing.T) {
+	service := newOperationsServer(t)
+
+	if err := service.Touch("blob", 60); err != nil {
+		t.Errorf("Expected touch to succeed, got %v", err)
+	}
+	if err := service.Touch("missing", 60); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("Expected ErrCacheMiss, got %v", err)
+	}
+	if err := service.Touch("blob", -1); err == nil {
+		t.Error("Expected error for negative TTL")
+	}
+}
+
+func TestOperations_ReadOnly(t *testing.T) {
+	service := newOperationsServer(t)
+	service.readOnly = true
+
+	if _, err := service.Increment("counter", 1); err == nil || err.Error() != "connection is read-only" {
+		t.Errorf("Expected 'connection is read-only', got '%v'", err)
+	}
+	if err := service.Touch("blob", 60); err == nil || err.Error() != "connection is read-only" {
+		t.Errorf("Expected 'connection is read-only', got '%v'", err)
+	}
+}
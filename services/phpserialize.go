@@ -0,0 +1,282 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// decodePHP parses a PHP serialize() value. Objects are not instantiated:
+// they become maps with "__class" and their properties, with the private
+// and protected name mangling removed.
+func decodePHP(value []byte) ([]byte, error) {
+	p := &phpParser{data: value}
+	v, err := p.value(0)
+	if err != nil {
+		return nil, fmt.Errorf("php: %v at offset %d", err, p.pos)
+	}
+	if p.pos != len(p.data) {
+		return nil, fmt.Errorf("php: trailing data at offset %d", p.pos)
+	}
+	return marshalSerialized(v, nil)
+}
+
+// looksLikePHP is a cheap check for the type prefix every serialized value
+// starts with.
+func looksLikePHP(value []byte) bool {
+	if len(value) < 2 {
+		return false
+	}
+	switch value[0] {
+	case 'N':
+		return value[1] == ';'
+	case 'b', 'i', 'd', 's', 'a', 'O', 'C', 'E':
+		return value[1] == ':'
+	}
+	return false
+}
+
+type phpParser struct {
+	data  []byte
+	pos   int
+	items int
+}
+
+func (p *phpParser) value(depth int) (interface{}, error) {
+	if depth > maxSerializedDepth {
+		return nil, fmt.Errorf("nesting too deep")
+	}
+	if p.items++; p.items > maxSerializedItems {
+		return nil, errSerializedTooLarge
+	}
+	if p.pos >= len(p.data) {
+		return nil, errSerializedTruncated
+	}
+
+	kind := p.data[p.pos]
+	p.pos++
+	if kind == 'N' {
+		return nil, p.expect(';')
+	}
+	if err := p.expect(':'); err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case 'b':
+		n, err := p.until(';')
+		if err != nil {
+			return nil, err
+		}
+		if n != "0" && n != "1" {
+			return nil, fmt.Errorf("invalid boolean %q", n)
+		}
+		return n == "1", nil
+	case 'i':
+		n, err := p.until(';')
+		if err != nil {
+			return nil, err
+		}
+		return strconv.ParseInt(n, 10, 64)
+	case 'd':
+		n, err := p.until(';')
+		if err != nil {
+			return nil, err
+		}
+		switch n {
+		case "INF":
+			return math.Inf(1), nil
+		case "-INF":
+			return math.Inf(-1), nil
+		case "NAN":
+			return math.NaN(), nil
+		}
+		return strconv.ParseFloat(n, 64)
+	case 's':
+		s, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		return s, p.expect(';')
+	case 'a':
+		return p.array(depth)
+	case 'O':
+		class, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(':'); err != nil {
+			return nil, err
+		}
+		props, err := p.array(depth)
+		if err != nil {
+			return nil, err
+		}
+		obj := classObject(string(class))
+		if m, ok := props.(*orderedMap); ok {
+			for _, key := range m.keys {
+				obj.Set(phpPropertyName(key), m.values[key])
+			}
+		} else {
+			for i, v := range props.([]interface{}) {
+				obj.Set(strconv.Itoa(i), v)
+			}
+		}
+		return obj, nil
+	case 'C':
+		// Serializable objects write their own payload.
+		class, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(':'); err != nil {
+			return nil, err
+		}
+		n, err := p.length(':')
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect('{'); err != nil {
+			return nil, err
+		}
+		if p.pos+n > len(p.data) {
+			return nil, errSerializedTruncated
+		}
+		obj := classObject(string(class))
+		obj.Set("__data", p.data[p.pos:p.pos+n])
+		p.pos += n
+		return obj, p.expect('}')
+	case 'E':
+		name, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		m := newOrderedMap()
+		m.Set("__enum", string(name))
+		return m, p.expect(';')
+	case 'r', 'R':
+		n, err := p.until(';')
+		if err != nil {
+			return nil, err
+		}
+		m := newOrderedMap()
+		m.Set("__ref", n)
+		return m, nil
+	}
+	return nil, fmt.Errorf("unknown type %q", kind)
+}
+
+// array parses "<count>:{key;value...}". Arrays with keys 0..n-1 in order
+// become lists, anything else an ordered map.
+func (p *phpParser) array(depth int) (interface{}, error) {
+	n, err := p.length(':')
+	if err != nil {
+		return nil, err
+	}
+	if n > maxSerializedItems || n > len(p.data)-p.pos {
+		return nil, errSerializedTooLarge
+	}
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+
+	m := newOrderedMap()
+	list := true
+	for i := 0; i < n; i++ {
+		key, err := p.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		var name string
+		switch key := key.(type) {
+		case int64:
+			name = strconv.FormatInt(key, 10)
+			list = list && key == int64(i)
+		case []byte:
+			name = string(key)
+			list = false
+		default:
+			return nil, fmt.Errorf("invalid array key %v", key)
+		}
+		v, err := p.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		m.Set(name, v)
+	}
+	if err := p.expect('}'); err != nil {
+		return nil, err
+	}
+
+	if list {
+		items := make([]interface{}, len(m.keys))
+		for i, key := range m.keys {
+			items[i] = m.values[key]
+		}
+		return items, nil
+	}
+	return m, nil
+}
+
+// quoted parses `<len>:"<bytes>"`.
+func (p *phpParser) quoted() ([]byte, error) {
+	n, err := p.length(':')
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect('"'); err != nil {
+		return nil, err
+	}
+	if p.pos+n > len(p.data) {
+		return nil, errSerializedTruncated
+	}
+	s := p.data[p.pos : p.pos+n]
+	p.pos += n
+	return s, p.expect('"')
+}
+
+func (p *phpParser) length(end byte) (int, error) {
+	s, err := p.until(end)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid length %q", s)
+	}
+	return n, nil
+}
+
+func (p *phpParser) until(end byte) (string, error) {
+	i := bytes.IndexByte(p.data[p.pos:], end)
+	if i < 0 {
+		return "", errSerializedTruncated
+	}
+	s := string(p.data[p.pos : p.pos+i])
+	p.pos += i + 1
+	return s, nil
+}
+
+func (p *phpParser) expect(c byte) error {
+	if p.pos >= len(p.data) {
+		return errSerializedTruncated
+	}
+	if p.data[p.pos] != c {
+		return fmt.Errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+// phpPropertyName strips the "\0Class\0" and "\0*\0" prefixes PHP adds to
+// private and protected property names.
+func phpPropertyName(name string) string {
+	if strings.HasPrefix(name, "\x00") {
+		if i := strings.IndexByte(name[1:], 0); i >= 0 {
+			return name[i+2:]
+		}
+	}
+	return name
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func compactJSON(t *testing.T, data []byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", data, err)
+	}
+	return buf.String()
+}
+
+func TestDecodePHP(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{`i:42;`, `42`},
+		{`d:0.5;`, `0.5`},
+		{`d:INF;`, `"+Inf"`},
+		{`b:1;`, `true`},
+		{`N;`, `null`},
+		{`s:5:"a;b:c";`, `"a;b:c"`},
+		{`a:2:{i:0;s:1:"x";i:1;s:1:"y";}`, `["x","y"]`},
+		{`a:2:{i:1;s:1:"x";s:1:"k";a:0:{}}`, `{"1":"x","k":[]}`},
+		{
+			"O:4:\"User\":3:{s:4:\"name\";s:3:\"Ana\";s:9:\"\x00*\x00secret\";b:1;s:8:\"\x00User\x00pw\";N;}",
+			`{"__class":"User","name":"Ana","secret":true,"pw":null}`,
+		},
+		{`C:3:"Foo":4:{abcd}`, `{"__class":"Foo","__data":"abcd"}`},
+		{`E:11:"Suit:Hearts";`, `{"__enum":"Suit:Hearts"}`},
+		{`a:2:{i:0;O:1:"A":0:{}i:1;r:2;}`, `[{"__class":"A"},{"__ref":"2"}]`},
+	}
+
+	for _, tt := range tests {
+		decoded, err := decodePHP([]byte(tt.value))
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.value, err)
+			continue
+		}
+		if got := compactJSON(t, decoded); got != tt.want {
+			t.Errorf("%q: expected %s, got %s", tt.value, tt.want, got)
+		}
+	}
+}
+
+func TestDecodePHP_Invalid(t *testing.T) {
+	for _, value := range []string{
+		``,
+		`i:42`,
+		`i:42;x`,
+		`s:10:"short";`,
+		`a:1:{i:0;}`,
+		`a:99999999:{}`,
+		`a:1:{d:0.5;i:1;}`,
+		`x:1;`,
+		"i:1;\n",
+	} {
+		if _, err := decodePHP([]byte(value)); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestDecodePHP_DeepNesting(t *testing.T) {
+	value := bytes.Repeat([]byte("a:1:{i:0;"), maxSerializedDepth+10)
+	value = append(value, "N;"...)
+	value = append(value, bytes.Repeat([]byte("}"), maxSerializedDepth+10)...)
+	if _, err := decodePHP(value); err == nil {
+		t.Error("Expected nesting beyond the limit to be rejected")
+	}
+}
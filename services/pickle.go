@@ -0,0 +1,674 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// decodePickle reads a Python pickle (protocols 0 to 5) without running
+// it. Globals are never imported: they show up as {"__global": "mod.name"},
+// and REDUCE, NEWOBJ and BUILD as maps describing the call they would make.
+func decodePickle(value []byte) ([]byte, error) {
+	m := &pickleMachine{r: bufio.NewReader(bytes.NewReader(value)), memo: make(map[int]interface{}), refs: make(serializedRefs)}
+	v, err := m.run()
+	if err != nil {
+		return nil, fmt.Errorf("pickle: %v", err)
+	}
+	return marshalSerialized(v, m.refs)
+}
+
+// looksLikePickle matches the PROTO opcode that starts protocol 2+ pickles.
+// Older protocols have no header and are only decoded when a prefix rule
+// or the item flags ask for it.
+func looksLikePickle(value []byte, flags uint32) bool {
+	if len(value) >= 2 && value[0] == 0x80 && value[1] >= 2 && value[1] <= 5 {
+		return true
+	}
+	return flags&pythonPickledFlag != 0 && len(value) > 0 && value[len(value)-1] == '.'
+}
+
+// pythonPickledFlag is the flag python-memcached, pylibmc and pymemcache
+// set on pickled values.
+const pythonPickledFlag = 1
+
+// pickleMark separates the items of MARK based opcodes on the stack.
+type pickleMark struct{}
+
+type pickleMachine struct {
+	r     *bufio.Reader
+	stack []interface{}
+	memo  map[int]interface{}
+	refs  serializedRefs
+	items int
+}
+
+func (m *pickleMachine) push(v interface{}) error {
+	if m.items++; m.items > maxSerializedItems {
+		return errSerializedTooLarge
+	}
+	m.stack = append(m.stack, v)
+	return nil
+}
+
+func (m *pickleMachine) pop() (interface{}, error) {
+	if len(m.stack) == 0 {
+		return nil, errors.New("stack underflow")
+	}
+	v := m.stack[len(m.stack)-1]
+	m.stack = m.stack[:len(m.stack)-1]
+	if _, ok := v.(pickleMark); ok {
+		return nil, errors.New("unexpected mark")
+	}
+	return v, nil
+}
+
+func (m *pickleMachine) top() (interface{}, error) {
+	if len(m.stack) == 0 {
+		return nil, errors.New("stack underflow")
+	}
+	return m.stack[len(m.stack)-1], nil
+}
+
+// popMark returns the items pushed since the last MARK and removes them,
+// together with the mark.
+func (m *pickleMachine) popMark() ([]interface{}, error) {
+	for i := len(m.stack) - 1; i >= 0; i-- {
+		if _, ok := m.stack[i].(pickleMark); ok {
+			items := append([]interface{}(nil), m.stack[i+1:]...)
+			m.stack = m.stack[:i]
+			return items, nil
+		}
+	}
+	return nil, errors.New("mark not found")
+}
+
+func (m *pickleMachine) read(n uint64) ([]byte, error) {
+	if n > maxDecodedSize {
+		return nil, errSerializedTooLarge
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(m.r, buf); err != nil {
+		return nil, errSerializedTruncated
+	}
+	return buf, nil
+}
+
+func (m *pickleMachine) readUint(size int) (uint64, error) {
+	buf, err := m.read(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	var padded [8]byte
+	copy(padded[:], buf)
+	return binary.LittleEndian.Uint64(padded[:]), nil
+}
+
+func (m *pickleMachine) readLine() (string, error) {
+	line, err := m.r.ReadString('\n')
+	if err != nil {
+		return "", errSerializedTruncated
+	}
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+func (m *pickleMachine) run() (interface{}, error) {
+	for {
+		op, err := m.r.ReadByte()
+		if err != nil {
+			return nil, errSerializedTruncated
+		}
+		if op == '.' {
+			return m.pop()
+		}
+		if err := m.step(op); err != nil {
+			return nil, fmt.Errorf("opcode 0x%02x: %v", op, err)
+		}
+	}
+}
+
+func (m *pickleMachine) step(op byte) error {
+	switch op {
+	case 0x80: // PROTO
+		_, err := m.r.ReadByte()
+		return err
+	case 0x95: // FRAME
+		_, err := m.readUint(8)
+		return err
+	case '(': // MARK
+		m.stack = append(m.stack, pickleMark{})
+		return nil
+	case '0': // POP
+		if len(m.stack) == 0 {
+			return errors.New("stack underflow")
+		}
+		m.stack = m.stack[:len(m.stack)-1]
+		return nil
+	case '1': // POP_MARK
+		_, err := m.popMark()
+		return err
+	case '2': // DUP
+		v, err := m.top()
+		if err != nil {
+			return err
+		}
+		return m.push(v)
+
+	case 'N':
+		return m.push(nil)
+	case 0x88: // NEWTRUE
+		return m.push(true)
+	case 0x89: // NEWFALSE
+		return m.push(false)
+	case 'I':
+		line, err := m.readLine()
+		if err != nil {
+			return err
+		}
+		switch line {
+		case "01":
+			return m.push(true)
+		case "00":
+			return m.push(false)
+		}
+		return m.pushInt(line)
+	case 'L':
+		line, err := m.readLine()
+		if err != nil {
+			return err
+		}
+		return m.pushInt(strings.TrimSuffix(line, "L"))
+	case 'J': // BININT
+		n, err := m.readUint(4)
+		if err != nil {
+			return err
+		}
+		return m.push(int64(int32(uint32(n))))
+	case 'K': // BININT1
+		n, err := m.readUint(1)
+		if err != nil {
+			return err
+		}
+		return m.push(int64(n))
+	case 'M': // BININT2
+		n, err := m.readUint(2)
+		if err != nil {
+			return err
+		}
+		return m.push(int64(n))
+	case 0x8a, 0x8b: // LONG1, LONG4
+		size := 1
+		if op == 0x8b {
+			size = 4
+		}
+		n, err := m.readUint(size)
+		if err != nil {
+			return err
+		}
+		data, err := m.read(n)
+		if err != nil {
+			return err
+		}
+		return m.push(pickleLong(data))
+	case 'F':
+		line, err := m.readLine()
+		if err != nil {
+			return err
+		}
+		f, err := strconv.ParseFloat(line, 64)
+		if err != nil {
+			return err
+		}
+		return m.push(f)
+	case 'G': // BINFLOAT
+		data, err := m.read(8)
+		if err != nil {
+			return err
+		}
+		return m.push(math.Float64frombits(binary.BigEndian.Uint64(data)))
+
+	case 'S': // STRING, a quoted Python 2 repr
+		line, err := m.readLine()
+		if err != nil {
+			return err
+		}
+		s, err := strconv.Unquote(pythonQuote(line))
+		if err != nil {
+			return fmt.Errorf("invalid string %s", line)
+		}
+		return m.push([]byte(s))
+	case 'V': // UNICODE, raw-unicode-escape
+		line, err := m.readLine()
+		if err != nil {
+			return err
+		}
+		return m.push(rawUnicodeEscape(line))
+	case 'T', 'U', 'B', 'C', 'X', 0x8c, 0x8d, 0x8e, 0x96:
+		return m.pushSized(op)
+
+	case ']', 'l': // EMPTY_LIST, LIST
+		list := &serializedList{}
+		if op == 'l' {
+			items, err := m.popMark()
+			if err != nil {
+				return err
+			}
+			list.items = items
+		}
+		return m.push(list)
+	case ')':
+		return m.push([]interface{}{})
+	case 't':
+		items, err := m.popMark()
+		if err != nil {
+			return err
+		}
+		return m.push(items)
+	case 0x85, 0x86, 0x87: // TUPLE1..3
+		n := int(op-0x85) + 1
+		if len(m.stack) < n {
+			return errors.New("stack underflow")
+		}
+		items := append([]interface{}(nil), m.stack[len(m.stack)-n:]...)
+		m.stack = m.stack[:len(m.stack)-n]
+		return m.push(items)
+	case '}', 'd': // EMPTY_DICT, DICT
+		dict := newOrderedMap()
+		if op == 'd' {
+			items, err := m.popMark()
+			if err != nil {
+				return err
+			}
+			if err := setPickleItems(dict, items); err != nil {
+				return err
+			}
+		}
+		return m.push(dict)
+	case 0x8f: // EMPTY_SET
+		return m.push(&serializedList{})
+	case 0x91: // FROZENSET
+		items, err := m.popMark()
+		if err != nil {
+			return err
+		}
+		return m.push(&serializedList{items: items})
+
+	case 'a': // APPEND
+		v, err := m.pop()
+		if err != nil {
+			return err
+		}
+		return m.appendItems([]interface{}{v})
+	case 'e', 0x90: // APPENDS, ADDITEMS
+		items, err := m.popMark()
+		if err != nil {
+			return err
+		}
+		return m.appendItems(items)
+	case 's': // SETITEM
+		value, err := m.pop()
+		if err != nil {
+			return err
+		}
+		key, err := m.pop()
+		if err != nil {
+			return err
+		}
+		return m.setItems([]interface{}{key, value})
+	case 'u': // SETITEMS
+		items, err := m.popMark()
+		if err != nil {
+			return err
+		}
+		return m.setItems(items)
+
+	case 'p': // PUT
+		line, err := m.readLine()
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(line)
+		if err != nil {
+			return err
+		}
+		return m.put(n)
+	case 'q', 'r': // BINPUT, LONG_BINPUT
+		n, err := m.readUint(map[byte]int{'q': 1, 'r': 4}[op])
+		if err != nil {
+			return err
+		}
+		return m.put(int(n))
+	case 0x94: // MEMOIZE
+		return m.put(len(m.memo))
+	case 'g': // GET
+		line, err := m.readLine()
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(line)
+		if err != nil {
+			return err
+		}
+		return m.get(n)
+	case 'h', 'j': // BINGET, LONG_BINGET
+		n, err := m.readUint(map[byte]int{'h': 1, 'j': 4}[op])
+		if err != nil {
+			return err
+		}
+		return m.get(int(n))
+
+	case 'c': // GLOBAL
+		module, err := m.readLine()
+		if err != nil {
+			return err
+		}
+		name, err := m.readLine()
+		if err != nil {
+			return err
+		}
+		return m.push(pickleGlobal(module, name))
+	case 0x93: // STACK_GLOBAL
+		name, err := m.pop()
+		if err != nil {
+			return err
+		}
+		module, err := m.pop()
+		if err != nil {
+			return err
+		}
+		return m.push(pickleGlobal(fmt.Sprint(pickleString(module)), fmt.Sprint(pickleString(name))))
+	case 'R': // REDUCE
+		args, err := m.pop()
+		if err != nil {
+			return err
+		}
+		callable, err := m.pop()
+		if err != nil {
+			return err
+		}
+		return m.push(pickleCall(callable, args, nil))
+	case 0x81: // NEWOBJ
+		args, err := m.pop()
+		if err != nil {
+			return err
+		}
+		class, err := m.pop()
+		if err != nil {
+			return err
+		}
+		return m.push(pickleCall(class, args, nil))
+	case 0x92: // NEWOBJ_EX
+		kwargs, err := m.pop()
+		if err != nil {
+			return err
+		}
+		args, err := m.pop()
+		if err != nil {
+			return err
+		}
+		class, err := m.pop()
+		if err != nil {
+			return err
+		}
+		return m.push(pickleCall(class, args, kwargs))
+	case 'i': // INST
+		module, err := m.readLine()
+		if err != nil {
+			return err
+		}
+		name, err := m.readLine()
+		if err != nil {
+			return err
+		}
+		args, err := m.popMark()
+		if err != nil {
+			return err
+		}
+		return m.push(pickleCall(pickleGlobal(module, name), args, nil))
+	case 'o': // OBJ
+		items, err := m.popMark()
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return errors.New("missing class")
+		}
+		return m.push(pickleCall(items[0], items[1:], nil))
+	case 'b': // BUILD
+		state, err := m.pop()
+		if err != nil {
+			return err
+		}
+		obj, err := m.top()
+		if err != nil {
+			return err
+		}
+		target, ok := obj.(*orderedMap)
+		if !ok {
+			return errors.New("BUILD on a non-object")
+		}
+		target.Set("__state", state)
+		return nil
+	case 'P': // PERSID
+		line, err := m.readLine()
+		if err != nil {
+			return err
+		}
+		ref := newOrderedMap()
+		ref.Set("__persistent_id", line)
+		return m.push(ref)
+	case 'Q': // BINPERSID
+		id, err := m.pop()
+		if err != nil {
+			return err
+		}
+		ref := newOrderedMap()
+		ref.Set("__persistent_id", id)
+		return m.push(ref)
+	case 0x82, 0x83, 0x84: // EXT1, EXT2, EXT4
+		n, err := m.readUint(map[byte]int{0x82: 1, 0x83: 2, 0x84: 4}[op])
+		if err != nil {
+			return err
+		}
+		ext := newOrderedMap()
+		ext.Set("__extension", n)
+		return m.push(ext)
+	}
+	return errors.New("unsupported opcode")
+}
+
+func (m *pickleMachine) pushInt(s string) error {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return m.push(n)
+	}
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return fmt.Errorf("invalid integer %q", s)
+	}
+	return m.push(n)
+}
+
+// pushSized handles the length prefixed string and bytes opcodes.
+func (m *pickleMachine) pushSized(op byte) error {
+	size := map[byte]int{'T': 4, 'U': 1, 'B': 4, 'C': 1, 'X': 4, 0x8c: 1, 0x8d: 8, 0x8e: 8, 0x96: 8}[op]
+	n, err := m.readUint(size)
+	if err != nil {
+		return err
+	}
+	data, err := m.read(n)
+	if err != nil {
+		return err
+	}
+	switch op {
+	case 'X', 0x8c, 0x8d: // str
+		return m.push(string(data))
+	}
+	return m.push(data)
+}
+
+func (m *pickleMachine) appendItems(items []interface{}) error {
+	target, err := m.top()
+	if err != nil {
+		return err
+	}
+	list, ok := target.(*serializedList)
+	if !ok {
+		return errors.New("append to a non-list")
+	}
+	list.items = append(list.items, items...)
+	return nil
+}
+
+func (m *pickleMachine) setItems(items []interface{}) error {
+	target, err := m.top()
+	if err != nil {
+		return err
+	}
+	dict, ok := target.(*orderedMap)
+	if !ok {
+		return errors.New("setitem on a non-dict")
+	}
+	return setPickleItems(dict, items)
+}
+
+func (m *pickleMachine) put(n int) error {
+	v, err := m.top()
+	if err != nil {
+		return err
+	}
+	m.memo[n] = v
+	m.refs.add(v, n)
+	return nil
+}
+
+func (m *pickleMachine) get(n int) error {
+	v, ok := m.memo[n]
+	if !ok {
+		return fmt.Errorf("memo %d not found", n)
+	}
+	return m.push(v)
+}
+
+func setPickleItems(dict *orderedMap, items []interface{}) error {
+	if len(items)%2 != 0 {
+		return errors.New("odd number of dict items")
+	}
+	for i := 0; i < len(items); i += 2 {
+		dict.Set(pickleKey(items[i]), items[i+1])
+	}
+	return nil
+}
+
+// pickleKey formats a dict key: strings as is, anything else (ints,
+// tuples) as its JSON form.
+func pickleKey(key interface{}) string {
+	switch key := pickleString(key).(type) {
+	case string:
+		return key
+	default:
+		tree, err := newSerializedWalker(nil).tree(key, 0)
+		if err != nil {
+			return fmt.Sprint(key)
+		}
+		data, err := json.Marshal(tree)
+		if err != nil {
+			return fmt.Sprint(key)
+		}
+		return string(data)
+	}
+}
+
+// pickleString returns Python 2 byte strings as text when they are valid
+// UTF-8.
+func pickleString(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		if s, ok := textOrBase64(b).(string); ok {
+			return s
+		}
+	}
+	return v
+}
+
+func pickleGlobal(module, name string) *orderedMap {
+	g := newOrderedMap()
+	g.Set("__global", module+"."+name)
+	return g
+}
+
+// pickleCall describes calling callable with args. Calls on a class become
+// an object of that class; BUILD adds its state later.
+func pickleCall(callable, args, kwargs interface{}) *orderedMap {
+	name := fmt.Sprint(callable)
+	if g, ok := callable.(*orderedMap); ok {
+		if global, ok := g.values["__global"].(string); ok {
+			name = global
+		}
+	}
+	obj := classObject(name)
+	if list, ok := args.([]interface{}); !ok || len(list) > 0 {
+		obj.Set("__args", args)
+	}
+	if kwargs != nil {
+		obj.Set("__kwargs", kwargs)
+	}
+	return obj
+}
+
+// pickleLong decodes a little-endian two's complement integer.
+func pickleLong(data []byte) interface{} {
+	if len(data) == 0 {
+		return int64(0)
+	}
+	be := make([]byte, len(data))
+	for i, b := range data {
+		be[len(data)-1-i] = b
+	}
+	n := new(big.Int).SetBytes(be)
+	if data[len(data)-1]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(data)*8)))
+	}
+	if n.IsInt64() {
+		return n.Int64()
+	}
+	return n
+}
+
+// pythonQuote turns a Python 2 string repr into a Go quoted string.
+func pythonQuote(repr string) string {
+	if len(repr) >= 2 && repr[0] == '\'' && repr[len(repr)-1] == '\'' {
+		inner := strings.ReplaceAll(repr[1:len(repr)-1], `\'`, `'`)
+		return `"` + strings.ReplaceAll(inner, `"`, `\"`) + `"`
+	}
+	return repr
+}
+
+// rawUnicodeEscape decodes Python's raw-unicode-escape: only \uXXXX and
+// \UXXXXXXXX are escapes, and other bytes are Latin-1.
+func rawUnicodeEscape(s string) string {
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && (s[i+1] == 'u' || s[i+1] == 'U') {
+			size := 4
+			if s[i+1] == 'U' {
+				size = 8
+			}
+			if i+2+size <= len(s) {
+				if r, err := strconv.ParseUint(s[i+2:i+2+size], 16, 32); err == nil {
+					out.WriteRune(rune(r))
+					i += 1 + size
+					continue
+				}
+			}
+		}
+		out.WriteRune(rune(s[i]))
+	}
+	return out.String()
+}
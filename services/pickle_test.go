@@ -0,0 +1,122 @@
+package services
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The fixtures were written by Python 3 with pickle.dumps(value, protocol=n).
+const (
+	// {"id": 7, "name": "João", "ratio": 0.5, "tags": ["x", "y"],
+	//  "nested": (1, 2), "big": 2**70, "neg": -300, "none": None,
+	//  "ok": True, "raw": b"\xff\x00"}
+	pickleProtocol4 = "80049583000000000000007d94288c026964944b078c046e616d65948c054a6fc3a36f948c05726174696f94473fe00000000000008c0474616773945d94288c0178948c017994658c066e6573746564944b014b0286948c03626967948a090000000000000000408c036e6567944ad4feffff8c046e6f6e65944e8c026f6b94888c03726177944302ff0094752e"
+	pickleProtocol2 = "80027d7100285802000000696471014b0758040000006e616d65710258050000004a6fc3a36f71035805000000726174696f7104473fe000000000000058040000007461677371055d710628580100000078710758010000007971086558060000006e657374656471094b014b0286710a5803000000626967710b8a0900000000000000004058030000006e6567710c4ad4feffff58040000006e6f6e65710d4e58020000006f6b710e885803000000726177710f635f636f646563730a656e636f64650a71105803000000c3bf00711158060000006c6174696e317112867113527114752e"
+	pickleProtocol0 = "286470300a5669640a70310a49370a73566e616d650a70320a564a6fe36f0a70330a7356726174696f0a70340a46302e350a7356746167730a70350a286c70360a56780a70370a6156790a70380a6173566e65737465640a70390a2849310a49320a747031300a73566269670a7031310a4c313138303539313632303731373431313330333432344c0a73566e65670a7031320a492d3330300a73566e6f6e650a7031330a4e73566f6b0a7031340a4930310a73567261770a7031350a635f636f646563730a656e636f64650a7031360a2856ff5c75303030300a7031370a566c6174696e310a7031380a747031390a527032300a732e"
+	// An instance of app.models.User with name "Ana" and tags ["a", "b"]
+	pickleObject = "8004953f000000000000008c0a6170702e6d6f64656c73948c04557365729493942981947d94288c046e616d65948c03416e61948c0474616773945d94288c0161948c0162946575622e"
+	// {"a": shared, "b": shared} with shared = [1]
+	pickleMemo = "80027d71002858010000006171015d71024b016158010000006271036802752e"
+	// l = []; 30 times: l = [l, l]
+	pickleShared = "80025d7100285d7101285d7102285d7103285d7104285d7105285d7106285d7107285d7108285d7109285d710a285d710b285d710c285d710d285d710e285d710f285d7110285d7111285d7112285d7113285d7114285d7115285d7116285d7117285d7118285d7119285d711a285d711b285d711c285d711d285d711e681e65681d65681c65681b65681a65681965681865681765681665681565681465681365681265681165681065680f65680e65680d65680c65680b65680a656809656808656807656806656805656804656803656802656801652e"
+)
+
+func pickleFixture(t *testing.T, h string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodePickle(t *testing.T) {
+	fields := `"id":7,"name":"João","ratio":0.5,"tags":["x","y"],"nested":[1,2],"big":1180591620717411303424,"neg":-300,"none":null,"ok":true`
+	// Protocols below 3 have no bytes type; they store bytes as a call to
+	// _codecs.encode, which is shown rather than run.
+	codecs := `{"__class":"_codecs.encode","__args":["ÿ\u0000","latin1"]}`
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"protocol 4", pickleProtocol4, `{` + fields + `,"raw":{"__base64":"/wA="}}`},
+		{"protocol 2", pickleProtocol2, `{` + fields + `,"raw":` + codecs + `}`},
+		{"protocol 0", pickleProtocol0, `{` + fields + `,"raw":` + codecs + `}`},
+		{"object", pickleObject, `{"__class":"app.models.User","__state":{"name":"Ana","tags":["a","b"]}}`},
+		{"memo", pickleMemo, `{"a":[1],"b":{"__ref":2}}`},
+	}
+
+	for _, tt := range tests {
+		decoded, err := decodePickle(pickleFixture(t, tt.value))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got := compactJSON(t, decoded); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestDecodePickle_Invalid(t *testing.T) {
+	full := pickleFixture(t, pickleProtocol4)
+	for name, value := range map[string][]byte{
+		"empty":     {},
+		"truncated": full[:len(full)/2],
+		"no stop":   full[:len(full)-1],
+		"bad memo":  []byte("\x80\x02h\x05."),
+		"underflow": []byte("\x80\x02a."),
+		"buffer":    []byte("\x80\x05\x97."),
+		"huge":      []byte("\x80\x04\x8d\xff\xff\xff\xff\xff\xff\xff\x7f"),
+	} {
+		if _, err := decodePickle(value); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLooksLikePickle(t *testing.T) {
+	if !looksLikePickle(pickleFixture(t, pickleProtocol2), 0) {
+		t.Error("Expected the PROTO header to be recognized")
+	}
+	protocol0 := pickleFixture(t, pickleProtocol0)
+	if looksLikePickle(protocol0, 0) || !looksLikePickle(protocol0, pythonPickledFlag) {
+		t.Error("Expected protocol 0 pickles to need the pickled flag")
+	}
+	if looksLikePickle([]byte(strings.Repeat("x", 10)), pythonPickledFlag) {
+		t.Error("Expected text without a STOP opcode to be rejected")
+	}
+}
+
+func TestDecodePickle_SharedReferences(t *testing.T) {
+	done := make(chan struct{})
+	var decoded []byte
+	var err error
+	go func() {
+		decoded, err = decodePickle(pickleFixture(t, pickleShared))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out decoding a pickle with shared references")
+	}
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(decoded) > 16<<10 || !strings.Contains(compactJSON(t, decoded), `{"__ref":`) {
+		t.Errorf("Expected repeats to be written as references, got %d bytes", len(decoded))
+	}
+
+	// Values shared without a memo entry (DUP) are copied until the
+	// budget runs out.
+	dup := "\x80\x02]" + strings.Repeat("2\x86", 40) + "."
+	if _, err := decodePickle([]byte(dup)); err == nil || !strings.Contains(err.Error(), errSerializedTooLarge.Error()) {
+		t.Errorf("Expected the render budget to run out, got %v", err)
+	}
+}
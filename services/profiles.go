@@ -0,0 +1,209 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+
+	"memcached-management/models"
+)
+
+var (
+	ErrProfileNotFound = errors.New("profile not found")
+	ErrProfileExists   = errors.New("profile already exists")
+	ErrInvalidProfile  = errors.New("invalid profile")
+)
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ProfileStore keeps named connection profiles in a local JSON file.
+type ProfileStore struct {
+	mu       sync.RWMutex
+	path     string
+	profiles map[string]models.Profile
+}
+
+// NewProfileStore loads the profiles stored at path. A missing file starts an
+// empty store; an empty path keeps profiles in memory only.
+func NewProfileStore(path string) (*ProfileStore, error) {
+	store := &ProfileStore{path: path, profiles: make(map[string]models.Profile)}
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles: %v", err)
+	}
+
+	var profiles []models.Profile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("invalid profiles file: %v", err)
+	}
+	for _, profile := range profiles {
+		store.profiles[profile.Name] = profile
+	}
+
+	return store, nil
+}
+
+func (ps *ProfileStore) List() []models.Profile {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	profiles := make([]models.Profile, 0, len(ps.profiles))
+	for _, profile := range ps.profiles {
+		profiles = append(profiles, profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+
+	return profiles
+}
+
+func (ps *ProfileStore) Get(name string) (models.Profile, error) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	profile, ok := ps.profiles[name]
+	if !ok {
+		return models.Profile{}, ErrProfileNotFound
+	}
+	return profile, nil
+}
+
+func (ps *ProfileStore) Create(profile models.Profile) error {
+	if err := validateProfile(&profile); err != nil {
+		return err
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if _, ok := ps.profiles[profile.Name]; ok {
+		return ErrProfileExists
+	}
+	profiles := ps.copyProfiles()
+	profiles[profile.Name] = profile
+
+	return ps.commit(profiles)
+}
+
+// Update replaces the profile called name. Renaming is allowed as long as the
+// new name is not taken.
+func (ps *ProfileStore) Update(name string, profile models.Profile) error {
+	if profile.Name == "" {
+		profile.Name = name
+	}
+	if err := validateProfile(&profile); err != nil {
+		return err
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if _, ok := ps.profiles[name]; !ok {
+		return ErrProfileNotFound
+	}
+	if _, ok := ps.profiles[profile.Name]; ok && profile.Name != name {
+		return ErrProfileExists
+	}
+	profiles := ps.copyProfiles()
+	delete(profiles, name)
+	profiles[profile.Name] = profile
+
+	return ps.commit(profiles)
+}
+
+func (ps *ProfileStore) Delete(name string) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if _, ok := ps.profiles[name]; !ok {
+		return ErrProfileNotFound
+	}
+	profiles := ps.copyProfiles()
+	delete(profiles, name)
+
+	return ps.commit(profiles)
+}
+
+// copyProfiles returns a copy of the profiles for a change to be made on.
+// Callers hold ps.mu.
+func (ps *ProfileStore) copyProfiles() map[string]models.Profile {
+	profiles := make(map[string]models.Profile, len(ps.profiles)+1)
+	for name, profile := range ps.profiles {
+		profiles[name] = profile
+	}
+	return profiles
+}
+
+// commit saves profiles and makes them the store's, leaving the store
+// unchanged when they cannot be saved. Callers hold ps.mu.
+func (ps *ProfileStore) commit(profiles map[string]models.Profile) error {
+	if err := ps.persist(profiles); err != nil {
+		return err
+	}
+	ps.profiles = profiles
+	return nil
+}
+
+// persist writes profiles to a temporary file and renames it over the
+// store so a crash never leaves a truncated file behind.
+func (ps *ProfileStore) persist(profiles map[string]models.Profile) error {
+	if ps.path == "" {
+		return nil
+	}
+
+	list := make([]models.Profile, 0, len(profiles))
+	for _, profile := range profiles {
+		list = append(list, profile)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(ps.path), ".profiles-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save profiles: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save profiles: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save profiles: %v", err)
+	}
+
+	return os.Rename(tmp.Name(), ps.path)
+}
+
+func validateProfile(profile *models.Profile) error {
+	if !profileNamePattern.MatchString(profile.Name) {
+		return fmt.Errorf("%w: name is required and may only contain letters, digits, '.', '_' and '-'", ErrInvalidProfile)
+	}
+
+	servers, err := parseServers(profile.Servers)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProfile, err)
+	}
+	profile.Servers = servers
+
+	if profile.TimeoutMS < 0 {
+		return fmt.Errorf("%w: timeout must not be negative", ErrInvalidProfile)
+	}
+
+	return nil
+}
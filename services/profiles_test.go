@@ -0,0 +1,126 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"memcached-management/models"
+)
+
+func TestProfileStore_CreateAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	store, err := NewProfileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profile := models.Profile{Name: "staging", Servers: []string{"cache-1", "cache-2:11212"}, TimeoutMS: 500, ReadOnly: true}
+	if err := store.Create(profile); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+
+	reloaded, err := NewProfileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := reloaded.Get("staging")
+	if err != nil {
+		t.Fatalf("Expected profile to be persisted: %v", err)
+	}
+	if len(got.Servers) != 2 || got.Servers[0] != "cache-1:11211" || got.Servers[1] != "cache-2:11212" {
+		t.Errorf("Expected normalized servers, got %v", got.Servers)
+	}
+	if got.TimeoutMS != 500 || !got.ReadOnly {
+		t.Errorf("Expected timeout and read-only flag to be persisted, got %+v", got)
+	}
+}
+
+func TestProfileStore_CreateDuplicate(t *testing.T) {
+	store, _ := NewProfileStore("")
+	profile := models.Profile{Name: "dev", Servers: []string{"localhost"}}
+	if err := store.Create(profile); err != nil {
+		t.Fatal(err)
+	}
+
+	err := store.Create(profile)
+	if !errors.Is(err, ErrProfileExists) {
+		t.Errorf("Expected ErrProfileExists, got %v", err)
+	}
+}
+
+func TestProfileStore_Validation(t *testing.T) {
+	store, _ := NewProfileStore("")
+
+	tests := []models.Profile{
+		{Name: "", Servers: []string{"localhost"}},
+		{Name: "prod eu", Servers: []string{"localhost"}},
+		{Name: "prod-eu", Servers: nil},
+		{Name: "prod-eu", Servers: []string{"http://localhost"}},
+		{Name: "prod-eu", Servers: []string{"localhost"}, TimeoutMS: -1},
+	}
+	for _, profile := range tests {
+		if err := store.Create(profile); !errors.Is(err, ErrInvalidProfile) {
+			t.Errorf("Expected ErrInvalidProfile for %+v, got %v", profile, err)
+		}
+	}
+}
+
+func TestProfileStore_UpdateAndDelete(t *testing.T) {
+	store, _ := NewProfileStore("")
+	_ = store.Create(models.Profile{Name: "dev", Servers: []string{"localhost"}})
+	_ = store.Create(models.Profile{Name: "qa", Servers: []string{"localhost"}})
+
+	if err := store.Update("missing", models.Profile{Servers: []string{"localhost"}}); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("Expected ErrProfileNotFound, got %v", err)
+	}
+	if err := store.Update("dev", models.Profile{Name: "qa", Servers: []string{"localhost"}}); !errors.Is(err, ErrProfileExists) {
+		t.Errorf("Expected ErrProfileExists when renaming onto another profile, got %v", err)
+	}
+	if err := store.Update("dev", models.Profile{Name: "local", Servers: []string{"127.0.0.1"}}); err != nil {
+		t.Fatalf("Failed to rename profile: %v", err)
+	}
+	if _, err := store.Get("dev"); !errors.Is(err, ErrProfileNotFound) {
+		t.Error("Expected old profile name to be removed after rename")
+	}
+
+	if err := store.Delete("local"); err != nil {
+		t.Fatalf("Failed to delete profile: %v", err)
+	}
+	if err := store.Delete("local"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("Expected ErrProfileNotFound, got %v", err)
+	}
+
+	profiles := store.List()
+	if len(profiles) != 1 || profiles[0].Name != "qa" {
+		t.Errorf("Expected only 'qa' to remain, got %v", profiles)
+	}
+}
+
+func TestProfileStore_FailedWriteKeepsProfiles(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewProfileStore(filepath.Join(dir, "profiles.json"))
+	if err := store.Create(models.Profile{Name: "dev", Servers: []string{"localhost"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The temporary file cannot be created in a missing directory.
+	store.path = filepath.Join(dir, "missing", "profiles.json")
+	if err := store.Create(models.Profile{Name: "prod", Servers: []string{"cache-1"}}); err == nil {
+		t.Error("Expected create to fail")
+	}
+	if err := store.Update("dev", models.Profile{Name: "local", Servers: []string{"localhost"}}); err == nil {
+		t.Error("Expected update to fail")
+	}
+	if err := store.Delete("dev"); err == nil {
+		t.Error("Expected delete to fail")
+	}
+	if profiles := store.List(); len(profiles) != 1 || profiles[0].Name != "dev" {
+		t.Errorf("Expected the failed writes to leave the profiles unchanged, got %+v", profiles)
+	}
+
+	store.path = filepath.Join(dir, "profiles.json")
+	if err := store.Create(models.Profile{Name: "prod", Servers: []string{"cache-1"}}); err != nil {
+		t.Errorf("Expected a retry to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ProtobufDecoder is the decoder name of protobuf rules. Protobuf has no
+// magic bytes, so it only runs for keys a rule maps to it.
+const ProtobufDecoder = "protobuf"
+
+var ErrInvalidDescriptors = errors.New("invalid descriptor set")
+
+// protoSchemas are the message types of an uploaded FileDescriptorSet.
+type protoSchemas struct {
+	files *protoregistry.Files
+	types *dynamicpb.Types
+}
+
+// SetProtoDescriptors replaces the protobuf schemas with the files of a
+// serialized FileDescriptorSet, as written by
+// `protoc --include_imports --descriptor_set_out`, and returns the full
+// names of its messages.
+func (r *DecoderRegistry) SetProtoDescriptors(data []byte) ([]string, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDescriptors, err)
+	}
+	if len(set.File) == 0 {
+		return nil, fmt.Errorf("%w: no files", ErrInvalidDescriptors)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDescriptors, err)
+	}
+
+	schemas := &protoSchemas{files: files, types: dynamicpb.NewTypes(files)}
+	r.mu.Lock()
+	r.protos = schemas
+	r.mu.Unlock()
+	return schemas.messages(), nil
+}
+
+// ProtoMessages returns the full names of the uploaded message types.
+func (r *DecoderRegistry) ProtoMessages() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.protos.messages()
+}
+
+func (s *protoSchemas) messages() []string {
+	if s == nil {
+		return nil
+	}
+	var names []string
+	s.files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		names = appendMessageNames(names, fd.Messages())
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
+
+func appendMessageNames(names []string, messages protoreflect.MessageDescriptors) []string {
+	for i := 0; i < messages.Len(); i++ {
+		md := messages.Get(i)
+		if md.IsMapEntry() {
+			continue
+		}
+		names = append(names, string(md.FullName()))
+		names = appendMessageNames(names, md.Messages())
+	}
+	return names
+}
+
+// decode renders value as JSON. With a message type it uses the protobuf
+// JSON mapping and returns the type's name. Without one, or when the type
+// is unknown or does not match the value, it falls back to the raw wire
+// format with an empty name; schemaErr then says why the type was not used.
+func (s *protoSchemas) decode(message string, value []byte) (decoded []byte, name string, schemaErr, err error) {
+	if message != "" {
+		decoded, schemaErr = s.decodeMessage(message, value)
+		if schemaErr == nil {
+			return decoded, message, nil, nil
+		}
+	}
+	decoded, err = decodeProtoWire(value)
+	return decoded, "", schemaErr, err
+}
+
+func (s *protoSchemas) decodeMessage(message string, value []byte) ([]byte, error) {
+	if s == nil {
+		return nil, fmt.Errorf("message %s not found: no descriptors uploaded", message)
+	}
+	desc, err := s.files.FindDescriptorByName(protoreflect.FullName(message))
+	if err != nil {
+		return nil, fmt.Errorf("message %s not found", message)
+	}
+	md, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message", message)
+	}
+
+	msg := dynamicpb.NewMessage(md)
+	if err := (proto.UnmarshalOptions{Resolver: s.types}).Unmarshal(value, msg); err != nil {
+		return nil, fmt.Errorf("%s: %v", message, err)
+	}
+	decoded, err := protojson.MarshalOptions{Multiline: true, Indent: "  ", Resolver: s.types}.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", message, err)
+	}
+	return decoded, nil
+}
+
+// decodeProtoWire decodes a message without its schema, like
+// `protoc --decode_raw`: every field is listed with its number, wire type
+// and value. Length delimited fields are shown as text when they are
+// printable, as a nested message when they parse as one, and as base64
+// otherwise.
+func decodeProtoWire(value []byte) ([]byte, error) {
+	fields, err := protoWireFields(value, 0)
+	if err != nil {
+		return nil, fmt.Errorf("protobuf: %v", err)
+	}
+	return marshalSerialized(fields, nil)
+}
+
+func protoWireFields(b []byte, depth int) ([]interface{}, error) {
+	if depth > maxSerializedDepth {
+		return nil, errors.New("nesting too deep")
+	}
+	fields := []interface{}{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		if len(fields) >= maxSerializedItems {
+			return nil, errSerializedTooLarge
+		}
+
+		field := newOrderedMap()
+		field.Set("field", int64(num))
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+			field.Set("type", "varint")
+			field.Set("value", v)
+		case protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+			field.Set("type", "fixed32")
+			field.Set("value", v)
+			field.Set("float", float64(math.Float32frombits(v)))
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+			field.Set("type", "fixed64")
+			field.Set("value", v)
+			field.Set("double", math.Float64frombits(v))
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+			field.Set("type", "bytes")
+			if isPrintable(v) {
+				field.Set("value", string(v))
+			} else if nested, err := protoWireFields(v, depth+1); err == nil && len(nested) > 0 {
+				field.Set("message", nested)
+			} else {
+				field.Set("value", v)
+			}
+		case protowire.StartGroupType:
+			v, n := protowire.ConsumeGroup(num, b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+			nested, err := protoWireFields(v, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			field.Set("type", "group")
+			field.Set("message", nested)
+		default:
+			return nil, fmt.Errorf("unexpected wire type %d", typ)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// isPrintable reports whether b is UTF-8 text without control characters
+// other than whitespace.
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"memcached-management/models"
+)
+
+// testDescriptorSet describes:
+//
+//	package acme.v1;
+//	message User { int64 id = 1; string name = 2; repeated string tags = 3; Address address = 4; }
+//	message Address { string city = 1; }
+func testDescriptorSet(t *testing.T) []byte {
+	t.Helper()
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{Name: proto.String(name), JsonName: proto.String(name), Number: proto.Int32(number), Type: typ.Enum(), Label: label.Enum()}
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	address := field("address", 4, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, optional)
+	address.TypeName = proto.String(".acme.v1.Address")
+
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("acme/v1/user.proto"),
+		Package: proto.String("acme.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("User"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64, optional),
+				field("name", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional),
+				field("tags", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_LABEL_REPEATED),
+				address,
+			}},
+			{Name: proto.String("Address"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("city", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional),
+			}},
+		},
+	}}}
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// testUserMessage is User{id: 7, name: "Ana", tags: ["a"], address: {city: "Recife"}}.
+func testUserMessage() []byte {
+	var address []byte
+	address = protowire.AppendTag(address, 1, protowire.BytesType)
+	address = protowire.AppendString(address, "Recife")
+
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.VarintType)
+	b = protowire.AppendVarint(b, 7)
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendString(b, "Ana")
+	b = protowire.AppendTag(b, 3, protowire.BytesType)
+	b = protowire.AppendString(b, "a")
+	b = protowire.AppendTag(b, 4, protowire.BytesType)
+	return protowire.AppendBytes(b, address)
+}
+
+func TestDecoderRegistry_Protobuf(t *testing.T) {
+	registry := NewDecoderRegistry()
+	messages, err := registry.SetProtoDescriptors(testDescriptorSet(t))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(messages) != 2 || messages[0] != "acme.v1.Address" || messages[1] != "acme.v1.User" {
+		t.Errorf("Unexpected messages %v", messages)
+	}
+
+	err = registry.SetRules([]models.DecoderRule{
+		{Prefix: "user:", Decoder: ProtobufDecoder, Message: "acme.v1.User"},
+		{Prefix: "order:", Decoder: ProtobufDecoder, Message: "acme.v1.Order"},
+		{Prefix: "raw:", Decoder: ProtobufDecoder},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	value := testUserMessage()
+	decoded := registry.Decode("user:1", value, 0)
+	if decoded == nil || decoded.Message != "acme.v1.User" {
+		t.Fatalf("Expected the value to be decoded as acme.v1.User, got %+v", decoded)
+	}
+	if got := compactJSON(t, decoded.Tree); got != `{"id":"7","name":"Ana","tags":["a"],"address":{"city":"Recife"}}` {
+		t.Errorf("Unexpected tree %s", got)
+	}
+
+	wire := `[{"field":1,"type":"varint","value":7},{"field":2,"type":"bytes","value":"Ana"},{"field":3,"type":"bytes","value":"a"},` +
+		`{"field":4,"type":"bytes","message":[{"field":1,"type":"bytes","value":"Recife"}]}]`
+	for key, schemaErr := range map[string]string{"raw:1": "", "order:1": "message acme.v1.Order not found"} {
+		decoded := registry.Decode(key, value, 0)
+		if decoded == nil || decoded.Message != "" || decoded.Steps[0] != ProtobufDecoder {
+			t.Errorf("%s: expected the raw wire format, got %+v", key, decoded)
+			continue
+		}
+		if got := compactJSON(t, decoded.Tree); got != wire {
+			t.Errorf("%s: unexpected tree %s", key, got)
+		}
+		if decoded.Error != schemaErr {
+			t.Errorf("%s: expected error %q, got %q", key, schemaErr, decoded.Error)
+		}
+	}
+
+	if decoded := registry.Decode("other:1", value, 0); decoded != nil && len(decoded.Steps) > 0 && decoded.Steps[0] == ProtobufDecoder {
+		t.Errorf("Expected protobuf to only run through rules, got %+v", decoded)
+	}
+	if decoded := registry.Decode("user:2", []byte{0x08}, 0); decoded == nil || decoded.Error == "" {
+		t.Errorf("Expected a truncated message to be reported, got %+v", decoded)
+	}
+}
+
+func TestDecoderRegistry_ProtobufSchemaMismatch(t *testing.T) {
+	registry := NewDecoderRegistry()
+	rules := []models.DecoderRule{{Prefix: "user:", Decoder: ProtobufDecoder, Message: "acme.v1.User"}}
+	if err := registry.SetRules(rules); err != nil {
+		t.Fatal(err)
+	}
+
+	value := testUserMessage()
+	decoded := registry.Decode("user:1", value, 0)
+	if decoded == nil || decoded.Error != "message acme.v1.User not found: no descriptors uploaded" || len(decoded.Tree) == 0 {
+		t.Errorf("Expected the wire format with a missing schema error, got %+v", decoded)
+	}
+
+	if _, err := registry.SetProtoDescriptors(testDescriptorSet(t)); err != nil {
+		t.Fatal(err)
+	}
+	// name is a proto3 string, which must be valid UTF-8.
+	var invalid []byte
+	invalid = protowire.AppendTag(invalid, 2, protowire.BytesType)
+	invalid = protowire.AppendBytes(invalid, []byte{0xff, 0xfe})
+	decoded = registry.Decode("user:2", invalid, 0)
+	if decoded == nil || decoded.Message != "" || len(decoded.Tree) == 0 {
+		t.Fatalf("Expected the wire format, got %+v", decoded)
+	}
+	if !strings.HasPrefix(decoded.Error, "acme.v1.User: ") || !strings.Contains(decoded.Error, "UTF-8") {
+		t.Errorf("Expected the unmarshal error, got %q", decoded.Error)
+	}
+}
+
+func TestDecodeProtoWire_Types(t *testing.T) {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.Fixed32Type)
+	b = protowire.AppendFixed32(b, 0x3f800000)
+	b = protowire.AppendTag(b, 2, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, 0x4000000000000000)
+	b = protowire.AppendTag(b, 3, protowire.BytesType)
+	b = protowire.AppendBytes(b, []byte{0xff, 0xff})
+
+	decoded, err := decodeProtoWire(b)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := `[{"field":1,"type":"fixed32","value":1065353216,"float":1},{"field":2,"type":"fixed64","value":4611686018427387904,"double":2},` +
+		`{"field":3,"type":"bytes","value":{"__base64":"//8="}}]`
+	if got := compactJSON(t, decoded); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestSetProtoDescriptors_Invalid(t *testing.T) {
+	registry := NewDecoderRegistry()
+	unresolved, _ := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:       proto.String("a.proto"),
+		Dependency: []string{"missing.proto"},
+	}}})
+	for name, data := range map[string][]byte{
+		"garbage":    []byte("not a descriptor set"),
+		"empty":      {},
+		"unresolved": unresolved,
+	} {
+		if _, err := registry.SetProtoDescriptors(data); !errors.Is(err, ErrInvalidDescriptors) {
+			t.Errorf("%s: expected ErrInvalidDescriptors, got %v", name, err)
+		}
+	}
+
+	if err := registry.SetRules([]models.DecoderRule{{Prefix: "a:", Decoder: "json", Message: "acme.v1.User"}}); !errors.Is(err, ErrInvalidDecoderRule) {
+		t.Errorf("Expected a message on a non-protobuf rule to be rejected, got %v", err)
+	}
+}
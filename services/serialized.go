@@ -0,0 +1,218 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// The language serialization decoders (PHP, pickle, Java) only parse their
+// formats into plain values; they never load classes or run code. Objects
+// become maps with their class under "__class".
+
+const (
+	// maxSerializedDepth bounds nesting, which also cuts cycles through
+	// values without a reference number short
+	maxSerializedDepth = 64
+	// maxSerializedItems bounds the containers and elements a decoder may
+	// create or render, so a small value cannot claim huge allocations
+	maxSerializedItems = 1 << 20
+	// maxSerializedBytes bounds the text of a rendered value
+	maxSerializedBytes = 32 << 20
+)
+
+var (
+	errSerializedTruncated = errors.New("unexpected end of data")
+	errSerializedTooLarge  = errors.New("too many elements")
+)
+
+// orderedMap is a JSON object that keeps its keys in insertion order, as
+// PHP arrays and Python dicts do. It is a pointer type so that containers
+// filled in after being referenced (pickle memo, Java handles) are seen
+// complete by every reference.
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newOrderedMap() *orderedMap {
+	return &orderedMap{values: make(map[string]interface{})}
+}
+
+func (m *orderedMap) Set(key string, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// serializedList is a list that may still grow after being referenced.
+type serializedList struct {
+	items []interface{}
+}
+
+// serializedRefs numbers the values a stream can refer to again (pickle
+// memo entries, Java handles), so that repeats are written as
+// {"__ref": n} instead of being copied.
+type serializedRefs map[interface{}]int
+
+// add records v as number n unless it already has one. Values without an
+// identity, such as numbers and strings, are skipped.
+func (r serializedRefs) add(v interface{}, n int) {
+	if key, ok := serializedIdentity(v); ok {
+		if _, known := r[key]; !known {
+			r[key] = n
+		}
+	}
+}
+
+// serializedIdentity returns a comparable key for containers and byte
+// strings: their pointer, or the address of their first element.
+func serializedIdentity(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case *orderedMap, *serializedList:
+		return v, true
+	case []interface{}:
+		if len(v) > 0 {
+			return &v[0], true
+		}
+	case []byte:
+		if len(v) > 0 {
+			return &v[0], true
+		}
+	}
+	return nil, false
+}
+
+// serializedWalker copies a decoded value into a tree that json can marshal
+// safely: a numbered value is written once and then as {"__ref": n},
+// anything nested deeper than maxSerializedDepth is replaced by a marker,
+// and numbers JSON cannot represent become strings. The copy is bounded by
+// maxSerializedItems nodes and maxSerializedBytes of text, since shared
+// values without a number are still copied.
+type serializedWalker struct {
+	refs    serializedRefs
+	emitted map[interface{}]bool
+	nodes   int
+	bytes   int
+}
+
+func newSerializedWalker(refs serializedRefs) *serializedWalker {
+	return &serializedWalker{refs: refs, emitted: make(map[interface{}]bool)}
+}
+
+func (w *serializedWalker) tree(v interface{}, depth int) (interface{}, error) {
+	if w.nodes++; w.nodes > maxSerializedItems {
+		return nil, errSerializedTooLarge
+	}
+	if depth > maxSerializedDepth {
+		return "<max depth reached>", nil
+	}
+	if key, ok := serializedIdentity(v); ok {
+		if n, ok := w.refs[key]; ok {
+			if w.emitted[key] {
+				ref := newOrderedMap()
+				ref.Set("__ref", n)
+				return ref, nil
+			}
+			w.emitted[key] = true
+		}
+	}
+
+	switch v := v.(type) {
+	case *orderedMap:
+		m := newOrderedMap()
+		for _, key := range v.keys {
+			value, err := w.tree(v.values[key], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			m.Set(key, value)
+		}
+		return m, nil
+	case *serializedList:
+		return w.list(v.items, depth)
+	case []interface{}:
+		return w.list(v, depth)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return strconv.FormatFloat(v, 'g', -1, 64), nil
+		}
+		return v, nil
+	case string:
+		return v, w.text(len(v))
+	case []byte:
+		return textOrBase64(v), w.text(len(v))
+	}
+	return v, nil
+}
+
+func (w *serializedWalker) list(v []interface{}, depth int) (interface{}, error) {
+	items := make([]interface{}, len(v))
+	for i, item := range v {
+		var err error
+		if items[i], err = w.tree(item, depth+1); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+func (w *serializedWalker) text(n int) error {
+	if w.bytes += n; w.bytes > maxSerializedBytes {
+		return errSerializedTooLarge
+	}
+	return nil
+}
+
+// marshalSerialized renders a decoded value as indented JSON. refs numbers
+// the values the format lets it refer to again; it may be nil.
+func marshalSerialized(v interface{}, refs serializedRefs) ([]byte, error) {
+	tree, err := newSerializedWalker(refs).tree(v, 0)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(tree, "", "  ")
+}
+
+// textOrBase64 returns raw bytes as a string when they are valid UTF-8 and
+// as {"__base64": "..."} otherwise.
+func textOrBase64(b []byte) interface{} {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	m := newOrderedMap()
+	m.Set("__base64", base64.StdEncoding.EncodeToString(b))
+	return m
+}
+
+// classObject starts the map of an object of class name.
+func classObject(name string) *orderedMap {
+	m := newOrderedMap()
+	m.Set("__class", name)
+	return m
+}
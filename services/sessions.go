@@ -0,0 +1,142 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SessionManager gives every browser session its own MemcachedService so
+// connecting in one tab never redirects another user's operations.
+type SessionManager struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+	idleTTL  time.Duration
+	now      func() time.Time
+}
+
+type session struct {
+	service  *MemcachedService
+	lastSeen time.Time
+}
+
+func NewSessionManager(idleTTL time.Duration) *SessionManager {
+	return &SessionManager{
+		sessions: make(map[string]*session),
+		idleTTL:  idleTTL,
+		now:      time.Now,
+	}
+}
+
+// Get returns the service bound to id. Unknown or empty ids get a fresh
+// session, and the id actually in use is returned alongside the service.
+func (m *SessionManager) Get(id string) (string, *MemcachedService) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if sess, ok := m.sessions[id]; ok && id != "" {
+		sess.lastSeen = m.now()
+		return id, sess.service
+	}
+
+	id = newSessionID()
+	sess := &session{service: NewMemcachedService(), lastSeen: m.now()}
+	m.sessions[id] = sess
+
+	return id, sess.service
+}
+
+// Remove ends a session and closes its connection.
+func (m *SessionManager) Remove(id string) {
+	m.mu.Lock()
+	sess, ok := m.sessions[id]
+	delete(m.sessions, id)
+	m.mu.Unlock()
+
+	if ok {
+		sess.service.Disconnect()
+	}
+}
+
+// Expire removes sessions idle for longer than the configured TTL and
+// returns how many were dropped.
+func (m *SessionManager) Expire() int {
+	m.mu.Lock()
+	var expired []*session
+	cutoff := m.now().Add(-m.idleTTL)
+	for id, sess := range m.sessions {
+		if sess.lastSeen.Before(cutoff) {
+			expired = append(expired, sess)
+			delete(m.sessions, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, sess := range expired {
+		sess.service.Disconnect()
+	}
+
+	return len(expired)
+}
+
+// StartJanitor expires idle sessions every interval until the returned stop
+// function is called.
+func (m *SessionManager) StartJanitor(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				m.Expire()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// ConnectedServers returns the distinct servers any session is connected to.
+func (m *SessionManager) ConnectedServers() []string {
+	m.mu.Lock()
+	services := make([]*MemcachedService, 0, len(m.sessions))
+	for _, sess := range m.sessions {
+		services = append(services, sess.service)
+	}
+	m.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var servers []string
+	for _, service := range services {
+		for _, server := range service.Servers() {
+			if !seen[server] {
+				seen[server] = true
+				servers = append(servers, server)
+			}
+		}
+	}
+	sort.Strings(servers)
+
+	return servers
+}
+
+func (m *SessionManager) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions)
+}
+
+func newSessionID() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
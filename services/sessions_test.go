@@ -0,0 +1,68 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionManager_GetCreatesSession(t *testing.T) {
+	manager := NewSessionManager(time.Hour)
+
+	id, service := manager.Get("")
+	if id == "" || service == nil {
+		t.Fatal("Expected a new session to be created")
+	}
+
+	sameID, sameService := manager.Get(id)
+	if sameID != id || sameService != service {
+		t.Error("Expected the same session to be returned for a known id")
+	}
+
+	otherID, otherService := manager.Get("unknown")
+	if otherID == "unknown" || otherID == id || otherService == service {
+		t.Error("Expected unknown ids to start a separate session")
+	}
+
+	if manager.Count() != 2 {
+		t.Errorf("Expected 2 sessions, got %d", manager.Count())
+	}
+}
+
+func TestSessionManager_Expire(t *testing.T) {
+	manager := NewSessionManager(time.Minute)
+	now := time.Now()
+	manager.now = func() time.Time { return now }
+
+	idle, _ := manager.Get("")
+	active, _ := manager.Get("")
+
+	now = now.Add(45 * time.Second)
+	manager.Get(active)
+
+	now = now.Add(30 * time.Second)
+	if expired := manager.Expire(); expired != 1 {
+		t.Errorf("Expected 1 expired session, got %d", expired)
+	}
+
+	if id, _ := manager.Get(idle); id == idle {
+		t.Error("Expected idle session to be expired")
+	}
+	if id, _ := manager.Get(active); id != active {
+		t.Error("Expected active session to be kept")
+	}
+}
+
+func TestSessionManager_Remove(t *testing.T) {
+	manager := NewSessionManager(time.Hour)
+	id, service := manager.Get("")
+	_ = service.Connect("localhost")
+
+	manager.Remove(id)
+
+	if service.IsConnected() {
+		t.Error("Expected removed session to be disconnected")
+	}
+	if manager.Count() != 0 {
+		t.Errorf("Expected no sessions, got %d", manager.Count())
+	}
+}
@@ -0,0 +1,294 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"memcached-management/models"
+)
+
+// Stats collects the stats, settings, slabs, items and conns reports of
+// every server. A server that cannot be queried is reported with its error
+// instead of failing the whole call.
+func (s *MemcachedService) Stats() ([]models.ServerStats, error) {
+	st, err := s.state()
+	if err != nil {
+		return nil, err
+	}
+
+	return CollectStats(st.servers, st.timeout), nil
+}
+
+// CollectStats queries every server in parallel, keeping the input order.
+func CollectStats(servers []string, timeout time.Duration) []models.ServerStats {
+	stats := make([]models.ServerStats, len(servers))
+
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+			stats[i] = serverStats(server, timeout)
+		}(i, server)
+	}
+	wg.Wait()
+
+	return stats
+}
+
+func serverStats(server string, timeout time.Duration) models.ServerStats {
+	result := models.ServerStats{Server: server}
+
+	conn, err := net.DialTimeout("tcp", server, timeout)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to connect: %v", err)
+		return result
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reports := make(map[string]map[string]string)
+	for _, cmd := range []string{"stats", "stats settings", "stats slabs", "stats items", "stats conns"} {
+		conn.SetDeadline(time.Now().Add(timeout))
+		if _, err := fmt.Fprintf(conn, "%s\r\n", cmd); err != nil {
+			result.Error = fmt.Sprintf("%s: %v", cmd, err)
+			return result
+		}
+		report, err := readStats(reader)
+		if err != nil {
+			result.Error = fmt.Sprintf("%s: %v", cmd, err)
+			return result
+		}
+		reports[cmd] = report
+	}
+
+	result.General = parseGeneralStats(reports["stats"])
+	result.Settings = reports["stats settings"]
+	result.Slabs, result.ActiveSlabs, result.TotalMalloced = parseSlabStats(reports["stats slabs"])
+	result.Items = parseItemStats(reports["stats items"])
+	result.Conns = parseConnStats(reports["stats conns"])
+
+	return result
+}
+
+// readStats reads "STAT <name> <value>" lines up to END.
+func readStats(r *bufio.Reader) (map[string]string, error) {
+	stats := make(map[string]string)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "END":
+			return stats, nil
+		case line == "ERROR", strings.HasPrefix(line, "CLIENT_ERROR"), strings.HasPrefix(line, "SERVER_ERROR"):
+			return nil, fmt.Errorf("server replied %q", line)
+		}
+
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) == 3 && fields[0] == "STAT" {
+			stats[fields[1]] = fields[2]
+		} else if len(fields) == 2 && fields[0] == "STAT" {
+			stats[fields[1]] = ""
+		}
+	}
+}
+
+func parseUint(value string) uint64 {
+	n, _ := strconv.ParseUint(value, 10, 64)
+	return n
+}
+
+func parseGeneralStats(raw map[string]string) models.GeneralStats {
+	u := func(name string) uint64 { return parseUint(raw[name]) }
+
+	general := models.GeneralStats{
+		PID:              u("pid"),
+		Version:          raw["version"],
+		Uptime:           u("uptime"),
+		Time:             u("time"),
+		Threads:          u("threads"),
+		CurrConnections:  u("curr_connections"),
+		TotalConnections: u("total_connections"),
+		RejectedConns:    u("rejected_connections"),
+		CmdGet:           u("cmd_get"),
+		CmdSet:           u("cmd_set"),
+		CmdFlush:         u("cmd_flush"),
+		CmdTouch:         u("cmd_touch"),
+		GetHits:          u("get_hits"),
+		GetMisses:        u("get_misses"),
+		GetExpired:       u("get_expired"),
+		DeleteHits:       u("delete_hits"),
+		DeleteMisses:     u("delete_misses"),
+		IncrHits:         u("incr_hits"),
+		IncrMisses:       u("incr_misses"),
+		DecrHits:         u("decr_hits"),
+		DecrMisses:       u("decr_misses"),
+		CasHits:          u("cas_hits"),
+		CasMisses:        u("cas_misses"),
+		CasBadval:        u("cas_badval"),
+		BytesRead:        u("bytes_read"),
+		BytesWritten:     u("bytes_written"),
+		LimitMaxBytes:    u("limit_maxbytes"),
+		Bytes:            u("bytes"),
+		CurrItems:        u("curr_items"),
+		TotalItems:       u("total_items"),
+		Evictions:        u("evictions"),
+		Reclaimed:        u("reclaimed"),
+		Expired:          u("expired_unfetched"),
+		Raw:              raw,
+	}
+
+	if lookups := general.GetHits + general.GetMisses; lookups > 0 {
+		general.HitRatio = float64(general.GetHits) / float64(lookups)
+	}
+
+	return general
+}
+
+// splitIndexed splits names such as "1:chunk_size" into the numeric prefix
+// and the field name.
+func splitIndexed(name string) (int, string, bool) {
+	prefix, field, ok := strings.Cut(name, ":")
+	if !ok {
+		return 0, "", false
+	}
+	id, err := strconv.Atoi(prefix)
+	if err != nil {
+		return 0, "", false
+	}
+	return id, field, true
+}
+
+func parseSlabStats(raw map[string]string) ([]models.SlabStats, uint64, uint64) {
+	slabs := make(map[int]*models.SlabStats)
+	for name, value := range raw {
+		id, field, ok := splitIndexed(name)
+		if !ok {
+			continue
+		}
+		slab, ok := slabs[id]
+		if !ok {
+			slab = &models.SlabStats{ID: id}
+			slabs[id] = slab
+		}
+
+		n := parseUint(value)
+		switch field {
+		case "chunk_size":
+			slab.ChunkSize = n
+		case "chunks_per_page":
+			slab.ChunksPerPage = n
+		case "total_pages":
+			slab.TotalPages = n
+		case "total_chunks":
+			slab.TotalChunks = n
+		case "used_chunks":
+			slab.UsedChunks = n
+		case "free_chunks":
+			slab.FreeChunks = n
+		case "mem_requested":
+			slab.MemRequested = n
+		case "get_hits":
+			slab.GetHits = n
+		case "cmd_set":
+			slab.CmdSet = n
+		case "delete_hits":
+			slab.DeleteHits = n
+		}
+	}
+
+	result := make([]models.SlabStats, 0, len(slabs))
+	for _, slab := range slabs {
+		result = append(result, *slab)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	return result, parseUint(raw["active_slabs"]), parseUint(raw["total_malloced"])
+}
+
+func parseItemStats(raw map[string]string) []models.ItemStats {
+	items := make(map[int]*models.ItemStats)
+	for name, value := range raw {
+		id, field, ok := splitIndexed(strings.TrimPrefix(name, "items:"))
+		if !ok {
+			continue
+		}
+		item, ok := items[id]
+		if !ok {
+			item = &models.ItemStats{SlabID: id}
+			items[id] = item
+		}
+
+		n := parseUint(value)
+		switch field {
+		case "number":
+			item.Number = n
+		case "age":
+			item.Age = n
+		case "evicted":
+			item.Evicted = n
+		case "evicted_nonzero":
+			item.EvictedNonzero = n
+		case "evicted_time":
+			item.EvictedTime = n
+		case "outofmemory":
+			item.OutOfMemory = n
+		case "reclaimed":
+			item.Reclaimed = n
+		case "expired_unfetched":
+			item.ExpiredUnfetch = n
+		}
+	}
+
+	result := make([]models.ItemStats, 0, len(items))
+	for _, item := range items {
+		result = append(result, *item)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].SlabID < result[j].SlabID })
+
+	return result
+}
+
+func parseConnStats(raw map[string]string) []models.ConnStats {
+	conns := make(map[int]*models.ConnStats)
+	for name, value := range raw {
+		fd, field, ok := splitIndexed(name)
+		if !ok {
+			continue
+		}
+		conn, ok := conns[fd]
+		if !ok {
+			conn = &models.ConnStats{FD: fd}
+			conns[fd] = conn
+		}
+
+		switch field {
+		case "addr":
+			conn.Addr = value
+		case "listen_addr":
+			conn.ListenAddr = value
+		case "state":
+			conn.State = value
+		case "secs_since_last_cmd":
+			conn.SecsSinceLastCmd = parseUint(value)
+		}
+	}
+
+	result := make([]models.ConnStats, 0, len(conns))
+	for _, conn := range conns {
+		result = append(result, *conn)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].FD < result[j].FD })
+
+	return result
+}
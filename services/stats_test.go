@@ -0,0 +1,118 @@
+package services
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestReadStats(t *testing.T) {
+	input := "STAT pid 1\r\nSTAT version 1.6.21\r\nSTAT empty\r\nEND\r\nSTAT next 2\r\n"
+	reader := bufio.NewReader(strings.NewReader(input))
+
+	stats, err := readStats(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats["pid"] != "1" || stats["version"] != "1.6.21" {
+		t.Errorf("Unexpected stats %v", stats)
+	}
+	if _, ok := stats["empty"]; !ok {
+		t.Error("Expected STAT lines without value to be kept")
+	}
+	if _, ok := stats["next"]; ok {
+		t.Error("Expected reading to stop at END")
+	}
+}
+
+func TestReadStats_Error(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("ERROR\r\n"))
+	if _, err := readStats(reader); err == nil {
+		t.Error("Expected error for unsupported stats command")
+	}
+}
+
+func TestParseGeneralStats(t *testing.T) {
+	general := parseGeneralStats(map[string]string{
+		"uptime":         "3600",
+		"version":        "1.6.21",
+		"get_hits":       "75",
+		"get_misses":     "25",
+		"evictions":      "4",
+		"limit_maxbytes": "67108864",
+		"curr_items":     "10",
+	})
+
+	if general.Uptime != 3600 || general.Version != "1.6.21" {
+		t.Errorf("Unexpected uptime/version: %+v", general)
+	}
+	if general.HitRatio != 0.75 {
+		t.Errorf("Expected hit ratio 0.75, got %v", general.HitRatio)
+	}
+	if general.Evictions != 4 || general.LimitMaxBytes != 67108864 || general.CurrItems != 10 {
+		t.Errorf("Unexpected counters: %+v", general)
+	}
+
+	if empty := parseGeneralStats(map[string]string{}); empty.HitRatio != 0 {
+		t.Errorf("Expected hit ratio 0 without lookups, got %v", empty.HitRatio)
+	}
+}
+
+func TestParseSlabStats(t *testing.T) {
+	slabs, active, malloced := parseSlabStats(map[string]string{
+		"2:chunk_size":   "120",
+		"1:chunk_size":   "96",
+		"1:used_chunks":  "3",
+		"active_slabs":   "2",
+		"total_malloced": "2097152",
+	})
+
+	if active != 2 || malloced != 2097152 {
+		t.Errorf("Unexpected totals: active=%d malloced=%d", active, malloced)
+	}
+	if len(slabs) != 2 || slabs[0].ID != 1 || slabs[1].ID != 2 {
+		t.Fatalf("Expected slabs 1 and 2 in order, got %+v", slabs)
+	}
+	if slabs[0].ChunkSize != 96 || slabs[0].UsedChunks != 3 || slabs[1].ChunkSize != 120 {
+		t.Errorf("Unexpected slab fields: %+v", slabs)
+	}
+}
+
+func TestParseItemStats(t *testing.T) {
+	items := parseItemStats(map[string]string{
+		"items:1:number":  "5",
+		"items:1:evicted": "2",
+		"items:3:age":     "60",
+	})
+
+	if len(items) != 2 || items[0].SlabID != 1 || items[1].SlabID != 3 {
+		t.Fatalf("Expected item stats for slabs 1 and 3, got %+v", items)
+	}
+	if items[0].Number != 5 || items[0].Evicted != 2 || items[1].Age != 60 {
+		t.Errorf("Unexpected item fields: %+v", items)
+	}
+}
+
+func TestParseConnStats(t *testing.T) {
+	conns := parseConnStats(map[string]string{
+		"5:addr":                "tcp:127.0.0.1:54321",
+		"5:state":               "conn_parse_cmd",
+		"5:secs_since_last_cmd": "0",
+		"3:listen_addr":         "tcp:0.0.0.0:11211",
+	})
+
+	if len(conns) != 2 || conns[0].FD != 3 || conns[1].FD != 5 {
+		t.Fatalf("Expected conns 3 and 5, got %+v", conns)
+	}
+	if conns[1].Addr != "tcp:127.0.0.1:54321" || conns[1].State != "conn_parse_cmd" {
+		t.Errorf("Unexpected conn fields: %+v", conns[1])
+	}
+}
+
+func TestStats_NotConnected(t *testing.T) {
+	service := NewMemcachedService()
+	_, err := service.Stats()
+	if err == nil || err.Error() != "not connected to Memcached" {
+		t.Errorf("Expected 'not connected to Memcached', got '%v'", err)
+	}
+}
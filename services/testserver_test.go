@@ -0,0 +1,222 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startFakeServer runs a TCP server that answers each command line with the
+// text returned by respond. respond may read a command's data block from r.
+func startFakeServer(t *testing.T, respond func(line string, r *bufio.Reader) string) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if _, err := conn.Write([]byte(respond(strings.TrimRight(line, "\r\n"), reader))); err != nil {
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// fakeItem is an item held by a fakeCache.
+type fakeItem struct {
+	value string
+	flags uint32
+	// ttl is the remaining time to live in seconds, -1 when the item never
+	// expires
+	ttl int64
+	cas uint64
+	// gone marks an item the metadump still lists but that expired before
+	// it was read
+	gone bool
+	// stored is the command that last wrote the item, "" when the test did
+	stored string
+}
+
+// fakeCache is the data of a fake server started with startCacheServer. Its
+// metadump lists the keys in the order they were first stored and every
+// write bumps the CAS id.
+type fakeCache struct {
+	mu    sync.Mutex
+	keys  []string
+	items map[string]fakeItem
+	next  uint64
+	// scans counts the metadumps served
+	scans int
+	// noMeta answers mg with ERROR, as servers without the meta protocol do
+	noMeta bool
+	// noCAS answers gets with CAS id 0, as servers started with -C do
+	noCAS bool
+	// hold, when set, runs before each set or add is answered
+	hold func()
+}
+
+func newFakeCache() *fakeCache {
+	return &fakeCache{items: make(map[string]fakeItem)}
+}
+
+// set stores value with flags 0 and no expiration.
+func (fc *fakeCache) set(key, value string) {
+	fc.put(key, fakeItem{value: value, ttl: -1})
+}
+
+func (fc *fakeCache) put(key string, item fakeItem) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.store(key, item)
+}
+
+// store saves item under a new CAS id. The caller holds fc.mu.
+func (fc *fakeCache) store(key string, item fakeItem) {
+	if _, ok := fc.items[key]; !ok {
+		fc.keys = append(fc.keys, key)
+	}
+	fc.next++
+	item.cas = fc.next
+	fc.items[key] = item
+}
+
+func (fc *fakeCache) delete(key string) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.remove(key)
+}
+
+// remove drops key. The caller holds fc.mu.
+func (fc *fakeCache) remove(key string) {
+	delete(fc.items, key)
+	for i, k := range fc.keys {
+		if k == key {
+			fc.keys = append(fc.keys[:i], fc.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// item returns the live item stored under key.
+func (fc *fakeCache) item(key string) (fakeItem, bool) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	item, ok := fc.items[key]
+	return item, ok && !item.gone
+}
+
+// startCacheServer fakes a server over cache. It answers version, the
+// metadump, mg, get, gets, delete, set and add; anything else is an
+// ERROR.
+func startCacheServer(t *testing.T, cache *fakeCache) string {
+	return startFakeServer(t, func(line string, r *bufio.Reader) string {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return "ERROR\r\n"
+		}
+
+		var data []byte
+		if (fields[0] == "set" || fields[0] == "add") && len(fields) >= 5 {
+			size, _ := strconv.Atoi(fields[4])
+			data = make([]byte, size+2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return "CLIENT_ERROR bad data chunk\r\n"
+			}
+			data = data[:size]
+			if cache.hold != nil {
+				cache.hold()
+			}
+		}
+
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+
+		var item fakeItem
+		live := false
+		if len(fields) > 1 {
+			item, live = cache.items[fields[1]]
+			live = live && !item.gone
+		}
+
+		switch {
+		case line == "version":
+			return "VERSION 1.6.21\r\n"
+		case line == "lru_crawler metadump all":
+			cache.scans++
+			var reply strings.Builder
+			for _, key := range cache.keys {
+				item := cache.items[key]
+				exp := int64(-1)
+				if item.ttl >= 0 {
+					exp = time.Now().Unix() + item.ttl
+				}
+				fmt.Fprintf(&reply, "key=%s exp=%d la=1 cas=%d fetch=no cls=1 size=%d\n", url.PathEscape(key), exp, item.cas, len(item.value))
+			}
+			return reply.String() + "END\r\n"
+		case len(fields) < 2:
+		case fields[0] == "mg" && !cache.noMeta:
+			if !live {
+				return "EN\r\n"
+			}
+			return fmt.Sprintf("VA %d t%d s%d f%d c%d l1\r\n%s\r\n", len(item.value), item.ttl, len(item.value), item.flags, item.cas, item.value)
+		case fields[0] == "get", fields[0] == "gets":
+			if !live {
+				return "END\r\n"
+			}
+			reply := fmt.Sprintf("VALUE %s %d %d", fields[1], item.flags, len(item.value))
+			if fields[0] == "gets" {
+				cas := item.cas
+				if cache.noCAS {
+					cas = 0
+				}
+				reply += fmt.Sprintf(" %d", cas)
+			}
+			return reply + "\r\n" + item.value + "\r\nEND\r\n"
+		case fields[0] == "delete":
+			if !live {
+				return "NOT_FOUND\r\n"
+			}
+			cache.remove(fields[1])
+			return "DELETED\r\n"
+		case (fields[0] == "set" || fields[0] == "add") && len(fields) >= 5:
+			if fields[0] == "add" && live {
+				return "NOT_STORED\r\n"
+			}
+			flags, _ := strconv.ParseUint(fields[2], 10, 32)
+			ttl, _ := strconv.ParseInt(fields[3], 10, 64)
+			if ttl == 0 {
+				ttl = -1
+			}
+			cache.store(fields[1], fakeItem{value: string(data), flags: uint32(flags), ttl: ttl, stored: fields[0]})
+			return "STORED\r\n"
+		}
+		return "ERROR\r\n"
+	})
+}
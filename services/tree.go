@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"memcached-management/models"
+)
+
+const (
+	defaultTreeDelimiters = ":"
+	// maxTreeChildren caps the children returned per node, largest first
+	maxTreeChildren = 500
+)
+
+// KeyTree groups the keys under prefix by their next namespace segment.
+// A segment ends at the first of delimiters and keeps it, so "user:" and
+// the key "user" are different children. Children are sorted by bytes,
+// largest first, and only the first maxTreeChildren are returned along with
+// the number left out.
+func (s *MemcachedService) KeyTree(ctx context.Context, prefix, delimiters string) (models.KeyTreeNode, []models.KeyTreeNode, int, error) {
+	node := models.KeyTreeNode{Name: prefix, Prefix: prefix}
+
+	st, err := s.state()
+	if err != nil {
+		return node, nil, 0, err
+	}
+	if delimiters == "" {
+		delimiters = defaultTreeDelimiters
+	}
+	if strings.ContainsAny(delimiters, " \t\r\n") {
+		return node, nil, 0, fmt.Errorf("%w: delimiters cannot contain whitespace", ErrInvalidFilter)
+	}
+
+	var match *KeyMatcher
+	if prefix != "" {
+		match = &KeyMatcher{prefix: prefix}
+	}
+
+	children := make(map[string]*models.KeyTreeNode)
+	err = st.scanKeys(ctx, KeyCursor{}, match, func(key models.KeyInfo, _ KeyCursor) error {
+		node.Count++
+		node.Bytes += int64(key.Size)
+
+		rest := key.Key[len(prefix):]
+		name, leaf := rest, true
+		if i := strings.IndexAny(rest, delimiters); i >= 0 {
+			name, leaf = rest[:i+1], false
+		}
+		if name == "" {
+			// The prefix itself is a key.
+			return nil
+		}
+
+		id := name
+		if leaf {
+			id = "\x00" + name
+		}
+		child, ok := children[id]
+		if !ok {
+			child = &models.KeyTreeNode{Name: name, Prefix: prefix + name, Leaf: leaf}
+			children[id] = child
+		}
+		child.Count++
+		child.Bytes += int64(key.Size)
+		return nil
+	})
+	if err != nil {
+		return node, nil, 0, err
+	}
+
+	result := make([]models.KeyTreeNode, 0, len(children))
+	for _, child := range children {
+		result = append(result, *child)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Bytes != result[j].Bytes {
+			return result[i].Bytes > result[j].Bytes
+		}
+		return result[i].Name < result[j].Name
+	})
+
+	truncated := 0
+	if len(result) > maxTreeChildren {
+		truncated = len(result) - maxTreeChildren
+		result = result[:maxTreeChildren]
+	}
+
+	return node, result, truncated, nil
+}
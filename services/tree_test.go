@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func startTreeServer(t *testing.T) string {
+	sizes := map[string]int{
+		"catalog:v41:1":   100,
+		"catalog:v41:2":   100,
+		"catalog:v42:1":   50,
+		"catalog":         5,
+		"session:abc":     10,
+		"cfg/flags.beta":  1,
+		"cfg/flags.alpha": 1,
+	}
+	cache := newFakeCache()
+	for key, size := range sizes {
+		cache.set(key, strings.Repeat("x", size))
+	}
+	return startCacheServer(t, cache)
+}
+
+func TestKeyTree_Root(t *testing.T) {
+	service := NewMemcachedService()
+	_ = service.Connect(startTreeServer(t))
+
+	node, children, truncated, err := service.KeyTree(context.Background(), "", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if node.Count != 7 || node.Bytes != 267 || truncated != 0 {
+		t.Errorf("Unexpected root %+v (truncated %d)", node, truncated)
+	}
+
+	// Largest first, equal sizes by name.
+	want := []string{"catalog: 3 250", "session: 1 10", "catalog 1 5 leaf", "cfg/flags.alpha 1 1 leaf", "cfg/flags.beta 1 1 leaf"}
+	if len(children) != len(want) {
+		t.Fatalf("Expected %d children, got %+v", len(want), children)
+	}
+	for i, child := range children {
+		got := fmt.Sprintf("%s %d %d", child.Name, child.Count, child.Bytes)
+		if child.Leaf {
+			got += " leaf"
+		}
+		if got != want[i] {
+			t.Errorf("Child %d: got %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestKeyTree_ExpandWithDelimiters(t *testing.T) {
+	service := NewMemcachedService()
+	_ = service.Connect(startTreeServer(t))
+
+	_, children, _, err := service.KeyTree(context.Background(), "catalog:", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(children) != 2 || children[0].Prefix != "catalog:v41:" || children[0].Count != 2 || children[1].Name != "v42:" {
+		t.Errorf("Unexpected children %+v", children)
+	}
+
+	_, children, _, err = service.KeyTree(context.Background(), "cfg/", "/.")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(children) != 1 || children[0].Name != "flags." || children[0].Count != 2 {
+		t.Errorf("Unexpected children %+v", children)
+	}
+
+	if _, _, _, err := service.KeyTree(context.Background(), "", ": "); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("Expected ErrInvalidFilter for whitespace delimiters, got %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"unicode/utf8"
+)
+
+// EncodeValue returns value as text when it is valid UTF-8 and as base64
+// otherwise, together with the encoding used ("" or "base64"). JSON cannot
+// carry arbitrary bytes, so every value sent to clients goes through it.
+func EncodeValue(value []byte) (string, string) {
+	if utf8.Valid(value) {
+		return string(value), ""
+	}
+	return base64.StdEncoding.EncodeToString(value), "base64"
+}
+
+// DecodeValue reverses EncodeValue.
+func DecodeValue(value, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(value), nil
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 value: %v", err)
+		}
+		return decoded, nil
+	}
+	return nil, fmt.Errorf("unknown encoding %q", encoding)
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeValue_RoundTrip(t *testing.T) {
+	for _, value := range [][]byte{[]byte("plain text\n"), {0xff, 0x00, 0x10}, {}} {
+		encoded, encoding := EncodeValue(value)
+		decoded, err := DecodeValue(encoded, encoding)
+		if err != nil || !bytes.Equal(decoded, value) {
+			t.Errorf("%q: got %q (%v)", value, decoded, err)
+		}
+	}
+
+	if _, encoding := EncodeValue([]byte("ok")); encoding != "" {
+		t.Errorf("Expected UTF-8 values to stay as text, got %q", encoding)
+	}
+	if _, err := DecodeValue("!!", "base64"); err == nil {
+		t.Error("Expected invalid base64 to be rejected")
+	}
+	if _, err := DecodeValue("x", "hex"); err == nil {
+		t.Error("Expected an unknown encoding to be rejected")
+	}
+}
+
+func TestNewItem_KeepsValueWhitespace(t *testing.T) {
+	item, err := newItem(" key ", "  line\n", 0, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if item.Key != "key" || string(item.Value) != "  line\n" {
+		t.Errorf("Expected the value to be stored as sent, got %q=%q", item.Key, item.Value)
+	}
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"memcached-management/models"
+)
+
+// watchCommand turns a connection into a log stream of reads, writes and
+// evictions. Such a connection accepts no other commands.
+const watchCommand = "watch fetchers mutations evictions"
+
+// watchBuffer is how many events may wait for a slow consumer before the
+// readers block; memcached then drops lines and reports them as skipped.
+const watchBuffer = 256
+
+// watchTypes maps memcached log types to event types.
+var watchTypes = map[string]string{
+	"item_get":   models.WatchFetch,
+	"item_store": models.WatchStore,
+	"deleted":    models.WatchDelete,
+	"eviction":   models.WatchEviction,
+}
+
+// WatchStream is a running watch on every server of a connection. Events
+// are delivered on Events until the stream is closed or a server fails.
+type WatchStream struct {
+	events chan models.WatchEvent
+	cancel context.CancelFunc
+
+	mu  sync.Mutex
+	err error
+}
+
+// Watch opens a dedicated watch connection to every server. It returns an
+// error when any server cannot be watched, e.g. because it predates the
+// watch command or its mutations log; after that, failures end the stream
+// and are reported by Err.
+func (s *MemcachedService) Watch(ctx context.Context) (*WatchStream, error) {
+	st, err := s.state()
+	if err != nil {
+		return nil, err
+	}
+
+	var conns []*watchConn
+	for _, server := range st.servers {
+		wc, err := startWatch(server, st.timeout)
+		if err != nil {
+			for _, wc := range conns {
+				wc.conn.Close()
+			}
+			return nil, fmt.Errorf("%s: %w", server, err)
+		}
+		conns = append(conns, wc)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	w := &WatchStream{events: make(chan models.WatchEvent, watchBuffer), cancel: cancel}
+	var wg sync.WaitGroup
+	for _, wc := range conns {
+		wg.Add(1)
+		go func(wc *watchConn) {
+			defer wg.Done()
+			if err := w.read(ctx, wc); err != nil && ctx.Err() == nil {
+				w.fail(fmt.Errorf("%s: %w", wc.server, err))
+			}
+		}(wc)
+	}
+	go func() {
+		wg.Wait()
+		close(w.events)
+	}()
+	return w, nil
+}
+
+type watchConn struct {
+	server string
+	conn   net.Conn
+	r      *bufio.Reader
+}
+
+func startWatch(server string, timeout time.Duration) (*watchConn, error) {
+	conn, err := net.DialTimeout("tcp", server, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %v", err)
+	}
+	wc := &watchConn{server: server, conn: conn, r: bufio.NewReader(conn)}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	var reply string
+	if _, err = fmt.Fprintf(conn, "%s\r\n", watchCommand); err == nil {
+		reply, err = wc.r.ReadString('\n')
+	}
+	if reply = strings.TrimRight(reply, "\r\n"); err == nil && reply != "OK" {
+		err = fmt.Errorf("watch not supported: server replied %q", reply)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// The stream can be idle for any time.
+	conn.SetDeadline(time.Time{})
+	return wc, nil
+}
+
+// Events returns the event channel, which is closed when the stream ends.
+func (w *WatchStream) Events() <-chan models.WatchEvent {
+	return w.events
+}
+
+// Close stops the stream and closes its connections.
+func (w *WatchStream) Close() {
+	w.cancel()
+}
+
+// Err returns the failure that ended the stream, if any.
+func (w *WatchStream) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+func (w *WatchStream) fail(err error) {
+	w.mu.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.mu.Unlock()
+	w.cancel()
+}
+
+func (w *WatchStream) read(ctx context.Context, wc *watchConn) error {
+	defer wc.conn.Close()
+	stop := context.AfterFunc(ctx, func() { wc.conn.Close() })
+	defer stop()
+
+	for {
+		line, err := wc.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		event, ok := parseWatchLine(wc.server, strings.TrimRight(line, "\r\n"))
+		if !ok {
+			continue
+		}
+		select {
+		case w.events <- event:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// parseWatchLine parses a log line such as
+// "ts=1700000000.123456 gid=42 type=item_get key=user%3A1 status=found clsid=1 cfd=20 size=68".
+// Keys are URI encoded by the server.
+func parseWatchLine(server, line string) (models.WatchEvent, bool) {
+	event := models.WatchEvent{Server: server}
+	for _, field := range strings.Fields(line) {
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+
+		switch name {
+		case "ts":
+			event.Time = parseWatchTime(value)
+		case "gid":
+		case "type":
+			event.Type = value
+			if t, ok := watchTypes[value]; ok {
+				event.Type = t
+			}
+		case "key":
+			if key, err := url.PathUnescape(value); err == nil {
+				event.Key = key
+			} else {
+				event.Key = value
+			}
+		case "status":
+			event.Status = value
+		case "cmd":
+			event.Command = value
+		case "ttl":
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				event.TTL = &n
+			}
+		case "la":
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				event.LastAccess = &n
+			}
+		case "fetch":
+			fetched := value == "yes"
+			event.Fetched = &fetched
+		case "size":
+			event.Size, _ = strconv.Atoi(value)
+		case "clsid":
+			event.SlabClass, _ = strconv.Atoi(value)
+		case "skipped":
+			event.Type = models.WatchSkipped
+			event.Skipped, _ = strconv.Atoi(value)
+		default:
+			if event.Fields == nil {
+				event.Fields = make(map[string]string)
+			}
+			event.Fields[name] = value
+		}
+	}
+	if event.Type == "" {
+		return event, false
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	return event, true
+}
+
+// parseWatchTime parses "seconds.microseconds" since the epoch.
+func parseWatchTime(value string) time.Time {
+	secs, frac, _ := strings.Cut(value, ".")
+	s, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	usec, _ := strconv.ParseInt((frac + "000000")[:6], 10, 64)
+	return time.Unix(s, usec*1000)
+}
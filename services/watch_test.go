@@ -0,0 +1,128 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"memcached-management/models"
+)
+
+func TestParseWatchLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		check func(models.WatchEvent) bool
+	}{
+		{
+			"ts=1700000000.250000 gid=1 type=item_get key=user%3A1 status=found clsid=1 cfd=20 size=68",
+			func(e models.WatchEvent) bool {
+				return e.Type == models.WatchFetch && e.Key == "user:1" && e.Status == "found" && e.Size == 68 && e.SlabClass == 1 &&
+					e.Time.Equal(time.Unix(1700000000, 250000000)) && e.Fields["cfd"] == "20"
+			},
+		},
+		{
+			"ts=1700000000.1 gid=2 type=item_store key=a status=stored cmd=set ttl=300 clsid=1 cfd=20 size=5",
+			func(e models.WatchEvent) bool {
+				return e.Type == models.WatchStore && e.Command == "set" && e.TTL != nil && *e.TTL == 300 && e.Time.Nanosecond() == 100000000
+			},
+		},
+		{
+			"ts=1700000000.000001 gid=3 type=deleted key=a cmd=delete clsid=1 cfd=20 size=5",
+			func(e models.WatchEvent) bool { return e.Type == models.WatchDelete && e.Command == "delete" },
+		},
+		{
+			"ts=1700000000.000001 gid=4 type=eviction key=old fetch=no ttl=-1 la=3600 clsid=2",
+			func(e models.WatchEvent) bool {
+				return e.Type == models.WatchEviction && e.Fetched != nil && !*e.Fetched && *e.LastAccess == 3600 && *e.TTL == -1
+			},
+		},
+		{
+			"skipped=12",
+			func(e models.WatchEvent) bool { return e.Type == models.WatchSkipped && e.Skipped == 12 && !e.Time.IsZero() },
+		},
+		{
+			"ts=1700000000.000001 gid=5 type=mystery key=a",
+			func(e models.WatchEvent) bool { return e.Type == "mystery" },
+		},
+	}
+
+	for _, tt := range tests {
+		event, ok := parseWatchLine("cache-1:11211", tt.line)
+		if !ok || event.Server != "cache-1:11211" || !tt.check(event) {
+			t.Errorf("%q: unexpected event %+v", tt.line, event)
+		}
+	}
+
+	if _, ok := parseWatchLine("", "WARNING: something"); ok {
+		t.Error("Expected a line without a type to be ignored")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	addr := startFakeServer(t, func(line string, r *bufio.Reader) string {
+		switch line {
+		case "version":
+			return "VERSION 1.6.21\r\n"
+		case watchCommand:
+			return "OK\r\n" +
+				"ts=1700000000.000001 gid=1 type=item_store key=a status=stored cmd=set ttl=0 clsid=1 cfd=20 size=1\r\n" +
+				"ts=1700000000.000002 gid=2 type=item_get key=a status=found clsid=1 cfd=20 size=1\r\n"
+		}
+		return "ERROR\r\n"
+	})
+
+	service := NewMemcachedService()
+	if err := service.Connect(addr); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := service.Watch(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var types []string
+	for len(types) < 2 {
+		select {
+		case event := <-stream.Events():
+			types = append(types, event.Type)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for events, got %v", types)
+		}
+	}
+	if strings.Join(types, ",") != "store,fetch" {
+		t.Errorf("Expected store,fetch, got %v", types)
+	}
+
+	stream.Close()
+	select {
+	case _, ok := <-stream.Events():
+		if ok {
+			t.Error("Expected no more events after Close")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the stream to end after Close")
+	}
+	if stream.Err() != nil {
+		t.Errorf("Expected no error after Close, got %v", stream.Err())
+	}
+}
+
+func TestWatch_Unsupported(t *testing.T) {
+	addr := startFakeServer(t, func(line string, r *bufio.Reader) string {
+		if line == "version" {
+			return "VERSION 1.4.20\r\n"
+		}
+		return "ERROR\r\n"
+	})
+
+	service := NewMemcachedService()
+	if err := service.Connect(addr); err != nil {
+		t.Fatal(err)
+	}
+	_, err := service.Watch(context.Background())
+	if err == nil || !strings.Contains(err.Error(), `watch not supported: server replied "ERROR"`) {
+		t.Errorf("Expected an unsupported watch error, got %v", err)
+	}
+}
@@ -3,9 +3,13 @@ package integration
 import (
 	"bytes"
 	"encoding/json"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"memcached-management/config"
@@ -14,21 +18,71 @@ import (
 	"memcached-management/services"
 )
 
+// TestMain runs the suite from the repository root so handlers can resolve
+// paths such as ./web/index.html.
+func TestMain(m *testing.M) {
+	if err := os.Chdir("../.."); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
 func setupRouter() *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	logger := config.SetupLogger()
-	memcachedService := services.NewMemcachedService()
-	handler := handlers.NewHandler(memcachedService, logger)
+	sessions := services.NewSessionManager(time.Hour)
+	profiles, _ := services.NewProfileStore("")
+	handler := handlers.NewHandler(sessions, profiles, logger)
 
 	r := gin.New()
+	r.Use(handler.MetricsMiddleware())
+
 	r.GET("/", handler.ServeIndex)
 	r.POST("/connect", handler.HandleConnect)
+	r.POST("/disconnect", handler.HandleDisconnect)
 	r.POST("/set", handler.HandleSet)
 	r.POST("/get", handler.HandleGet)
 	r.POST("/getMultiple", handler.HandleGetMultiple)
 	r.POST("/delete", handler.HandleDelete)
 	r.POST("/flush", handler.HandleFlush)
+	r.POST("/incr", handler.HandleIncrement)
+	r.POST("/decr", handler.HandleDecrement)
+	r.POST("/add", handler.HandleAdd)
+	r.POST("/replace", handler.HandleReplace)
+	r.POST("/append", handler.HandleAppend)
+	r.POST("/prepend", handler.HandlePrepend)
+	r.POST("/touch", handler.HandleTouch)
 	r.POST("/listKeys", handler.HandleListKeys)
+	r.POST("/deleteByPattern", handler.HandleDeleteByPattern)
+	r.POST("/keyTree", handler.HandleKeyTree)
+	r.GET("/export", handler.HandleExport)
+	r.POST("/import", handler.HandleImport)
+	r.POST("/migrations", handler.HandleStartMigration)
+	r.GET("/migrations", handler.HandleListMigrations)
+	r.GET("/migrations/:id", handler.HandleGetMigration)
+	r.POST("/migrations/:id/cancel", handler.HandleCancelMigration)
+	r.POST("/diff", handler.HandleDiff)
+	r.POST("/diff/files", handler.HandleDiffFiles)
+	r.GET("/decoders", handler.HandleListDecoders)
+	r.PUT("/decoders/rules", handler.HandleSetDecoderRules)
+	r.POST("/decoders/protobuf", handler.HandleUploadProtoDescriptors)
+	r.GET("/stats", handler.HandleStats)
+	r.GET("/watch", handler.HandleWatch)
+	r.POST("/hotkeys", handler.HandleStartHotKeys)
+	r.GET("/hotkeys", handler.HandleGetHotKeys)
+	r.POST("/hotkeys/stop", handler.HandleStopHotKeys)
+	r.GET("/keywatches", handler.HandleListKeyWatches)
+	r.POST("/keywatches", handler.HandleAddKeyWatch)
+	r.GET("/keywatches/events", handler.HandleKeyWatchEvents)
+	r.GET("/keywatches/:id", handler.HandleGetKeyWatch)
+	r.DELETE("/keywatches/:id", handler.HandleRemoveKeyWatch)
+	r.GET("/metrics", handler.HandleMetrics)
+
+	r.GET("/profiles", handler.HandleListProfiles)
+	r.POST("/profiles", handler.HandleCreateProfile)
+	r.GET("/profiles/:name", handler.HandleGetProfile)
+	r.PUT("/profiles/:name", handler.HandleUpdateProfile)
+	r.DELETE("/profiles/:name", handler.HandleDeleteProfile)
 
 	return r
 }
@@ -248,4 +302,739 @@ func TestHandleListKeys_NotConnected(t *testing.T) {
 	if response.Error != "Error listing keys: not connected to Memcached" {
 		t.Errorf("Expected error 'Error listing keys: not connected to Memcached', got '%s'", response.Error)
 	}
-}
\ No newline at end of file
+}
+
+func TestHandleListKeys_InvalidRequests(t *testing.T) {
+	router := setupRouter()
+
+	tests := []struct {
+		body   string
+		status int
+		error  string
+	}{
+		{`{"cursor":"not a cursor"}`, http.StatusBadRequest, "Invalid cursor"},
+		{`{"stream":"xml"}`, http.StatusBadRequest, "Invalid stream mode: xml"},
+		{`{"regex":"("}`, http.StatusBadRequest, "invalid filter: error parsing regexp: missing closing ): `(`"},
+		{`{"stream":"ndjson"}`, http.StatusInternalServerError, "Error listing keys: not connected to Memcached"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/listKeys", bytes.NewBufferString(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.body, tt.status, w.Code)
+		}
+
+		var response models.ListKeysResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Error != tt.error {
+			t.Errorf("%s: expected error %q, got %q", tt.body, tt.error, response.Error)
+		}
+	}
+}
+
+func TestHandleDeleteByPattern_Validation(t *testing.T) {
+	router := setupRouter()
+
+	tests := []struct {
+		body   string
+		status int
+		error  string
+	}{
+		{`{}`, http.StatusBadRequest, "A prefix, glob or regex is required"},
+		{`{"min_size":10}`, http.StatusBadRequest, "A prefix, glob or regex is required"},
+		{`{"glob":"a*","regex":"a"}`, http.StatusBadRequest, "invalid filter: glob and regex cannot be combined"},
+		{`{"glob":"catalog:*"}`, http.StatusInternalServerError, "Error previewing delete: not connected to Memcached"},
+		{`{"glob":"catalog:*","execute":true}`, http.StatusInternalServerError, "Error deleting by pattern: not connected to Memcached"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/deleteByPattern", bytes.NewBufferString(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.body, tt.status, w.Code)
+		}
+
+		var response models.DeleteByPatternResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Error != tt.error {
+			t.Errorf("%s: expected error %q, got %q", tt.body, tt.error, response.Error)
+		}
+	}
+}
+
+func TestHandleKeyTree_NotConnected(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/keyTree", bytes.NewBufferString(`{"prefix":"catalog:"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	var response models.KeyTreeResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error != "Error building key tree: not connected to Memcached" {
+		t.Errorf("Unexpected error %q", response.Error)
+	}
+}
+
+func TestHandleExport_Errors(t *testing.T) {
+	router := setupRouter()
+
+	tests := []struct {
+		url    string
+		status int
+		error  string
+	}{
+		{"/export?format=zip", http.StatusBadRequest, "Invalid format: zip"},
+		{"/export?prefix=user:", http.StatusInternalServerError, "Error exporting: not connected to Memcached"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tt.url, nil)
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.url, tt.status, w.Code)
+		}
+
+		var response models.ItemResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Error != tt.error {
+			t.Errorf("%s: expected error %q, got %q", tt.url, tt.error, response.Error)
+		}
+	}
+}
+
+func TestHandleImport_Errors(t *testing.T) {
+	router := setupRouter()
+
+	upload := func(fields map[string]string, file string) *http.Request {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		for name, value := range fields {
+			writer.WriteField(name, value)
+		}
+		if file != "" {
+			part, _ := writer.CreateFormFile("file", "dump.jsonl")
+			part.Write([]byte(file))
+		}
+		writer.Close()
+
+		req, _ := http.NewRequest("POST", "/import", &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req
+	}
+
+	tests := []struct {
+		req    *http.Request
+		status int
+		error  string
+	}{
+		{upload(nil, ""), http.StatusBadRequest, "A file upload is required"},
+		{upload(map[string]string{"mode": "merge"}, `{"key":"a","value":"b"}`), http.StatusBadRequest, `Error importing: invalid import: unknown mode "merge"`},
+		{upload(nil, `{"key":"a","value":"b"}`), http.StatusInternalServerError, "Error importing: not connected to Memcached"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, tt.req)
+
+		if w.Code != tt.status {
+			t.Errorf("Expected status %d, got %d", tt.status, w.Code)
+		}
+
+		var response models.ImportResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Error != tt.error {
+			t.Errorf("Expected error %q, got %q", tt.error, response.Error)
+		}
+	}
+}
+
+func TestProfiles_CRUD(t *testing.T) {
+	router := setupRouter()
+
+	profile := models.Profile{Name: "staging", Servers: []string{"cache-1", "cache-2"}, TimeoutMS: 250, ReadOnly: true}
+	jsonData, _ := json.Marshal(profile)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/profiles", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/profiles", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for duplicate profile, got %d", http.StatusConflict, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/profiles/staging", nil)
+	router.ServeHTTP(w, req)
+
+	var response models.ProfileResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Profiles) != 1 || response.Profiles[0].Servers[0] != "cache-1:11211" {
+		t.Errorf("Expected stored profile with normalized servers, got %+v", response.Profiles)
+	}
+
+	update, _ := json.Marshal(models.Profile{Servers: []string{"cache-3"}})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/profiles/staging", bytes.NewBuffer(update))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/profiles/staging", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/profiles/staging", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d after delete, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestHandleConnect_UnknownProfile(t *testing.T) {
+	router := setupRouter()
+
+	connectReq := models.ConnectRequest{Profile: "prod-eu"}
+	jsonData, _ := json.Marshal(connectReq)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/connect", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	var response models.ConnectResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error != "Profile not found: prod-eu" {
+		t.Errorf("Expected error 'Profile not found: prod-eu', got '%s'", response.Error)
+	}
+}
+
+func TestSessions_AreIsolated(t *testing.T) {
+	router := setupRouter()
+
+	// Connect session A to a closed port: the connection fails, but the
+	// session stays bound to that server.
+	jsonData, _ := json.Marshal(models.ConnectRequest{URL: "127.0.0.1:1"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/connect", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	cookies := w.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatal("Expected a session cookie to be set")
+	}
+	token := w.Header().Get("X-Session-Token")
+	if token == "" || token != cookies[0].Value {
+		t.Fatalf("Expected session token header to match cookie, got '%s'", token)
+	}
+
+	itemReq, _ := json.Marshal(models.ItemRequest{Key: "test", Value: "value"})
+
+	// Session B has never connected
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/set", bytes.NewBuffer(itemReq))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	var response models.ItemResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error != "Error saving: not connected to Memcached" {
+		t.Errorf("Expected other session to be unaffected, got '%s'", response.Error)
+	}
+
+	// Session A uses its own connection
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/set", bytes.NewBuffer(itemReq))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(cookies[0])
+	router.ServeHTTP(w, req)
+
+	response = models.ItemResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Success || response.Error == "Error saving: not connected to Memcached" {
+		t.Errorf("Expected session A to use its connected client, got '%s'", response.Error)
+	}
+}
+
+func TestHandleStats_NotConnected(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/stats", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	var response models.StatsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error != "Error getting stats: not connected to Memcached" {
+		t.Errorf("Expected error 'Error getting stats: not connected to Memcached', got '%s'", response.Error)
+	}
+}
+
+func TestHandleMetrics(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/stats", nil)
+	router.ServeHTTP(w, req)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/metrics", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("Expected text/plain content type, got '%s'", contentType)
+	}
+
+	body := w.Body.String()
+	expected := `memcached_visualizer_http_request_errors_total{method="GET",route="/stats",status="500"} 1`
+	if !strings.Contains(body, expected) {
+		t.Errorf("Expected metrics to contain %q, got:\n%s", expected, body)
+	}
+}
+
+func TestHandleSet_CASNotConnected(t *testing.T) {
+	router := setupRouter()
+
+	itemReq := models.ItemRequest{Key: "test", Value: "value", CAS: 42}
+	jsonData, _ := json.Marshal(itemReq)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/set", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	var response models.ItemResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error != "Error saving: not connected to Memcached" {
+		t.Errorf("Expected error 'Error saving: not connected to Memcached', got '%s'", response.Error)
+	}
+}
+
+func TestOperations_NotConnected(t *testing.T) {
+	router := setupRouter()
+
+	tests := map[string]string{
+		"/incr":    "Error on increment: not connected to Memcached",
+		"/decr":    "Error on decrement: not connected to Memcached",
+		"/add":     "Error on add: not connected to Memcached",
+		"/replace": "Error on replace: not connected to Memcached",
+		"/append":  "Error on append: not connected to Memcached",
+		"/prepend": "Error on prepend: not connected to Memcached",
+		"/touch":   "Error on touch: not connected to Memcached",
+	}
+
+	for path, expected := range tests {
+		jsonData, _ := json.Marshal(models.ItemRequest{Key: "counter", Value: "1", Delta: 2, TTL: 60})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%s: expected status %d, got %d", path, http.StatusInternalServerError, w.Code)
+		}
+
+		var response models.ItemResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Error != expected {
+			t.Errorf("%s: expected error '%s', got '%s'", path, expected, response.Error)
+		}
+	}
+}
+
+func TestHandleMigrations_Validation(t *testing.T) {
+	router := setupRouter()
+
+	tests := []struct {
+		method string
+		url    string
+		body   string
+		status int
+		error  string
+	}{
+		{"POST", "/migrations", `{"destination":{"servers":["localhost:11212"]}}`, http.StatusBadRequest, "invalid profile: source servers or profile are required"},
+		{"POST", "/migrations", `{"source":{"servers":["localhost:11211"],"profile":"prod"},"destination":{"servers":["localhost:11212"]}}`, http.StatusBadRequest, "invalid profile: source takes either servers or a profile"},
+		{"POST", "/migrations", `{"source":{"profile":"missing"},"destination":{"servers":["localhost:11212"]}}`, http.StatusNotFound, "source: profile not found"},
+		{"POST", "/migrations", `{"source":{"servers":["localhost:11211"]},"destination":{"servers":["localhost:11212"]},"mode":"merge"}`, http.StatusBadRequest, `Error starting migration: invalid migration: unknown mode "merge"`},
+		{"GET", "/migrations/missing", "", http.StatusNotFound, "migration not found"},
+		{"POST", "/migrations/missing/cancel", "", http.StatusNotFound, "migration not found"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tt.method, tt.url, bytes.NewBufferString(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.url, tt.status, w.Code)
+		}
+
+		var response models.MigrationResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Error != tt.error {
+			t.Errorf("%s %s: expected error %q, got %q", tt.method, tt.url, tt.error, response.Error)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/migrations", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestHandleDiff_Validation(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/diff", bytes.NewBufferString(`{"a":{"servers":["localhost:11211"]}}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	var response models.DiffResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusBadRequest || response.Error != "invalid profile: b servers or profile are required" {
+		t.Errorf("Expected a missing side to be rejected, got %d %q", w.Code, response.Error)
+	}
+
+	upload := func(files map[string]string) *http.Request {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		for name, content := range files {
+			part, _ := writer.CreateFormFile(name, name+".jsonl")
+			part.Write([]byte(content))
+		}
+		writer.Close()
+
+		req, _ := http.NewRequest("POST", "/diff/files", &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req
+	}
+
+	tests := []struct {
+		req    *http.Request
+		status int
+		error  string
+	}{
+		{upload(map[string]string{"a": `{"key":"k","value":"v"}`}), http.StatusBadRequest, "Files a and b are required"},
+		{upload(map[string]string{"a": `{"key":"k","value":"v"}`, "b": "not json"}), http.StatusBadRequest, "Error comparing: b: invalid diff: line 1: invalid JSON: invalid character 'o' in literal null (expecting 'u')"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, tt.req)
+
+		if w.Code != tt.status {
+			t.Errorf("Expected status %d, got %d", tt.status, w.Code)
+		}
+
+		var response models.DiffResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Error != tt.error {
+			t.Errorf("Expected error %q, got %q", tt.error, response.Error)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, upload(map[string]string{"a": `{"key":"k","value":"v"}`, "b": `{"key":"k","value":"w"}`}))
+	response = models.DiffResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || response.Report == nil || response.Report.Summary.Mismatched != 1 || response.Report.A != "a.jsonl" {
+		t.Errorf("Unexpected diff response %d %+v", w.Code, response)
+	}
+}
+
+func TestHandleSet_InvalidEncodedValue(t *testing.T) {
+	router := setupRouter()
+
+	tests := []struct {
+		body  string
+		error string
+	}{
+		{`{"key":"k","value":"!!","encoding":"base64"}`, "Invalid value: invalid base64 value: illegal base64 data at input byte 0"},
+		{`{"key":"k","value":"ff","encoding":"hex"}`, `Invalid value: unknown encoding "hex"`},
+	}
+
+	for _, path := range []string{"/set", "/add"} {
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s %s: expected status %d, got %d", path, tt.body, http.StatusBadRequest, w.Code)
+			}
+
+			var response models.ItemResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Error != tt.error {
+				t.Errorf("%s %s: expected error %q, got %q", path, tt.body, tt.error, response.Error)
+			}
+		}
+	}
+}
+
+func TestHandleDecoderRules(t *testing.T) {
+	router := setupRouter()
+
+	put := func(body string) (int, models.DecodersResponse) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/decoders/rules", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		var response models.DecodersResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code, response
+	}
+
+	status, response := put(`{"rules":[{"prefix":"py:","decoder":"yaml"}]}`)
+	if status != http.StatusBadRequest || response.Error != `invalid decoder rule: unknown decoder "yaml"` {
+		t.Errorf("Expected an unknown decoder to be rejected, got %d %q", status, response.Error)
+	}
+
+	status, response = put(`{"rules":[{"prefix":"py:","decoder":"pickle"},{"prefix":"tmp:","decoder":"none"}]}`)
+	if status != http.StatusOK || len(response.Rules) != 2 {
+		t.Errorf("Expected the rules to be saved, got %d %+v", status, response)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/decoders", nil)
+	router.ServeHTTP(w, req)
+
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Rules) != 2 || response.Rules[0].Decoder != "pickle" {
+		t.Errorf("Expected the saved rules, got %+v", response.Rules)
+	}
+	decoders := strings.Join(response.Decoders, ",")
+	for _, name := range []string{"php", "pickle", "java"} {
+		if !strings.Contains(decoders, name) {
+			t.Errorf("Expected decoder %s in %s", name, decoders)
+		}
+	}
+}
+
+func TestHandleUploadProtoDescriptors_Validation(t *testing.T) {
+	router := setupRouter()
+
+	upload := func(field string, content []byte) *http.Request {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, _ := writer.CreateFormFile(field, "descriptors.pb")
+		part.Write(content)
+		writer.Close()
+
+		req, _ := http.NewRequest("POST", "/decoders/protobuf", &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req
+	}
+
+	tests := []struct {
+		req   *http.Request
+		error string
+	}{
+		{upload("file", []byte{}), "A descriptor set upload is required"},
+		{upload("descriptors", []byte{}), "Error loading descriptors: invalid descriptor set: no files"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, tt.req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+
+		var response models.DecodersResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Error != tt.error {
+			t.Errorf("Expected error %q, got %q", tt.error, response.Error)
+		}
+	}
+}
+
+func TestHandleWatch_NotConnected(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/watch?prefix=user:", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	var response models.ItemResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error != "Error starting watch: not connected to Memcached" {
+		t.Errorf("Unexpected error %q", response.Error)
+	}
+}
+
+func TestHandleHotKeys_Validation(t *testing.T) {
+	router := setupRouter()
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		code   int
+		error  string
+	}{
+		{"GET", "/hotkeys", "", http.StatusNotFound, "hot key analysis not started"},
+		{"POST", "/hotkeys/stop", "", http.StatusNotFound, "hot key analysis not started"},
+		{"POST", "/hotkeys", `{"window_seconds":2}`, http.StatusBadRequest, "Error starting hot key analysis: invalid hot key options: window must be between 6 and 3600 seconds"},
+		{"POST", "/hotkeys", `{}`, http.StatusInternalServerError, "Error starting hot key analysis: not connected to Memcached"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.code {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.code, w.Code)
+		}
+
+		var response models.HotKeyResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Error != tt.error {
+			t.Errorf("%s %s: expected error %q, got %q", tt.method, tt.path, tt.error, response.Error)
+		}
+	}
+}
+
+func TestHandleKeyWatches_NotConnected(t *testing.T) {
+	router := setupRouter()
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		code   int
+		error  string
+	}{
+		{"GET", "/keywatches", "", http.StatusOK, ""},
+		{"POST", "/keywatches", `{"key":"config"}`, http.StatusInternalServerError, "Error adding watch: not connected to Memcached"},
+		{"GET", "/keywatches/events", "", http.StatusInternalServerError, "Error subscribing to changes: not connected to Memcached"},
+		{"GET", "/keywatches/abc", "", http.StatusNotFound, "key watch not found"},
+		{"DELETE", "/keywatches/abc", "", http.StatusNotFound, "key watch not found"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.code {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.code, w.Code)
+		}
+
+		var response models.KeyWatchResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Error != tt.error {
+			t.Errorf("%s %s: expected error %q, got %q", tt.method, tt.path, tt.error, response.Error)
+		}
+	}
+}